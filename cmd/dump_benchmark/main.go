@@ -45,7 +45,7 @@ func main() {
 	sClient, err := client.New(&client.Config{
 		Addr:       sOpts.Addr,
 		User:       sOpts.User,
-		Password:   sOpts.Password,
+		Password:   string(sOpts.Password),
 		Database:   sOpts.Database,
 		Charset:    sOpts.Charset,
 		MaxRetries: 2,
@@ -58,7 +58,7 @@ func main() {
 	uClient, err := client.New(&client.Config{
 		Addr:           uOpts.Addr,
 		User:           uOpts.User,
-		Password:       uOpts.Password,
+		Password:       string(uOpts.Password),
 		Database:       uOpts.Database,
 		Charset:        uOpts.Charset,
 		MaxRetries:     uOpts.MaxRetries,
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/atomic"
+
+	"github.com/city-mobil/go-mymy/internal/bridge"
+)
+
+// initAdminHandler serves the admin API. The token is replaced on the config reload.
+func initAdminHandler(token *atomic.String, b *bridge.Bridge, reload func() (*bridge.ReloadResult, error)) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/reload", postOnly(func(w http.ResponseWriter, _ *http.Request) {
+		res, err := reload()
+		if err != nil {
+			writeError(w, adminErrorStatus(err), err)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	}))
+	mux.HandleFunc("/admin/pause", postOnly(func(w http.ResponseWriter, _ *http.Request) {
+		if err := b.Pause(); err != nil {
+			writeError(w, adminErrorStatus(err), err)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+	}))
+	mux.HandleFunc("/admin/resume", postOnly(func(w http.ResponseWriter, _ *http.Request) {
+		if err := b.Resume(); err != nil {
+			writeError(w, adminErrorStatus(err), err)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+	}))
+	mux.HandleFunc("/admin/skip", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var err error
+		switch {
+		case query.Get("transaction") == "true":
+			err = b.SkipTransaction()
+		case query.Get("events") != "":
+			var n int
+			n, err = strconv.Atoi(query.Get("events"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, errors.New("events must be a number"))
+
+				return
+			}
+			err = b.SkipEvents(n)
+		default:
+			writeError(w, http.StatusBadRequest, errors.New("events or transaction is required"))
+
+			return
+		}
+
+		if err != nil {
+			writeError(w, adminErrorStatus(err), err)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"status": "scheduled"})
+	}))
+	mux.HandleFunc("/admin/position", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, b.Position())
+	})
+	mux.HandleFunc("/admin/resnapshot", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, b.Snapshots())
+		case http.MethodPost:
+			table := r.URL.Query().Get("table")
+			if table == "" {
+				writeError(w, http.StatusBadRequest, errors.New("table is required"))
+
+				return
+			}
+
+			err := b.Resnapshot(table)
+			if err != nil {
+				writeError(w, adminErrorStatus(err), err)
+
+				return
+			}
+
+			writeJSON(w, http.StatusAccepted, map[string]string{
+				"table": table,
+				"state": string(bridge.SnapshotRunning),
+			})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	return withAdminAuth(token, mux)
+}
+
+func postOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func withAdminAuth(token *atomic.String, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := token.Load()
+		if want == "" {
+			writeError(w, http.StatusForbidden, errors.New("admin API is disabled"))
+
+			return
+		}
+
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, bridge.ErrRuleNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, errInvalidConfig), errors.Is(err, bridge.ErrInvalidSkipNumber):
+		return http.StatusBadRequest
+	case errors.Is(err, bridge.ErrSnapshotInProgress), errors.Is(err, bridge.ErrNotRunning),
+		errors.Is(err, bridge.ErrReloadUnsupported), errors.Is(err, bridge.ErrNotPaused),
+		errors.Is(err, bridge.ErrGTIDModeDisabled):
+		return http.StatusConflict
+	case errors.Is(err, bridge.ErrSnapshotDisabled):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+// adminClient calls the admin API of the running replicator.
+type adminClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAdminClient(cfg *config.AppConfig, addr string) (*adminClient, error) {
+	if addr == "" {
+		host, port, err := net.SplitHostPort(cfg.ListenAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+
+		addr = net.JoinHostPort(host, port)
+	}
+
+	return &adminClient{
+		baseURL: "http://" + addr,
+		token:   string(cfg.Admin.Token),
+		http: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}, nil
+}
+
+func (c *adminClient) do(method, path string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+
+		return fmt.Errorf("admin API returned %s: %s", resp.Status, apiErr.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/city-mobil/go-mymy/internal/bridge"
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+// checkConfigCmd validates the config and checks the replicator is able
+// to run with it. It exits with 1 if any check failed.
+func checkConfigCmd(args []string) int {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Config file path")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	_ = fs.Parse(args)
+
+	cfg, err := config.ReadFromFile(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config: %v\n", err)
+
+		return 2
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	report := bridge.CheckConfig(ctx, cfg, newHandlerFactory(cfg))
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printCheckReport(report)
+	}
+
+	if report.Failed() {
+		return 1
+	}
+
+	return 0
+}
+
+func printCheckReport(r *bridge.CheckReport) {
+	for _, res := range r.Results {
+		line := fmt.Sprintf("[%-4s] %s", strings.ToUpper(res.Status), res.Name)
+		if res.Message != "" {
+			line += ": " + res.Message
+		}
+		fmt.Println(line)
+
+		if res.Hint != "" {
+			fmt.Printf("       hint: %s\n", res.Hint)
+		}
+	}
+}
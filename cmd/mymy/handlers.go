@@ -0,0 +1,15 @@
+package main
+
+import (
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+	"github.com/city-mobil/go-mymy/pkg/mymy/filter"
+	"github.com/city-mobil/go-mymy/pkg/mymy/script"
+)
+
+// Built-in handlers are linked into the replicator binary and take precedence
+// over the plugins with the same name. Register your handlers here to build
+// a custom replicator without Go plugins.
+func init() {
+	mymy.Register("mymy_filter", filter.NewEventHandler)
+	mymy.Register("mymy_starlark", script.NewEventHandler)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"log/syslog"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/signal"
 	"path"
@@ -20,6 +21,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	sidlog "github.com/siddontang/go-log/log"
+	"go.uber.org/atomic"
 	"golang.org/x/sys/unix"
 	"gopkg.in/natefinch/lumberjack.v2"
 
@@ -27,6 +29,7 @@ import (
 	"github.com/city-mobil/go-mymy/internal/bridge"
 	"github.com/city-mobil/go-mymy/internal/config"
 	"github.com/city-mobil/go-mymy/internal/metrics"
+	"github.com/city-mobil/go-mymy/internal/tracing"
 	"github.com/city-mobil/go-mymy/internal/util"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -40,21 +43,86 @@ var (
 
 var (
 	configPath = flag.String("config", "", "Config file path")
+	dryRun     = flag.Bool("dry-run", false, "Write the upstream statements to the log instead of executing them")
+	startGTID  = flag.String("start-gtid", "", "GTID set to start after if no position is saved, skipping the dump")
+	startFile  = flag.String("start-file", "", "Binlog file to start from if no position is saved, skipping the dump")
+	startPos   = flag.Uint("start-pos", 0, "Binlog position in -start-file to start from")
+	startTime  = flag.String("start-time", "", "Start from the binlog at the RFC 3339 time if no position is saved, skipping the dump")
+	stopGTID   = flag.String("stop-gtid", "", "Stop once the GTID set is applied")
+	stopFile   = flag.String("stop-file", "", "Stop at the binlog file once everything before it is applied")
+	stopPos    = flag.Uint("stop-pos", 0, "Binlog position in -stop-file to stop at")
+	stopTime   = flag.String("stop-time", "", "Stop before the first transaction at or after the RFC 3339 time")
+	replay     = flag.Bool("replay", false, "Replay the binlog files given as the arguments instead of the source binlog")
 )
 
+var errInvalidConfig = errors.New("invalid config")
+
+const tracingShutdownTimeout = 5 * time.Second
+
+// commands are the subcommands of the replicator.
+// Without a subcommand the replicator starts the replication.
+var commands = map[string]func(args []string) int{
+	"check-config": checkConfigCmd,
+	"position":     positionCmd,
+	"resnapshot":   resnapshotCmd,
+	"verify":       verifyCmd,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	flag.Parse()
 	cfg, err := config.ReadFromFile(*configPath)
 	if err != nil {
 		log.Fatal().Err(err).Msgf("failed to read config")
 	}
+	if *dryRun {
+		cfg.Replication.DryRun.Enabled = true
+	}
+	if *replay {
+		cfg.Replication.Replay.Files = flag.Args()
+		if !cfg.Replication.Replay.Enabled() {
+			log.Fatal().Msg("no binlog files to replay")
+		}
+	}
+	if *startGTID != "" || *startFile != "" || *startTime != "" {
+		cfg.Replication.Start = config.PositionConfig{
+			GTID: *startGTID,
+			File: *startFile,
+			Pos:  uint32(*startPos),
+			Time: *startTime,
+		}
+		if err = cfg.Replication.Start.Validate(); err != nil {
+			log.Fatal().Err(err).Msg("invalid start position")
+		}
+	}
+	if *stopGTID != "" || *stopFile != "" || *stopTime != "" {
+		cfg.Replication.Stop = config.PositionConfig{
+			GTID: *stopGTID,
+			File: *stopFile,
+			Pos:  uint32(*stopPos),
+			Time: *stopTime,
+		}
+		if err = cfg.Replication.Stop.Validate(); err != nil {
+			log.Fatal().Err(err).Msg("invalid stop position")
+		}
+	}
 
 	logger := initLogger(cfg)
 	logger.Info().Msgf("starting replicator %s, commit %s, built at %s", version, commit, buildDate)
 
 	metrics.Init()
 
-	factory := bridge.NewEventHandlerPluginFactory(cfg.App.PluginDir)
+	shutdownTracing, err := tracing.Init(cfg.App.Tracing, version)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("could not init tracing")
+	}
+
+	factory := newHandlerFactory(cfg)
 	b, err := bridge.New(cfg, factory, logger)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("could not establish MySQL bridge")
@@ -62,7 +130,12 @@ func main() {
 
 	healthHd := initHealthHandler(cfg.App.Health, b)
 	aboutHd := initAboutHandler(version, commit, buildDate)
-	server := initHTTPServer(cfg.App.ListenAddr, healthHd, aboutHd)
+	adminToken := atomic.NewString(string(cfg.App.Admin.Token))
+	adminHd := initAdminHandler(adminToken, b, func() (*bridge.ReloadResult, error) {
+		return reloadConfig(b, adminToken)
+	})
+	statusHd := initStatusHandler(b)
+	server := initHTTPServer(cfg.App.ListenAddr, healthHd, aboutHd, statusHd, adminHd)
 	go func() {
 		logger.Info().Msgf("listening on %s", cfg.App.ListenAddr)
 
@@ -72,6 +145,7 @@ func main() {
 		}
 	}()
 
+	completed := make(chan struct{})
 	go func() {
 		errRun := b.Run()
 		if errRun != nil {
@@ -82,13 +156,38 @@ func main() {
 		if errClose != nil {
 			logger.Err(errClose).Msg("got error on closing replicator")
 		}
+
+		if b.Completed() {
+			close(completed)
+		}
+
+		// The bridge can not be started again, so the process is restarted
+		// by the supervisor and joins as standby.
+		if bridge.IsLeadershipLost(errRun) {
+			logger.Fatal().Msg("lost leadership, exiting")
+		}
+	}()
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			logger.Info().Msg("received SIGHUP, reloading config")
+
+			if _, errReload := reloadConfig(b, adminToken); errReload != nil {
+				logger.Err(errReload).Msg("failed to reload config")
+			}
+		}
 	}()
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-interrupt
-
-	logger.Info().Msgf("received system signal: %s. Shutting down replicator", sig)
+	select {
+	case sig := <-interrupt:
+		logger.Info().Msgf("received system signal: %s. Shutting down replicator", sig)
+	case <-completed:
+		logger.Info().Msg("replication is completed at the stop position. Shutting down replicator")
+	}
 
 	err = b.Close()
 	if err != nil {
@@ -99,6 +198,47 @@ func main() {
 	if err != nil {
 		logger.Err(err).Msg("failed to shutting down the HTTP server gracefully")
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+
+	err = shutdownTracing(ctx)
+	if err != nil {
+		logger.Err(err).Msg("failed to flush the traces")
+	}
+
+	// The stop position is not reached, the catch-up or the replay has to be continued.
+	if (cfg.Replication.Stop.Enabled() || cfg.Replication.Replay.Enabled()) && !b.Completed() {
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// reloadConfig reads the config file and the secrets again, applies the rules
+// and the admin token.
+func reloadConfig(b *bridge.Bridge, adminToken *atomic.String) (*bridge.ReloadResult, error) {
+	cfg, err := config.ReadFromFile(*configPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidConfig, err)
+	}
+
+	res, err := b.Reload(cfg, newHandlerFactory(cfg))
+	if err != nil {
+		return nil, err
+	}
+	adminToken.Store(string(cfg.App.Admin.Token))
+
+	return res, nil
+}
+
+// newHandlerFactory resolves the rule handlers among the built-in ones first,
+// then among the Go plugins and the handler executables.
+func newHandlerFactory(cfg *config.Config) bridge.EventHandlerFactory {
+	return bridge.NewEventHandlerChainFactory(
+		bridge.NewEventHandlerRegistryFactory(),
+		bridge.NewEventHandlerPluginFactory(cfg.App.PluginDir),
+		bridge.NewEventHandlerProcessFactory(cfg.App.PluginDir, cfg.App.PluginCallTimeout),
+	)
 }
 
 func initLogger(cfg *config.Config) zerolog.Logger {
@@ -163,7 +303,7 @@ func newRollingLogFile(cfg *config.Logging) (io.Writer, error) {
 	}, nil
 }
 
-func initHTTPServer(addr string, healthHd, aboutHd http.Handler) *http.Server {
+func initHTTPServer(addr string, healthHd, aboutHd, statusHd, adminHd http.Handler) *http.Server {
 	server := &http.Server{
 		Addr:         addr,
 		ReadTimeout:  5 * time.Second,
@@ -173,6 +313,8 @@ func initHTTPServer(addr string, healthHd, aboutHd http.Handler) *http.Server {
 	http.Handle("/metrics", promhttp.Handler())
 	http.Handle("/health", healthHd)
 	http.Handle("/about", aboutHd)
+	http.Handle("/status", statusHd)
+	http.Handle("/admin/", adminHd)
 
 	return server
 }
@@ -180,10 +322,23 @@ func initHTTPServer(addr string, healthHd, aboutHd http.Handler) *http.Server {
 func initHealthHandler(cfg config.Health, b *bridge.Bridge) http.Handler {
 	sbm := uint32(cfg.SecondsBehindMaster)
 
-	return healthcheck.Handler(
+	return withRole(b, healthcheck.Handler(
 		healthcheck.WithChecker(
 			"lag", healthcheck.CheckerFunc(
 				func(ctx context.Context) error {
+					// The standby is healthy while it waits for the lock.
+					if b.Role() == bridge.RoleStandby {
+						return nil
+					}
+
+					if lag, ok := b.HeartbeatLag(); ok {
+						if lag > time.Duration(sbm)*time.Second {
+							return fmt.Errorf("replication lag too big: %s", lag.Round(time.Millisecond))
+						}
+
+						return nil
+					}
+
 					cur := b.Delay()
 					if cur > sbm {
 						return fmt.Errorf("replication lag too big: %d", cur)
@@ -197,6 +352,10 @@ func initHealthHandler(cfg config.Health, b *bridge.Bridge) http.Handler {
 		healthcheck.WithChecker(
 			"state", healthcheck.CheckerFunc(
 				func(ctx context.Context) error {
+					if b.Role() == bridge.RoleStandby {
+						return nil
+					}
+
 					dumping := b.Dumping()
 					if dumping {
 						return errors.New("replicator has not yet finished dump process")
@@ -211,7 +370,34 @@ func initHealthHandler(cfg config.Health, b *bridge.Bridge) http.Handler {
 				},
 			),
 		),
-	)
+	))
+}
+
+// withRole adds the HA role of the replicator to the health check response.
+func withRole(b *bridge.Bridge, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := b.Role()
+		if role == "" {
+			h.ServeHTTP(w, r)
+
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+
+		resp := make(map[string]interface{})
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+		resp["role"] = role
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(rec.Code)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
 }
 
 func initAboutHandler(version, commit, buildDate string) http.Handler {
@@ -239,3 +425,17 @@ func initAboutHandler(version, commit, buildDate string) http.Handler {
 		_, _ = w.Write(aboutStr)
 	})
 }
+
+func initStatusHandler(b *bridge.Bridge) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(b.Status())
+	})
+}
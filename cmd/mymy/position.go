@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/city-mobil/go-mymy/internal/bridge"
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+const positionUsage = `usage: mymy position show|set|reset -config <path> [options]
+
+set and reset refuse to run while the replicator answers on app.listen_addr or holds the HA lock.
+Only the HA lock proves the replicator is stopped: with the state file or with app.ha disabled
+they refuse to run without -force, stop the replicator first.`
+
+// positionCmd shows, sets or resets the saved position of the stopped replicator.
+func positionCmd(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, positionUsage)
+
+		return 2
+	}
+
+	action := args[0]
+	fs := flag.NewFlagSet("position "+action, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\n\noptions:\n", positionUsage)
+		fs.PrintDefaults()
+	}
+	cfgPath := fs.String("config", "", "Config file path")
+	asJSON := fs.Bool("json", false, "Print the position as JSON")
+	gtid := fs.String("gtid", "", "GTID set to continue after")
+	file := fs.String("file", "", "Binlog file to continue from")
+	pos := fs.Uint("pos", 4, "Binlog position to continue from")
+	current := fs.Bool("current", false, "Continue from the current source position")
+	force := fs.Bool("force", false, "Change the position even if the replicator answers on app.listen_addr or there is no HA lock to check it is stopped")
+	_ = fs.Parse(args[1:])
+
+	switch action {
+	case "show", "set", "reset":
+	default:
+		fmt.Fprintln(os.Stderr, positionUsage)
+
+		return 2
+	}
+
+	cfg, err := config.ReadFromFile(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+
+		return 2
+	}
+
+	if action != "show" && !*force && replicatorRunning(cfg) {
+		fmt.Fprintf(os.Stderr, "replicator is running on %s, stop it first or use -force\n", cfg.App.ListenAddr)
+
+		return 2
+	}
+
+	m, err := bridge.NewPositionManager(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open position: %v\n", err)
+
+		return 2
+	}
+	defer func() {
+		_ = m.Close()
+	}()
+	m.Force = *force
+
+	ctx := context.Background()
+
+	var info *bridge.PositionInfo
+	switch action {
+	case "show":
+		info, err = m.Show(ctx)
+	case "set":
+		info, err = m.Set(ctx, bridge.PositionTarget{
+			GTID:    *gtid,
+			File:    *file,
+			Pos:     uint32(*pos),
+			Current: *current,
+		})
+	case "reset":
+		err = m.Reset(ctx)
+		info = &bridge.PositionInfo{GTIDMode: cfg.Replication.GTIDMode, Empty: true}
+	}
+	if errors.Is(err, bridge.ErrStopNotChecked) {
+		fmt.Fprintf(os.Stderr, "failed to %s position: there is no HA lock to check the replicator is stopped, "+
+			"stop it first and use -force\n", action)
+
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to %s position: %v\n", action, err)
+
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(info)
+	} else {
+		printPosition(info)
+	}
+
+	if info.Error != "" {
+		return 1
+	}
+
+	return 0
+}
+
+// replicatorRunning returns true if the replicator of the config answers on the HTTP address.
+func replicatorRunning(cfg *config.Config) bool {
+	client, err := newAdminClient(&cfg.App, "")
+	if err != nil {
+		return false
+	}
+
+	var st bridge.Status
+	if err = client.do(http.MethodGet, "/status", nil, &st); err != nil {
+		return false
+	}
+
+	return st.Phase != bridge.PhaseStopped
+}
+
+func printPosition(info *bridge.PositionInfo) {
+	mode := "binlog file position"
+	if info.GTIDMode {
+		mode = "gtid"
+	}
+	fmt.Printf("mode: %s\n", mode)
+
+	if info.Empty {
+		fmt.Println("position: none, the replicator starts with the dump")
+
+		return
+	}
+
+	if info.GTID != "" {
+		fmt.Printf("gtid: %s\n", info.GTID)
+	}
+	if info.File != "" {
+		fmt.Printf("file: %s, pos: %d\n", info.File, info.Pos)
+	}
+	if info.Error != "" {
+		fmt.Printf("error: %s\n", info.Error)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/bridge"
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+const resnapshotPollInterval = 1 * time.Second
+
+// resnapshotCmd asks the running replicator to copy a source table again.
+func resnapshotCmd(args []string) int {
+	fs := flag.NewFlagSet("resnapshot", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Config file path")
+	addr := fs.String("addr", "", "Replicator HTTP address, defaults to app.listen_addr")
+	table := fs.String("table", "", "Source table to re-snapshot")
+	wait := fs.Bool("wait", false, "Wait until the re-snapshot is finished")
+	_ = fs.Parse(args)
+
+	if *table == "" {
+		fmt.Fprintln(os.Stderr, "table is required")
+
+		return 2
+	}
+
+	cfg, err := config.ReadFromFile(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+
+		return 1
+	}
+
+	client, err := newAdminClient(&cfg.App, *addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid replicator address: %v\n", err)
+
+		return 1
+	}
+
+	path := "/admin/resnapshot?table=" + url.QueryEscape(*table)
+	if err = client.do(http.MethodPost, path, nil, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start re-snapshot: %v\n", err)
+
+		return 1
+	}
+
+	fmt.Printf("re-snapshot of %s started\n", *table)
+	if !*wait {
+		return 0
+	}
+
+	ticker := time.NewTicker(resnapshotPollInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+
+		var list []bridge.SnapshotStatus
+		if err = client.do(http.MethodGet, "/admin/resnapshot", nil, &list); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to get re-snapshot status: %v\n", err)
+
+			return 1
+		}
+
+		for _, status := range list {
+			if status.Table != *table || status.State == bridge.SnapshotRunning {
+				continue
+			}
+
+			if status.State == bridge.SnapshotFailed {
+				fmt.Fprintf(os.Stderr, "re-snapshot of %s failed: %s\n", *table, status.Error)
+
+				return 1
+			}
+
+			fmt.Printf("re-snapshot of %s finished, rows: %d\n", *table, status.Rows)
+
+			return 0
+		}
+	}
+}
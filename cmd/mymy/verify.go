@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog"
+
+	"github.com/city-mobil/go-mymy/internal/bridge"
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+// verifyCmd compares the rule's source table with the upstream rows and
+// optionally repairs the found mismatches.
+func verifyCmd(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	cfgPath := fs.String("config", "", "Config file path")
+	table := fs.String("table", "", "Source table to verify")
+	repair := fs.Bool("repair", false, "Repair missing, extra and differing upstream rows")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	upstreamTables := fs.String("upstream-tables", "", "Comma-separated upstream tables to search for extra rows")
+	_ = fs.Parse(args)
+
+	if *table == "" {
+		fmt.Fprintln(os.Stderr, "table is required")
+
+		return 2
+	}
+
+	cfg, err := config.ReadFromFile(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+
+		return 2
+	}
+
+	logger := zerolog.New(os.Stderr).Level(zerolog.WarnLevel).With().Timestamp().Logger()
+
+	factory := newHandlerFactory(cfg)
+	v, err := bridge.NewVerifier(cfg, factory, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create verifier: %v\n", err)
+
+		return 2
+	}
+	defer func() {
+		_ = v.Close()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	opts := bridge.VerifyOptions{Repair: *repair}
+	if *upstreamTables != "" {
+		opts.UpstreamTables = strings.Split(*upstreamTables, ",")
+	}
+
+	report, err := v.Verify(ctx, *table, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to verify %s: %v\n", *table, err)
+
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printVerifyReport(report)
+	}
+
+	if !report.Consistent() {
+		return 1
+	}
+
+	return 0
+}
+
+func printVerifyReport(r *bridge.VerifyReport) {
+	fmt.Printf("table: %s\n", r.Table)
+	fmt.Printf("source rows: %d, checked: %d\n", r.SourceRows, r.Checked)
+	fmt.Printf("missing: %d, extra: %d, differing: %d, repaired: %d\n", r.Missing, r.Extra, r.Differing, r.Repaired)
+
+	if len(r.Unchecked) > 0 {
+		fmt.Printf("extra rows are not checked in: %s\n", strings.Join(r.Unchecked, ", "))
+	}
+
+	for _, diff := range r.Diffs {
+		line := fmt.Sprintf("%s %s %v", diff.Kind, diff.Table, diff.Key)
+		if len(diff.Columns) > 0 {
+			line += " columns: " + strings.Join(diff.Columns, ", ")
+		}
+		fmt.Println(line)
+	}
+}
@@ -8,3 +8,15 @@ CREATE TABLE users
     name     varchar(50) default '' not null,
     email    varchar(254)           not null
 ) charset = utf8;
+
+CREATE TABLE mymy_watermark
+(
+    id    tinyint unsigned primary key,
+    value varchar(64) not null
+) charset = utf8;
+
+CREATE TABLE mymy_heartbeat
+(
+    id int unsigned primary key,
+    ts bigint       not null
+);
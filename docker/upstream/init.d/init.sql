@@ -7,3 +7,9 @@ CREATE TABLE clients
     name  varchar(50) default '' not null,
     email varchar(254)           not null
 ) charset = utf8;
+
+CREATE TABLE mymy_state
+(
+    name     varchar(64) primary key,
+    position text not null
+);
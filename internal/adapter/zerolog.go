@@ -2,11 +2,15 @@ package adapter
 
 import (
 	"bytes"
+	"regexp"
 	"strings"
 
 	"github.com/rs/zerolog"
 )
 
+// passwordArg matches the password passed to mysqldump, which is logged with its arguments.
+var passwordArg = regexp.MustCompile(`(--password=)\S*`)
+
 // ZeroLogHandler is a handler to redirect siddontang/go-log messages to zerolog.
 type ZeroLogHandler struct {
 	logger zerolog.Logger
@@ -20,7 +24,7 @@ func NewZeroLogHandler(logger zerolog.Logger) *ZeroLogHandler {
 
 func (h *ZeroLogHandler) Write(p []byte) (n int, err error) {
 	level, msg := parseLevelAndMsg(p)
-	h.logger.WithLevel(level).Msg(msg)
+	h.logger.WithLevel(level).Msg(redact(msg))
 
 	return len(p), nil
 }
@@ -48,3 +52,7 @@ func parseLevelAndMsg(p []byte) (level zerolog.Level, msg string) {
 
 	return level, strings.TrimSpace(string(p[end+1:]))
 }
+
+func redact(msg string) string {
+	return passwordArg.ReplaceAllString(msg, "${1}******")
+}
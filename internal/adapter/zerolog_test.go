@@ -61,3 +61,11 @@ func Test_parseLevelAndMsg(t *testing.T) {
 		})
 	}
 }
+
+func Test_redact(t *testing.T) {
+	msg := "exec mysqldump with [--host=127.0.0.1 --port=3306 --user=repl --password=s3cr=t --master-data]"
+	want := "exec mysqldump with [--host=127.0.0.1 --port=3306 --user=repl --password=****** --master-data]"
+	assert.Equal(t, want, redact(msg))
+
+	assert.Equal(t, "dump MySQL and parse OK", redact("dump MySQL and parse OK"))
+}
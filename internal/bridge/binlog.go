@@ -0,0 +1,352 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/siddontang/go-mysql/mysql"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+// Binlog event types carrying the GTIDs.
+const (
+	eventGTID          = "Gtid"
+	eventPreviousGTIDs = "Previous_gtids"
+	eventGTIDList      = "Gtid_list"
+)
+
+// The first events of the binlog file to look for the previous GTIDs in.
+const binlogHeaderEvents = 10
+
+var ErrBinlogPurged = errors.New("binlog is purged from the source")
+
+// binlogInspector reads the source binlog to convert and validate the positions.
+type binlogInspector struct {
+	source   *client.SQLClient
+	flavor   string
+	gtidMode bool
+}
+
+func newBinlogInspector(source *client.SQLClient, cfg *config.Config) *binlogInspector {
+	return &binlogInspector{
+		source:   source,
+		flavor:   cfg.Replication.SourceOpts.Flavor,
+		gtidMode: cfg.Replication.GTIDMode,
+	}
+}
+
+// newSourceClient opens the single connection to the source database.
+func newSourceClient(opts config.SourceConfig) (*client.SQLClient, error) {
+	tlsCfg, err := sourceTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(&client.Config{
+		Addr:         opts.Addr,
+		User:         opts.User,
+		Password:     string(opts.Password),
+		Database:     opts.Database,
+		Charset:      opts.Charset,
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+		TLS:          tlsCfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to source: %w", err)
+	}
+
+	return c, nil
+}
+
+func (i *binlogInspector) masterStatus(ctx context.Context) (file string, pos uint32, gtid string, err error) {
+	rows, err := queryRows(ctx, i.source, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", 0, "", err
+	}
+	if len(rows) == 0 {
+		return "", 0, "", errors.New("binary log is disabled on the source")
+	}
+
+	var p uint64
+	if _, err = fmt.Sscan(rows[0]["Position"], &p); err != nil {
+		return "", 0, "", err
+	}
+
+	if i.flavor == config.FlavorMariaDB {
+		err = i.source.QueryRow(ctx, "SELECT @@gtid_binlog_pos").Scan(&gtid)
+	} else {
+		gtid = strings.ReplaceAll(rows[0]["Executed_Gtid_Set"], "\n", "")
+	}
+
+	return rows[0]["File"], uint32(p), gtid, err
+}
+
+// checkFilePos checks the binlog file exists and the position is the start of an event.
+func (i *binlogInspector) checkFilePos(ctx context.Context, file string, pos uint32) error {
+	size, ok, err := i.binlogSize(ctx, file)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrBinlogPurged, file)
+	}
+	if pos < 4 || uint64(pos) > size {
+		return fmt.Errorf("position %d is out of binlog file %s of %d bytes", pos, file, size)
+	}
+	if uint64(pos) == size {
+		return nil
+	}
+
+	found := false
+	err = i.scanEvents(ctx, file, 0, func(e binlogEvent) bool {
+		found = e.pos == uint64(pos)
+
+		return e.pos < uint64(pos)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("position %d is not the start of an event in %s", pos, file)
+	}
+
+	return nil
+}
+
+// filePosToGTID returns the GTID set executed before the position.
+func (i *binlogInspector) filePosToGTID(ctx context.Context, file string, pos uint32) (mysql.GTIDSet, error) {
+	set, err := mysql.ParseGTIDSet(i.flavor, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var errEvent error
+	err = i.scanEvents(ctx, file, 0, func(e binlogEvent) bool {
+		// The header events are before any position, but the file may be started from.
+		if e.eventType == eventGTID && e.pos >= uint64(pos) {
+			return false
+		}
+
+		switch e.eventType {
+		case eventPreviousGTIDs, eventGTIDList:
+			var prev mysql.GTIDSet
+			prev, errEvent = mysql.ParseGTIDSet(i.flavor, previousGTIDs(e.info))
+			if errEvent == nil {
+				set = prev
+			}
+		case eventGTID:
+			if gtid := eventGTIDValue(i.flavor, e.info); gtid != "" {
+				errEvent = set.Update(gtid)
+			}
+		}
+
+		return errEvent == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return set, errEvent
+}
+
+// gtidToFilePos returns the position of the first transaction which is not in the set.
+func (i *binlogInspector) gtidToFilePos(ctx context.Context, set mysql.GTIDSet) (string, uint32, error) {
+	files, err := queryRows(ctx, i.source, "SHOW BINARY LOGS")
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The last file the set contains all the previous transactions of.
+	for n := len(files) - 1; n >= 0; n-- {
+		file := files[n]["Log_name"]
+
+		prev, errPrev := i.previousGTIDs(ctx, file)
+		if errPrev != nil {
+			return "", 0, errPrev
+		}
+		if !set.Contain(prev) {
+			continue
+		}
+
+		var size uint64
+		if _, err = fmt.Sscan(files[n]["File_size"], &size); err != nil {
+			return "", 0, err
+		}
+		pos := size
+
+		err = i.scanEvents(ctx, file, 0, func(e binlogEvent) bool {
+			if e.eventType != eventGTID {
+				return true
+			}
+
+			gtid, errParse := mysql.ParseGTIDSet(i.flavor, eventGTIDValue(i.flavor, e.info))
+			if errParse != nil || set.Contain(gtid) {
+				return true
+			}
+			pos = e.pos
+
+			return false
+		})
+		if err != nil {
+			return "", 0, err
+		}
+
+		return file, uint32(pos), nil
+	}
+
+	return "", 0, fmt.Errorf("%w: no binlog file starts within GTID set %s", ErrBinlogPurged, set)
+}
+
+func (i *binlogInspector) previousGTIDs(ctx context.Context, file string) (mysql.GTIDSet, error) {
+	var info string
+	err := i.scanEvents(ctx, file, binlogHeaderEvents, func(e binlogEvent) bool {
+		if e.eventType == eventPreviousGTIDs || e.eventType == eventGTIDList {
+			info = previousGTIDs(e.info)
+
+			return false
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.ParseGTIDSet(i.flavor, info)
+}
+
+func (i *binlogInspector) binlogSize(ctx context.Context, file string) (uint64, bool, error) {
+	files, err := queryRows(ctx, i.source, "SHOW BINARY LOGS")
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, f := range files {
+		if f["Log_name"] != file {
+			continue
+		}
+
+		var size uint64
+		_, err = fmt.Sscan(f["File_size"], &size)
+
+		return size, true, err
+	}
+
+	return 0, false, nil
+}
+
+type binlogEvent struct {
+	pos       uint64
+	eventType string
+	info      string
+}
+
+// scanEvents calls fn for the events of the binlog file until it returns false.
+// Zero limit reads the whole file.
+func (i *binlogInspector) scanEvents(ctx context.Context, file string, limit int, fn func(e binlogEvent) bool) error {
+	query := "SHOW BINLOG EVENTS IN ?"
+	args := []interface{}{file}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := i.source.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var (
+			name, eventType    string
+			pos, serverID, end uint64
+			info               sql.NullString
+		)
+		if err = rows.Scan(&name, &pos, &eventType, &serverID, &end, &info); err != nil {
+			return err
+		}
+
+		if !fn(binlogEvent{pos: pos, eventType: eventType, info: info.String}) {
+			break
+		}
+	}
+
+	return rows.Err()
+}
+
+// previousGTIDs returns the GTID set from the description of the Previous_gtids
+// or the Gtid_list event, e.g. `uuid:1-5,\nuuid:1-3` or `[0-1-100]`.
+func previousGTIDs(info string) string {
+	info = strings.TrimPrefix(strings.TrimSuffix(strings.TrimSpace(info), "]"), "[")
+
+	return strings.ReplaceAll(info, "\n", "")
+}
+
+// eventGTIDValue returns the GTID from the description of the Gtid event,
+// e.g. `SET @@SESSION.GTID_NEXT= 'uuid:6'` or `BEGIN GTID 0-1-101`.
+func eventGTIDValue(flavor, info string) string {
+	if flavor == config.FlavorMariaDB {
+		fields := strings.Fields(info)
+		for i, field := range fields {
+			if field == "GTID" && i+1 < len(fields) {
+				return fields[i+1]
+			}
+		}
+
+		return ""
+	}
+
+	start := strings.IndexByte(info, '\'')
+	end := strings.LastIndexByte(info, '\'')
+	if start < 0 || end <= start {
+		return ""
+	}
+
+	return info[start+1 : end]
+}
+
+// queryRows reads the rows of the statement with the variable columns, e.g. SHOW statements.
+func queryRows(ctx context.Context, c *client.SQLClient, query string, args ...interface{}) ([]map[string]string, error) {
+	rows, err := c.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []map[string]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		list = append(list, row)
+	}
+
+	return list, rows.Err()
+}
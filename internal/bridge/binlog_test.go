@@ -0,0 +1,86 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+func TestPreviousGTIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		info string
+		want string
+	}{
+		{
+			name: "MySQL",
+			info: "07812e7f-5dad-11e6-b5b3-525400d2e382:1-5,\n0d4becf8-5970-11ea-819f-1c34da0723b1:1-90",
+			want: "07812e7f-5dad-11e6-b5b3-525400d2e382:1-5,0d4becf8-5970-11ea-819f-1c34da0723b1:1-90",
+		},
+		{
+			name: "MySQLEmpty",
+			info: "",
+			want: "",
+		},
+		{
+			name: "MariaDB",
+			info: "[0-1-100,1-2-5]",
+			want: "0-1-100,1-2-5",
+		},
+		{
+			name: "MariaDBEmpty",
+			info: "[]",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, previousGTIDs(tt.info))
+		})
+	}
+}
+
+func TestEventGTIDValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		flavor string
+		info   string
+		want   string
+	}{
+		{
+			name:   "MySQL",
+			flavor: config.FlavorMySQL,
+			info:   "SET @@SESSION.GTID_NEXT= '07812e7f-5dad-11e6-b5b3-525400d2e382:6'",
+			want:   "07812e7f-5dad-11e6-b5b3-525400d2e382:6",
+		},
+		{
+			name:   "MySQLInvalid",
+			flavor: config.FlavorMySQL,
+			info:   "BEGIN",
+			want:   "",
+		},
+		{
+			name:   "MariaDBTransaction",
+			flavor: config.FlavorMariaDB,
+			info:   "BEGIN GTID 0-1-101",
+			want:   "0-1-101",
+		},
+		{
+			name:   "MariaDBDDL",
+			flavor: config.FlavorMariaDB,
+			info:   "GTID 0-1-102 cid=15",
+			want:   "0-1-102",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, eventGTIDValue(tt.flavor, tt.info))
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/util"
+)
+
+// Statuses of the config checks.
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+const checkConnectTimeout = 5 * time.Second
+
+// CheckResult is a result of the single config check.
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Hint tells how to fix the failed check.
+	Hint string `json:"hint,omitempty"`
+}
+
+// CheckReport is a result of the config check.
+type CheckReport struct {
+	Results []CheckResult `json:"results"`
+}
+
+// Failed returns true if any check failed. The warnings are not failures.
+func (r *CheckReport) Failed() bool {
+	for _, res := range r.Results {
+		if res.Status == CheckFail {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *CheckReport) ok(name, msg string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: CheckOK, Message: msg})
+}
+
+func (r *CheckReport) warn(name, msg, hint string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: CheckWarn, Message: msg, Hint: hint})
+}
+
+func (r *CheckReport) fail(name, msg, hint string) {
+	r.Results = append(r.Results, CheckResult{Name: name, Status: CheckFail, Message: msg, Hint: hint})
+}
+
+// CheckConfig checks that the replicator is able to run with the config:
+// the handlers are resolved, both databases are reachable, the source
+// binlog is suitable for the replication and the users have the required grants.
+func CheckConfig(ctx context.Context, cfg *config.Config, ehFactory EventHandlerFactory) *CheckReport {
+	report := &CheckReport{}
+
+	checkHandlers(report, cfg, ehFactory)
+	checkDumpExec(report, cfg)
+	checkSource(ctx, report, cfg)
+	if cfg.Replication.Sink.Type == config.SinkFile {
+		checkFileSink(report, cfg)
+	} else {
+		checkUpstream(ctx, report, cfg)
+	}
+
+	return report
+}
+
+func checkHandlers(report *CheckReport, cfg *config.Config, ehFactory EventHandlerFactory) {
+	for _, rule := range cfg.Replication.Rules {
+		name := "handler " + rule.Source.Table
+		pluginCfg := rule.Upstream.Plugin
+
+		h, err := ehFactory.New(pluginCfg.Name, pluginCfg.Config)
+		if err != nil {
+			report.fail(name, err.Error(), fmt.Sprintf("check the plugin name %q, the plugin_dir and the plugin config", pluginCfg.Name))
+
+			continue
+		}
+		if c, ok := h.(io.Closer); ok {
+			_ = c.Close()
+		}
+
+		report.ok(name, pluginCfg.Name)
+	}
+}
+
+func checkDumpExec(report *CheckReport, cfg *config.Config) {
+	const name = "mysqldump"
+
+	path := cfg.Replication.SourceOpts.Dump.ExecPath
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			report.fail(name, err.Error(), "fix replication.source.dump.exec_path")
+
+			return
+		}
+
+		report.ok(name, path)
+
+		return
+	}
+
+	// The dump is not needed if the replication continues from the known position.
+	const hint = "install mysqldump or set replication.source.dump.exec_path"
+	if cfg.Replication.Start.Enabled() {
+		report.warn(name, "mysqldump is not found, the replication starts from replication.start", hint)
+
+		return
+	}
+
+	saved, err := hasSavedPosition(cfg)
+	switch {
+	case err != nil:
+		report.fail(name, fmt.Sprintf("mysqldump is not found and the saved position is not read: %v", err), hint)
+	case saved:
+		report.warn(name, "mysqldump is not found, the replication continues from the saved position", hint)
+	default:
+		report.fail(name, "mysqldump is not found and no position is saved, the initial dump would be skipped",
+			hint+", or set replication.start")
+	}
+}
+
+// hasSavedPosition returns true if the replicator has the position to continue from.
+func hasSavedPosition(cfg *config.Config) (bool, error) {
+	var upstream *client.SQLClient
+	if cfg.App.State.Table != "" {
+		c, err := newUpstreamClient(cfg)
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			_ = c.Close()
+		}()
+		upstream = c
+	}
+
+	pos, err := readPosition(cfg, upstream)
+	if err != nil {
+		return false, err
+	}
+
+	return !isEmptyPosition(pos), nil
+}
+
+// checkFileSink checks the replicator can create the files in the sink directory.
+func checkFileSink(report *CheckReport, cfg *config.Config) {
+	const name = "sink dir"
+
+	dir := util.AbsPath(cfg.Replication.Sink.File.Dir)
+	f, err := ioutil.TempFile(dir, ".mymy-check-*")
+	if err != nil {
+		report.fail(name, err.Error(), "create the directory writable by the replicator or fix replication.sink.file.dir")
+
+		return
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+
+	report.ok(name, dir)
+}
+
+func checkSource(ctx context.Context, report *CheckReport, cfg *config.Config) {
+	opts := cfg.Replication.SourceOpts
+
+	tlsCfg, err := sourceTLSConfig(opts)
+	if err != nil {
+		report.fail("source tls", err.Error(), "fix replication.source.tls")
+
+		return
+	}
+
+	c, err := client.New(&client.Config{
+		Addr:           opts.Addr,
+		User:           opts.User,
+		Password:       string(opts.Password),
+		Database:       opts.Database,
+		Charset:        opts.Charset,
+		MaxOpenConns:   1,
+		MaxIdleConns:   1,
+		ConnectTimeout: checkConnectTimeout,
+		TLS:            tlsCfg,
+	})
+	if err != nil {
+		report.fail("source connection", err.Error(), "check replication.source addr, user, password and database")
+
+		return
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+	report.ok("source connection", opts.Addr)
+
+	vars := make(map[string]string)
+	for _, v := range []string{"log_bin", "binlog_format", "binlog_row_image", "gtid_mode"} {
+		var value string
+		// The variable may not exist, e.g. gtid_mode in MariaDB.
+		if err = c.QueryRow(ctx, "SELECT @@"+v).Scan(&value); err == nil {
+			vars[v] = value
+		}
+	}
+	checkBinlogVars(report, vars, cfg.Replication.GTIDMode, opts.Flavor)
+
+	grants, err := showGrants(ctx, c)
+	if err != nil {
+		report.warn("source grants", err.Error(), "")
+	} else if missing := missingGrants(grants, "REPLICATION SLAVE", "REPLICATION CLIENT"); len(missing) > 0 {
+		report.warn("source grants", "missing "+strings.Join(missing, ", "),
+			fmt.Sprintf("GRANT %s ON *.* TO '%s'", strings.Join(missing, ", "), opts.User))
+	} else {
+		report.ok("source grants", "")
+	}
+
+	tables, err := listTables(ctx, c, opts.Database)
+	if err != nil {
+		report.fail("source tables", err.Error(), "grant SELECT on the source database")
+
+		return
+	}
+
+	for _, rule := range cfg.Replication.Rules {
+		checkTable(report, tables, "source table", opts.Database, rule.Source.Table)
+	}
+	if table := opts.Snapshot.WatermarkTable; table != "" {
+		checkTable(report, tables, "watermark table", opts.Database, table)
+	}
+	if table := opts.Heartbeat.Table; table != "" {
+		checkTable(report, tables, "heartbeat table", opts.Database, table)
+	}
+}
+
+// checkBinlogVars checks the source server variables required by the row-based replication.
+func checkBinlogVars(report *CheckReport, vars map[string]string, gtidMode bool, flavor string) {
+	if logBin := vars["log_bin"]; logBin != "1" && !strings.EqualFold(logBin, "ON") {
+		report.fail("log_bin", "binary log is disabled", "enable log_bin on the source server")
+	} else {
+		report.ok("log_bin", "ON")
+	}
+
+	if format := vars["binlog_format"]; !strings.EqualFold(format, "ROW") {
+		report.fail("binlog_format", format, "SET GLOBAL binlog_format = 'ROW'")
+	} else {
+		report.ok("binlog_format", format)
+	}
+
+	// binlog_row_image appeared in MySQL 5.6, the older servers log the full rows.
+	if image, ok := vars["binlog_row_image"]; ok && !strings.EqualFold(image, "FULL") {
+		report.fail("binlog_row_image", image, "SET GLOBAL binlog_row_image = 'FULL'")
+	} else {
+		report.ok("binlog_row_image", "FULL")
+	}
+
+	if !gtidMode || flavor == config.FlavorMariaDB {
+		return
+	}
+
+	if mode := vars["gtid_mode"]; !strings.EqualFold(mode, "ON") {
+		report.fail("gtid_mode", mode, "enable gtid_mode on the source server or set replication.gtid_mode to false")
+	} else {
+		report.ok("gtid_mode", mode)
+	}
+}
+
+func checkUpstream(ctx context.Context, report *CheckReport, cfg *config.Config) {
+	opts := cfg.Replication.UpstreamOpts
+
+	c, err := newUpstreamClient(cfg)
+	if err != nil {
+		report.fail("upstream connection", err.Error(), "check replication.upstream addr, user, password, database and tls")
+
+		return
+	}
+	defer func() {
+		_ = c.Close()
+	}()
+	report.ok("upstream connection", opts.Addr)
+
+	if cfg.Replication.SourceOpts.Dump.LoadInFileEnabled {
+		var localInfile string
+		err = c.QueryRow(ctx, "SELECT @@local_infile").Scan(&localInfile)
+		switch {
+		case err != nil:
+			report.fail("local_infile", err.Error(), "")
+		case localInfile != "1" && !strings.EqualFold(localInfile, "ON"):
+			report.fail("local_infile", "LOAD DATA LOCAL INFILE is disabled",
+				"SET GLOBAL local_infile = 1 or set replication.source.dump.load_in_file_enabled to false")
+		default:
+			report.ok("local_infile", "ON")
+		}
+	}
+
+	grants, err := showGrants(ctx, c)
+	if err != nil {
+		report.warn("upstream grants", err.Error(), "")
+	} else if missing := missingGrants(grants, "SELECT", "INSERT", "UPDATE", "DELETE"); len(missing) > 0 {
+		report.warn("upstream grants", "missing "+strings.Join(missing, ", "),
+			fmt.Sprintf("GRANT %s ON %s.* TO '%s'", strings.Join(missing, ", "), quoteName(opts.Database), opts.User))
+	} else {
+		report.ok("upstream grants", "")
+	}
+
+	if table := cfg.App.State.Table; table != "" {
+		tables, errList := listTables(ctx, c, opts.Database)
+		if errList != nil {
+			report.fail("state table", errList.Error(), "")
+
+			return
+		}
+		checkTable(report, tables, "state table", opts.Database, table)
+	}
+}
+
+func checkTable(report *CheckReport, tables map[string]struct{}, kind, database, table string) {
+	name := kind + " " + table
+	if _, ok := tables[table]; !ok {
+		report.fail(name, fmt.Sprintf("table %s.%s does not exist or is not accessible", database, table),
+			"create the table or grant SELECT on it")
+
+		return
+	}
+
+	report.ok(name, "")
+}
+
+func listTables(ctx context.Context, c *client.SQLClient, database string) (map[string]struct{}, error) {
+	rows, err := c.Query(ctx, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?", database)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	tables := make(map[string]struct{})
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables[table] = struct{}{}
+	}
+
+	return tables, rows.Err()
+}
+
+func showGrants(ctx context.Context, c *client.SQLClient) ([]string, error) {
+	rows, err := c.Query(ctx, "SHOW GRANTS FOR CURRENT_USER()")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var grants []string
+	for rows.Next() {
+		var grant string
+		if err = rows.Scan(&grant); err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+
+	return grants, rows.Err()
+}
+
+// missingGrants returns the privileges which are not found in the grants.
+// The grants through the roles are not resolved.
+func missingGrants(grants []string, privileges ...string) []string {
+	all := strings.ToUpper(strings.Join(grants, "\n"))
+	if strings.Contains(all, "ALL PRIVILEGES") {
+		return nil
+	}
+
+	var missing []string
+	for _, p := range privileges {
+		if !strings.Contains(all, p) {
+			missing = append(missing, p)
+		}
+	}
+
+	return missing
+}
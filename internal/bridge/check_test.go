@@ -0,0 +1,227 @@
+package bridge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+func TestCheckBinlogVars(t *testing.T) {
+	valid := map[string]string{
+		"log_bin":          "1",
+		"binlog_format":    "ROW",
+		"binlog_row_image": "FULL",
+		"gtid_mode":        "ON",
+	}
+
+	with := func(name, value string) map[string]string {
+		vars := make(map[string]string, len(valid))
+		for k, v := range valid {
+			vars[k] = v
+		}
+		if value == "" {
+			delete(vars, name)
+		} else {
+			vars[name] = value
+		}
+
+		return vars
+	}
+
+	tests := []struct {
+		name     string
+		vars     map[string]string
+		gtidMode bool
+		flavor   string
+		failed   []string
+	}{
+		{
+			name:     "Valid",
+			vars:     valid,
+			gtidMode: true,
+			flavor:   config.FlavorMySQL,
+		},
+		{
+			name:   "NoBinlog",
+			vars:   with("log_bin", "0"),
+			flavor: config.FlavorMySQL,
+			failed: []string{"log_bin"},
+		},
+		{
+			name:   "StatementFormat",
+			vars:   with("binlog_format", "MIXED"),
+			flavor: config.FlavorMySQL,
+			failed: []string{"binlog_format"},
+		},
+		{
+			name:   "MinimalImage",
+			vars:   with("binlog_row_image", "MINIMAL"),
+			flavor: config.FlavorMySQL,
+			failed: []string{"binlog_row_image"},
+		},
+		{
+			name:   "NoRowImage",
+			vars:   with("binlog_row_image", ""),
+			flavor: config.FlavorMySQL,
+		},
+		{
+			name:     "GTIDOff",
+			vars:     with("gtid_mode", "OFF"),
+			gtidMode: true,
+			flavor:   config.FlavorMySQL,
+			failed:   []string{"gtid_mode"},
+		},
+		{
+			name:   "GTIDNotRequired",
+			vars:   with("gtid_mode", "OFF"),
+			flavor: config.FlavorMySQL,
+		},
+		{
+			name:     "MariaDBGTID",
+			vars:     with("gtid_mode", ""),
+			gtidMode: true,
+			flavor:   config.FlavorMariaDB,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			report := &CheckReport{}
+			checkBinlogVars(report, tt.vars, tt.gtidMode, tt.flavor)
+
+			var failed []string
+			for _, res := range report.Results {
+				if res.Status == CheckFail {
+					failed = append(failed, res.Name)
+					assert.NotEmpty(t, res.Hint)
+				}
+			}
+			assert.Equal(t, tt.failed, failed)
+			assert.Equal(t, len(tt.failed) > 0, report.Failed())
+		})
+	}
+}
+
+func TestMissingGrants(t *testing.T) {
+	tests := []struct {
+		name   string
+		grants []string
+		want   []string
+	}{
+		{
+			name:   "All",
+			grants: []string{"GRANT ALL PRIVILEGES ON *.* TO `root`@`%`"},
+		},
+		{
+			name:   "Replication",
+			grants: []string{"GRANT REPLICATION SLAVE, REPLICATION CLIENT ON *.* TO `repl`@`%`"},
+		},
+		{
+			name: "Missing",
+			grants: []string{
+				"GRANT USAGE ON *.* TO `repl`@`%`",
+				"GRANT SELECT ON `city`.* TO `repl`@`%`",
+				"grant replication slave on *.* to `repl`@`%`",
+			},
+			want: []string{"REPLICATION CLIENT"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := missingGrants(tt.grants, "REPLICATION SLAVE", "REPLICATION CLIENT")
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckHandlers(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Replication.Rules = make([]config.RuleConfig, 2)
+	cfg.Replication.Rules[0].Source.Table = "users"
+	cfg.Replication.Rules[0].Upstream.Plugin.Name = "mirror"
+	cfg.Replication.Rules[1].Source.Table = "orders"
+	cfg.Replication.Rules[1].Upstream.Plugin.Name = "unknown"
+
+	report := &CheckReport{}
+	checkHandlers(report, cfg, &namedFactory{name: "mirror"})
+
+	if assert.Len(t, report.Results, 2) {
+		assert.Equal(t, CheckOK, report.Results[0].Status)
+		assert.Equal(t, CheckFail, report.Results[1].Status)
+		assert.Equal(t, "handler orders", report.Results[1].Name)
+	}
+}
+
+func TestCheckDumpExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-check")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	saved := filepath.Join(dir, "saved.info")
+	err = ioutil.WriteFile(saved, []byte(`{"name":"mysql-bin.000001","pos":4}`), 0600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		execPath string
+		dataFile string
+		start    string
+		want     string
+	}{
+		{
+			name:     "Found",
+			execPath: saved,
+			want:     CheckOK,
+		},
+		{
+			name:     "WrongPath",
+			execPath: filepath.Join(dir, "mysqldump"),
+			want:     CheckFail,
+		},
+		{
+			name:     "NoPosition",
+			dataFile: filepath.Join(dir, "empty.info"),
+			want:     CheckFail,
+		},
+		{
+			name:     "SavedPosition",
+			dataFile: saved,
+			want:     CheckWarn,
+		},
+		{
+			name:     "StartPosition",
+			dataFile: filepath.Join(dir, "empty.info"),
+			start:    "mysql-bin.000002",
+			want:     CheckWarn,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.App.DataFile = tt.dataFile
+			cfg.Replication.SourceOpts.Flavor = config.FlavorMySQL
+			cfg.Replication.SourceOpts.Dump.ExecPath = tt.execPath
+			cfg.Replication.Start.File = tt.start
+
+			report := &CheckReport{}
+			checkDumpExec(report, cfg)
+
+			if assert.Len(t, report.Results, 1) {
+				assert.Equal(t, tt.want, report.Results[0].Status)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package bridge
 
 import (
+	"fmt"
+
 	"github.com/siddontang/go-mysql/schema"
 
 	"github.com/city-mobil/go-mymy/pkg/mymy"
@@ -49,3 +51,17 @@ func newColumnsFromPKs(table *schema.Table) []mymy.Column {
 
 	return pks
 }
+
+func newSourceInfo(table *schema.Table) (mymy.SourceInfo, error) {
+	pks := newColumnsFromPKs(table)
+	if len(pks) == 0 {
+		return mymy.SourceInfo{}, fmt.Errorf("no primary keys found, schema: %s, table: %s", table.Schema, table.Name)
+	}
+
+	return mymy.SourceInfo{
+		Schema: table.Schema,
+		Table:  table.Name,
+		PKs:    pks,
+		Cols:   newColumnsFromNonPKs(table),
+	}, nil
+}
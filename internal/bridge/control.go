@@ -0,0 +1,301 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/city-mobil/go-mymy/internal/metrics"
+	"github.com/city-mobil/go-mymy/internal/tracing"
+)
+
+var (
+	ErrNotPaused         = errors.New("replication is not paused")
+	ErrGTIDModeDisabled  = errors.New("GTID mode is disabled")
+	ErrInvalidSkipNumber = errors.New("number of events to skip must be positive")
+)
+
+// rowsEvent is the queries made by the rule handler from the binlog rows event.
+type rowsEvent struct {
+	// ctx contains the span of the event handling.
+	ctx context.Context
+	// queued is the span of waiting in the sync queue.
+	queued  trace.Span
+	queries batch
+	// applied is the number of the queries applied before the failure.
+	// The retry after resume starts with the next query.
+	applied int
+	// rows is the number of the source rows the queries are built from.
+	rows int
+	// table is a rule key of the source table.
+	table  string
+	action string
+	// gtid is the GTID of the event transaction, empty in the binlog position mode.
+	gtid string
+	// at is the time the event has been written to the binlog, zero for the dumped rows.
+	at time.Time
+	// err is the handler error. It is applied as a failed event
+	// to pause the replication on error.
+	err error
+}
+
+// dequeued ends the span of waiting in the sync queue. The retried event ends it only once.
+func (e *rowsEvent) dequeued() {
+	if e.queued != nil {
+		e.queued.End()
+		e.queued = nil
+	}
+}
+
+// context returns the context to trace applying of the event.
+func (e *rowsEvent) context() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+
+	return e.ctx
+}
+
+// pauser blocks the sync loop while the replication is paused.
+type pauser struct {
+	mu *sync.Mutex
+	// resumeCh is closed on resume, nil if the replication is not paused.
+	resumeCh chan struct{}
+	// err is the error which paused the replication.
+	err error
+}
+
+func newPauser() *pauser {
+	return &pauser{
+		mu: &sync.Mutex{},
+	}
+}
+
+func (p *pauser) pause(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumeCh == nil {
+		p.resumeCh = make(chan struct{})
+	}
+	p.err = err
+}
+
+func (p *pauser) resume() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumeCh == nil {
+		return ErrNotPaused
+	}
+
+	close(p.resumeCh)
+	p.resumeCh = nil
+	p.err = nil
+
+	return nil
+}
+
+// wait returns the channel closed on resume or nil if the replication is not paused.
+func (p *pauser) wait() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.resumeCh == nil {
+		return nil
+	}
+
+	return p.resumeCh
+}
+
+func (p *pauser) paused() (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.resumeCh != nil, p.err
+}
+
+// skipper drops the rows events requested to skip.
+type skipper struct {
+	mu     *sync.Mutex
+	events int
+	txn    bool
+	// gtid is the transaction which events are being skipped.
+	gtid string
+}
+
+func newSkipper() *skipper {
+	return &skipper{
+		mu: &sync.Mutex{},
+	}
+}
+
+func (s *skipper) skipEvents(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events += n
+}
+
+func (s *skipper) skipTransaction() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.txn = true
+}
+
+// skip returns true if the event must not be applied.
+func (s *skipper) skip(e *rowsEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.gtid != "" {
+		if e.gtid == s.gtid {
+			return true
+		}
+		s.gtid = ""
+	}
+
+	if s.txn {
+		s.txn = false
+		s.gtid = e.gtid
+
+		return true
+	}
+
+	if s.events > 0 {
+		s.events--
+
+		return true
+	}
+
+	return false
+}
+
+// Position describes the replication position.
+type Position struct {
+	GTIDMode bool `json:"gtid_mode"`
+	// Synced is the position of the last event read from the binlog.
+	Synced string `json:"synced"`
+	// Saved is the position saved to the state file.
+	Saved string `json:"saved"`
+}
+
+// Pause stops applying the events to the upstream. The events are buffered
+// until the buffer is full, then the binlog reading is blocked.
+func (b *Bridge) Pause() error {
+	if !b.Running() {
+		return ErrNotRunning
+	}
+
+	b.pauser.pause(nil)
+	b.logger.Info().Msg("replication paused")
+
+	return nil
+}
+
+// Resume continues applying the events starting with the failed one if any.
+func (b *Bridge) Resume() error {
+	if err := b.pauser.resume(); err != nil {
+		return err
+	}
+
+	b.logger.Info().Msg("replication resumed")
+
+	return nil
+}
+
+// Paused returns true and the error which paused the replication if it is paused.
+func (b *Bridge) Paused() (bool, error) {
+	return b.pauser.paused()
+}
+
+// SkipEvents drops the next n rows events including the failed one.
+func (b *Bridge) SkipEvents(n int) error {
+	if n <= 0 {
+		return ErrInvalidSkipNumber
+	}
+
+	b.skipper.skipEvents(n)
+	b.logger.Warn().Int("events", n).Msg("rows events will be skipped")
+
+	return nil
+}
+
+// SkipTransaction drops all rows events of the next transaction including the failed one.
+func (b *Bridge) SkipTransaction() error {
+	if !b.gtidMode {
+		return ErrGTIDModeDisabled
+	}
+
+	b.skipper.skipTransaction()
+	b.logger.Warn().Msg("next transaction will be skipped")
+
+	return nil
+}
+
+// Position returns the current replication position.
+func (b *Bridge) Position() Position {
+	pos := Position{
+		GTIDMode: b.gtidMode,
+	}
+
+	switch {
+	case b.replay:
+		pos.Synced = b.replayed.Load()
+	case b.gtidMode:
+		if set := b.currentCanal().SyncedGTIDSet(); set != nil {
+			pos.Synced = set.String()
+		}
+	default:
+		pos.Synced = b.currentCanal().SyncedPosition().String()
+	}
+
+	if saved := b.stateSaver.position(); saved != nil {
+		pos.Saved = saved.String()
+	}
+
+	return pos
+}
+
+// applyEvent applies the rows event unless it is skipped.
+func (b *Bridge) applyEvent(e *rowsEvent) error {
+	e.dequeued()
+
+	if b.skipper.skip(e) {
+		b.logger.Warn().
+			Str("table", e.table).
+			Str("action", e.action).
+			Str("gtid", e.gtid).
+			Msg("rows event skipped")
+
+		return nil
+	}
+
+	if e.err != nil {
+		return e.err
+	}
+
+	ctx, span := tracing.Start(e.context(), "mymy.apply",
+		tracing.TableKey.String(e.table),
+		tracing.ActionKey.String(e.action),
+		tracing.GTIDKey.String(e.gtid),
+	)
+	n, err := b.doBatch(ctx, e.queries[e.applied:], eventMeta{
+		table: e.table,
+		gtid:  e.gtid,
+		at:    e.at,
+	})
+	e.applied += n
+	if err != nil {
+		b.stats.setError(e.table, err)
+	} else if !e.at.IsZero() {
+		metrics.SetAppliedEventAge(time.Since(e.at))
+	}
+	tracing.End(span, err)
+
+	return err
+}
@@ -0,0 +1,106 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func TestSkipper(t *testing.T) {
+	events := []*rowsEvent{
+		{gtid: "uuid:1"},
+		{gtid: "uuid:2"},
+		{gtid: "uuid:2"},
+		{gtid: "uuid:3"},
+		{gtid: "uuid:4"},
+		{gtid: "uuid:5"},
+	}
+
+	s := newSkipper()
+	assert.False(t, s.skip(events[0]))
+
+	s.skipTransaction()
+	assert.True(t, s.skip(events[1]))
+	assert.True(t, s.skip(events[2]))
+	assert.False(t, s.skip(events[3]))
+
+	s.skipEvents(1)
+	assert.True(t, s.skip(events[4]))
+	assert.False(t, s.skip(events[5]))
+}
+
+func TestPauser(t *testing.T) {
+	p := newPauser()
+	assert.Nil(t, p.wait())
+	assert.Equal(t, ErrNotPaused, p.resume())
+
+	p.pause(errChunkStale)
+	resumeCh := p.wait()
+	require.NotNil(t, resumeCh)
+
+	paused, err := p.paused()
+	assert.True(t, paused)
+	assert.Equal(t, errChunkStale, err)
+
+	require.NoError(t, p.resume())
+	_, ok := <-resumeCh
+	assert.False(t, ok)
+
+	paused, err = p.paused()
+	assert.False(t, paused)
+	assert.NoError(t, err)
+}
+
+// failingSink records the written queries and fails the write of the query once.
+type failingSink struct {
+	failOn  *mymy.Query
+	written []*mymy.Query
+}
+
+func (s *failingSink) write(_ context.Context, query *mymy.Query, _ eventMeta) error {
+	if query == s.failOn {
+		s.failOn = nil
+
+		return errors.New("duplicate key")
+	}
+	s.written = append(s.written, query)
+
+	return nil
+}
+
+func (s *failingSink) close() error {
+	return nil
+}
+
+func TestBridge_ApplyEventRetry(t *testing.T) {
+	queries := batch{
+		{Action: mymy.ActionInsert, Table: "clients"},
+		{Action: mymy.ActionInsert, Table: "clients"},
+		{Action: mymy.ActionInsert, Table: "clients"},
+	}
+	s := &failingSink{failOn: queries[1]}
+	b := &Bridge{
+		sink:      s,
+		throttler: &throttler{mu: &sync.Mutex{}},
+		skipper:   newSkipper(),
+		stats:     newRuleStats(),
+		logger:    zerolog.Nop(),
+	}
+	e := &rowsEvent{table: "city.users", queries: queries}
+
+	require.Error(t, b.applyEvent(e))
+	assert.Equal(t, 1, e.applied)
+	assert.Equal(t, []*mymy.Query{queries[0]}, s.written)
+
+	// The retry after resume applies only the queries not applied yet.
+	require.NoError(t, b.applyEvent(e))
+	assert.Equal(t, 3, e.applied)
+	assert.Equal(t, []*mymy.Query(queries), s.written)
+}
@@ -0,0 +1,101 @@
+package bridge
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/city-mobil/go-mymy/internal/metrics"
+)
+
+// delayPollInterval is a period of checking the pause while the event is delayed.
+const delayPollInterval = time.Second
+
+// DelayStatus is a state of the delayed replication.
+type DelayStatus struct {
+	// Delay is the configured minimum age of the applied events in seconds.
+	Delay float64 `json:"delay"`
+	// WaitingUntil is the time the next event is applied at, nil if the writes do not wait.
+	WaitingUntil *time.Time `json:"waiting_until,omitempty"`
+}
+
+// delayer holds the binlog events until they are old enough, so the accidental
+// changes of the source can be stopped before they reach the upstream.
+type delayer struct {
+	delay time.Duration
+	// until is the time in unix nanoseconds the writes wait till, zero if they do not wait.
+	until *atomic.Int64
+}
+
+func newDelayer(delay time.Duration) *delayer {
+	metrics.SetReplicationDelay(delay)
+
+	return &delayer{
+		delay: delay,
+		until: atomic.NewInt64(0),
+	}
+}
+
+func (d *delayer) enabled() bool {
+	return d.delay > 0
+}
+
+// wait blocks until the event written to the binlog at the time is old enough.
+// It returns false if the waiting is interrupted by the pause or the context.
+func (d *delayer) wait(ctx context.Context, at time.Time, paused func() bool) bool {
+	if !d.enabled() || at.IsZero() {
+		return true
+	}
+
+	until := at.Add(d.delay)
+	d.until.Store(until.UnixNano())
+	defer d.until.Store(0)
+
+	for {
+		left := time.Until(until)
+		if left <= 0 {
+			return true
+		}
+		if left > delayPollInterval {
+			left = delayPollInterval
+		}
+
+		select {
+		case <-time.After(left):
+		case <-ctx.Done():
+			return false
+		}
+
+		if paused() {
+			return false
+		}
+	}
+}
+
+func (d *delayer) status() *DelayStatus {
+	if !d.enabled() {
+		return nil
+	}
+
+	st := &DelayStatus{Delay: d.delay.Seconds()}
+	if until := d.until.Load(); until > 0 {
+		t := time.Unix(0, until)
+		st.WaitingUntil = &t
+	}
+
+	return st
+}
+
+// eventTime returns the time the event has been written to the source,
+// zero if the event is not delayed.
+func eventTime(item interface{}) time.Time {
+	switch v := item.(type) {
+	case *rowsEvent:
+		return v.at
+	case *heartbeatEvent:
+		return v.sentAt
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,67 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelayer_Wait(t *testing.T) {
+	never := func() bool { return false }
+
+	t.Run("Disabled", func(t *testing.T) {
+		d := newDelayer(0)
+		assert.True(t, d.wait(context.Background(), time.Now(), never))
+		assert.Nil(t, d.status())
+	})
+
+	t.Run("OldEvent", func(t *testing.T) {
+		d := newDelayer(time.Minute)
+		assert.True(t, d.wait(context.Background(), time.Now().Add(-2*time.Minute), never))
+	})
+
+	t.Run("DumpedRows", func(t *testing.T) {
+		d := newDelayer(time.Minute)
+		assert.True(t, d.wait(context.Background(), time.Time{}, never))
+	})
+
+	t.Run("FreshEvent", func(t *testing.T) {
+		d := newDelayer(200 * time.Millisecond)
+		start := time.Now()
+		assert.True(t, d.wait(context.Background(), start, never))
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(200*time.Millisecond))
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		d := newDelayer(time.Hour)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan bool, 1)
+		go func() {
+			done <- d.wait(ctx, time.Now(), never)
+		}()
+
+		require.Eventually(t, func() bool {
+			return d.status().WaitingUntil != nil
+		}, time.Second, 5*time.Millisecond)
+		cancel()
+
+		select {
+		case ok := <-done:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			require.FailNow(t, "delay is not interrupted")
+		}
+		assert.Nil(t, d.status().WaitingUntil)
+	})
+
+	t.Run("Paused", func(t *testing.T) {
+		d := newDelayer(time.Hour)
+		paused := func() bool { return true }
+
+		assert.False(t, d.wait(context.Background(), time.Now(), paused))
+	})
+}
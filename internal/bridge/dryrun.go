@@ -0,0 +1,262 @@
+package bridge
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/util"
+)
+
+var errArgsMismatch = errors.New("number of placeholders does not match number of arguments")
+
+// dryRun writes the upstream statements out instead of executing them.
+type dryRun struct {
+	mu     *sync.Mutex
+	out    io.WriteCloser
+	logger zerolog.Logger
+}
+
+func newDryRun(cfg config.DryRunConfig, logger zerolog.Logger) (*dryRun, error) {
+	d := &dryRun{
+		mu:     &sync.Mutex{},
+		logger: logger,
+	}
+
+	if cfg.File != "" {
+		f, err := os.OpenFile(util.AbsPath(cfg.File), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+		if err != nil {
+			return nil, fmt.Errorf("could not open dry run file: %w", err)
+		}
+		d.out = f
+	}
+
+	return d, nil
+}
+
+// write renders the statement with the arguments and writes it to the file or the log.
+func (d *dryRun) write(query string, args []interface{}) error {
+	stmt, err := renderSQL(query, args)
+	if err != nil {
+		return err
+	}
+
+	if d.out == nil {
+		d.logger.Info().Str("query", stmt).Msg("dry run")
+
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err = io.WriteString(d.out, stmt+";\n")
+
+	return err
+}
+
+func (d *dryRun) close() error {
+	if d.out == nil {
+		return nil
+	}
+
+	return d.out.Close()
+}
+
+// renderSQL replaces the placeholders with the arguments like the driver does
+// with interpolateParams, so the statement can be executed as is.
+func renderSQL(query string, args []interface{}) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(query) + len(args)*8)
+
+	var (
+		quote byte
+		next  int
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(query) {
+				sb.WriteByte(c)
+				i++
+				c = query[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if next >= len(args) {
+				return "", errArgsMismatch
+			}
+
+			literal, err := sqlLiteral(args[next])
+			if err != nil {
+				return "", err
+			}
+			next++
+			sb.WriteString(literal)
+
+			continue
+		}
+		sb.WriteByte(c)
+	}
+
+	if next != len(args) {
+		return "", errArgsMismatch
+	}
+
+	return sb.String(), nil
+}
+
+func sqlLiteral(arg interface{}) (string, error) {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL", nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+
+		return "0", nil
+	case string:
+		return quoteString(v), nil
+	case []byte:
+		if v == nil {
+			return "NULL", nil
+		}
+
+		return "_binary" + quoteString(string(v)), nil
+	case time.Time:
+		if v.IsZero() {
+			return "'0000-00-00'", nil
+		}
+
+		return quoteString(v.Format("2006-01-02 15:04:05.999999")), nil
+	case driver.Valuer:
+		value, err := v.Value()
+		if err != nil {
+			return "", err
+		}
+
+		return sqlLiteral(value)
+	default:
+		return "", fmt.Errorf("unsupported argument type %T", arg)
+	}
+}
+
+// quoteString escapes the string as mysql_real_escape_string does.
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+
+	sb.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			sb.WriteString(`\0`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\x1a':
+			sb.WriteString(`\Z`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('\'')
+
+	return sb.String()
+}
+
+// memorySaver keeps the position in memory only. The dry run starts
+// from the saved position but does not advance it.
+type memorySaver struct {
+	pos position
+	mu  *sync.RWMutex
+}
+
+func newMemorySaver(pos position) *memorySaver {
+	return &memorySaver{
+		pos: pos,
+		mu:  &sync.RWMutex{},
+	}
+}
+
+func (s *memorySaver) load() (position, error) {
+	return s.position(), nil
+}
+
+func (s *memorySaver) save(pos position, _ bool) error {
+	if pos == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pos = pos
+
+	return nil
+}
+
+func (s *memorySaver) position() position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.pos == nil {
+		return nil
+	}
+
+	return s.pos.clone()
+}
+
+// reset is not used by the dry run, the position is not saved anyway.
+func (s *memorySaver) reset() error {
+	return nil
+}
+
+func (s *memorySaver) close() error {
+	return nil
+}
@@ -0,0 +1,160 @@
+package bridge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func TestRenderSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		args    []interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "Numbers",
+			query: "UPDATE users SET age=?, rating=?, active=? WHERE id=?",
+			args:  []interface{}{int8(42), 4.5, true, uint64(7)},
+			want:  "UPDATE users SET age=42, rating=4.5, active=1 WHERE id=7",
+		},
+		{
+			name:  "Strings",
+			query: "INSERT INTO users (name,bio,data) VALUES (?,?,?)",
+			args:  []interface{}{"O'Brien", "line\nbreak \\ \"quoted\"", []byte("raw")},
+			want:  `INSERT INTO users (name,bio,data) VALUES ('O\'Brien','line\nbreak \\ \"quoted\"',_binary'raw')`,
+		},
+		{
+			name:  "Null",
+			query: "DELETE FROM users WHERE id=? AND deleted_at=?",
+			args:  []interface{}{1, nil},
+			want:  "DELETE FROM users WHERE id=1 AND deleted_at=NULL",
+		},
+		{
+			name:  "Time",
+			query: "INSERT INTO users (created_at) VALUES (?)",
+			args:  []interface{}{time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)},
+			want:  "INSERT INTO users (created_at) VALUES ('2020-01-02 03:04:05.000006')",
+		},
+		{
+			name:  "QuotedPlaceholder",
+			query: "INSERT INTO `what?` (name) VALUES ('?', ?)",
+			args:  []interface{}{"bob"},
+			want:  "INSERT INTO `what?` (name) VALUES ('?', 'bob')",
+		},
+		{
+			name:    "MissingArgs",
+			query:   "DELETE FROM users WHERE id=?",
+			wantErr: true,
+		},
+		{
+			name:    "ExtraArgs",
+			query:   "DELETE FROM users",
+			args:    []interface{}{1},
+			wantErr: true,
+		},
+		{
+			name:    "UnsupportedType",
+			query:   "DELETE FROM users WHERE id=?",
+			args:    []interface{}{struct{}{}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderSQL(tt.query, tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDryRun_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-dry-run")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "statements.sql")
+	d, err := newDryRun(config.DryRunConfig{Enabled: true, File: path}, zerolog.Nop())
+	require.NoError(t, err)
+
+	loader := newInFileLoader(&loaderConfig{
+		database:       "town",
+		flushThreshold: 2,
+		argEnclose:     "'",
+		dryRun:         d,
+	})
+	err = loader.append(batch{
+		{Action: mymy.ActionInsert, Table: "clients", Values: []mymy.QueryArg{{Field: "id", Value: 1}}},
+		{Action: mymy.ActionInsert, Table: "clients", Values: []mymy.QueryArg{{Field: "id", Value: 2}}},
+	})
+	require.NoError(t, err)
+
+	q, args, err := (&mymy.Query{
+		Action: mymy.ActionDelete,
+		Table:  "clients",
+		Where:  []mymy.QueryArg{{Field: "id", Value: 1}},
+	}).SQL()
+	require.NoError(t, err)
+	require.NoError(t, d.write(q, args))
+	require.NoError(t, d.close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"INSERT INTO clients (id) VALUES (1);\n"+
+			"INSERT INTO clients (id) VALUES (2);\n"+
+			"DELETE FROM clients WHERE id=1;\n",
+		string(data),
+	)
+}
+
+func TestDryRun_StateSaver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-dry-run")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "master.info")
+	err = ioutil.WriteFile(path, []byte(`{"name":"mysql-bin.000001","pos":4}`), 0600)
+	require.NoError(t, err)
+
+	cfg := &config.Config{}
+	cfg.App.DataFile = path
+	cfg.Replication.SourceOpts.Flavor = config.FlavorMySQL
+
+	b := &Bridge{dryRun: &dryRun{}}
+	require.NoError(t, b.newStateSaver(cfg))
+	require.IsType(t, &memorySaver{}, b.stateSaver)
+	assert.Equal(t, "(mysql-bin.000001, 4)", b.stateSaver.position().String())
+
+	// The saved position is left as is.
+	require.NoError(t, b.stateSaver.save(newBinlogPos(mysql.Position{Name: "mysql-bin.000002", Pos: 4}), true))
+	require.NoError(t, b.stateSaver.close())
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"name":"mysql-bin.000001","pos":4}`, string(data))
+}
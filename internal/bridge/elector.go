@@ -0,0 +1,266 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/metrics"
+)
+
+// HA roles of the replicator.
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// ErrLeadershipLost is returned when the replicator loses the HA lock.
+var ErrLeadershipLost = errors.New("ha lock is lost")
+
+// IsLeadershipLost returns true if the error returned by Run is caused by the lost HA lock.
+// Run combines the errors of its loops, so every combined error is checked.
+func IsLeadershipLost(err error) bool {
+	for _, e := range multierr.Errors(err) {
+		if errors.Is(e, ErrLeadershipLost) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// elector elects the single replicating instance among the instances sharing
+// the state key. The leader holds the upstream named lock on a dedicated connection,
+// so the lock is released by the server as soon as the leader session ends.
+type elector struct {
+	client   *client.SQLClient
+	lock     string
+	interval time.Duration
+	logger   zerolog.Logger
+
+	mu   *sync.Mutex
+	conn *sql.Conn
+	// connID is the upstream connection holding the lock.
+	connID *atomic.Int64
+	leader *atomic.Bool
+}
+
+func newElector(cfg *config.Config, logger zerolog.Logger) (*elector, error) {
+	opts := cfg.Replication.UpstreamOpts
+
+	tlsCfg, err := opts.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.New(&client.Config{
+		Addr:           opts.Addr,
+		User:           opts.User,
+		Password:       string(opts.Password),
+		Database:       opts.Database,
+		Charset:        opts.Charset,
+		MaxOpenConns:   1,
+		MaxIdleConns:   1,
+		ConnectTimeout: opts.ConnectTimeout,
+		TLS:            tlsCfg,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to upstream to elect leader: %w", err)
+	}
+
+	metrics.SetLeader(false)
+
+	return &elector{
+		client:   c,
+		lock:     lockName(opts.Database, cfg.App.State.Key),
+		interval: cfg.App.HA.CheckInterval,
+		logger:   logger,
+		mu:       &sync.Mutex{},
+		connID:   atomic.NewInt64(0),
+		leader:   atomic.NewBool(false),
+	}, nil
+}
+
+// lockName follows the MySQL recommendation to qualify the lock names
+// by the database, because the names are server-wide.
+func lockName(database, key string) string {
+	return fmt.Sprintf("%s.%s", database, key)
+}
+
+func (e *elector) role() string {
+	if e.leader.Load() {
+		return RoleLeader
+	}
+
+	return RoleStandby
+}
+
+// acquire blocks until the lock is taken. It returns false if the context is done.
+func (e *elector) acquire(ctx context.Context) bool {
+	e.logger.Info().Str("lock", e.lock).Msg("waiting for ha lock")
+
+	for {
+		ok, err := e.tryAcquire(ctx)
+		if ctx.Err() != nil {
+			return false
+		}
+
+		if err != nil {
+			e.logger.Warn().Err(err).Str("lock", e.lock).Msg("could not take ha lock")
+			e.reset()
+
+			select {
+			case <-time.After(e.interval):
+			case <-ctx.Done():
+				return false
+			}
+
+			continue
+		}
+
+		if ok {
+			e.leader.Store(true)
+			metrics.SetLeader(true)
+			e.logger.Info().Str("lock", e.lock).Msg("took ha lock, became leader")
+
+			return true
+		}
+	}
+}
+
+func (e *elector) tryAcquire(ctx context.Context) (bool, error) {
+	conn, err := e.session(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// GET_LOCK waits in whole seconds.
+	timeout := int(e.interval / time.Second)
+	if timeout < 1 {
+		timeout = 1
+	}
+
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", e.lock, timeout).Scan(&got)
+	if err != nil {
+		return false, err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return false, nil
+	}
+
+	var id int64
+	err = conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id)
+	if err != nil {
+		return false, err
+	}
+	e.connID.Store(id)
+
+	return true, nil
+}
+
+// session returns the dedicated connection, opening it if needed.
+func (e *elector) session(ctx context.Context) (*sql.Conn, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		conn, err := e.client.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e.conn = conn
+	}
+
+	return e.conn, nil
+}
+
+// reset drops the connection, e.g. after a network error.
+func (e *elector) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		_ = e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// watch checks the lock until the context is done.
+// It returns ErrLeadershipLost if the lock is not held anymore.
+func (e *elector) watch(ctx context.Context) error {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := e.check(ctx)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				e.leader.Store(false)
+				metrics.SetLeader(false)
+
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (e *elector) check(ctx context.Context) error {
+	conn, err := e.session(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLeadershipLost, err)
+	}
+
+	var held sql.NullBool
+	err = conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", e.lock).Scan(&held)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLeadershipLost, err)
+	}
+	if !held.Valid || !held.Bool {
+		return ErrLeadershipLost
+	}
+
+	return nil
+}
+
+// holder returns the connection holding the lock, zero if the lock is not held.
+func (e *elector) holder() int64 {
+	if !e.leader.Load() {
+		return 0
+	}
+
+	return e.connID.Load()
+}
+
+// close releases the lock, so the standby takes over without waiting for the session timeout.
+func (e *elector) close() error {
+	e.mu.Lock()
+	if e.conn != nil {
+		if e.leader.Load() {
+			_, _ = e.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", e.lock)
+		}
+		_ = e.conn.Close()
+		e.conn = nil
+	}
+	e.mu.Unlock()
+
+	e.leader.Store(false)
+	metrics.SetLeader(false)
+
+	return e.client.Close()
+}
@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+	"go.uber.org/multierr"
+)
+
+func TestElector_Role(t *testing.T) {
+	e := &elector{
+		lock:   lockName("town", "mymy"),
+		connID: atomic.NewInt64(42),
+		leader: atomic.NewBool(false),
+	}
+	assert.Equal(t, "town.mymy", e.lock)
+
+	// The standby must not pass the position fence.
+	assert.Equal(t, RoleStandby, e.role())
+	assert.EqualValues(t, 0, e.holder())
+
+	e.leader.Store(true)
+	assert.Equal(t, RoleLeader, e.role())
+	assert.EqualValues(t, 42, e.holder())
+}
+
+func TestIsLeadershipLost(t *testing.T) {
+	lost := fmt.Errorf("sync loop error: %w",
+		fmt.Errorf("failed to save sync position, table: mymy_state, pos: 1, what: %w", ErrLeadershipLost))
+	other := errors.New("spill loop error: context canceled")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Nil", err: nil, want: false},
+		{name: "Other", err: other, want: false},
+		{name: "Lost", err: ErrLeadershipLost, want: true},
+		{name: "Wrapped", err: lost, want: true},
+		{name: "Combined", err: multierr.Combine(other, lost), want: true},
+		{name: "CombinedOther", err: multierr.Combine(other, errors.New("dump loop error")), want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsLeadershipLost(tt.err))
+		})
+	}
+}
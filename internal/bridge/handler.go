@@ -1,6 +1,9 @@
 package bridge
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"path"
 	"plugin"
 
@@ -8,6 +11,8 @@ import (
 	"github.com/city-mobil/go-mymy/pkg/mymy"
 )
 
+var ErrHandlerNotFound = errors.New("event handler not found")
+
 type EventHandlerFactory interface {
 	New(name, cfgPath string) (mymy.EventHandler, error)
 }
@@ -24,6 +29,10 @@ func NewEventHandlerPluginFactory(pluginDir string) EventHandlerFactory {
 
 func (f *EventHandlerPluginFactory) New(name, cfgPath string) (mymy.EventHandler, error) {
 	pluginPath := util.AbsPath(path.Join(f.pluginDir, name+".so"))
+	if _, err := os.Stat(pluginPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s, plugin: %s", ErrHandlerNotFound, name, pluginPath)
+	}
+
 	p, err := plugin.Open(pluginPath)
 	if err != nil {
 		return nil, err
@@ -38,3 +47,44 @@ func (f *EventHandlerPluginFactory) New(name, cfgPath string) (mymy.EventHandler
 
 	return newHandler.(func(cfgPath string) (mymy.EventHandler, error))(absCfgPath)
 }
+
+// EventHandlerRegistryFactory creates the handlers registered with mymy.Register.
+type EventHandlerRegistryFactory struct{}
+
+func NewEventHandlerRegistryFactory() EventHandlerFactory {
+	return &EventHandlerRegistryFactory{}
+}
+
+func (f *EventHandlerRegistryFactory) New(name, cfgPath string) (mymy.EventHandler, error) {
+	newHandler, ok := mymy.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrHandlerNotFound, name)
+	}
+
+	return newHandler(util.AbsPath(cfgPath))
+}
+
+// EventHandlerChainFactory asks the factories in order and returns the handler
+// of the first factory which knows the handler name.
+type EventHandlerChainFactory struct {
+	factories []EventHandlerFactory
+}
+
+func NewEventHandlerChainFactory(factories ...EventHandlerFactory) EventHandlerFactory {
+	return &EventHandlerChainFactory{
+		factories: factories,
+	}
+}
+
+func (f *EventHandlerChainFactory) New(name, cfgPath string) (mymy.EventHandler, error) {
+	for _, factory := range f.factories {
+		h, err := factory.New(name, cfgPath)
+		if errors.Is(err, ErrHandlerNotFound) {
+			continue
+		}
+
+		return h, err
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrHandlerNotFound, name)
+}
@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+type namedFactory struct {
+	name string
+	err  error
+}
+
+func (f *namedFactory) New(name, _ string) (mymy.EventHandler, error) {
+	if name != f.name {
+		return nil, ErrHandlerNotFound
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return mymy.NewBaseEventHandler(f.name), nil
+}
+
+func TestEventHandlerChainFactory(t *testing.T) {
+	errBroken := errors.New("broken handler")
+
+	factory := NewEventHandlerChainFactory(
+		&namedFactory{name: "first"},
+		&namedFactory{name: "broken", err: errBroken},
+		&namedFactory{name: "second"},
+	)
+
+	h, err := factory.New("second", "")
+	require.NoError(t, err)
+	assert.NotNil(t, h)
+
+	_, err = factory.New("broken", "")
+	assert.Equal(t, errBroken, err)
+
+	_, err = factory.New("unknown", "")
+	assert.True(t, errors.Is(err, ErrHandlerNotFound))
+}
+
+func TestEventHandlerRegistryFactory(t *testing.T) {
+	mymy.Register("bridge_test_handler", func(_ string) (mymy.EventHandler, error) {
+		return mymy.NewBaseEventHandler("clients"), nil
+	})
+
+	factory := NewEventHandlerRegistryFactory()
+
+	h, err := factory.New("bridge_test_handler", "")
+	require.NoError(t, err)
+	assert.NotNil(t, h)
+
+	_, err = factory.New("unknown", "")
+	assert.True(t, errors.Is(err, ErrHandlerNotFound))
+}
+
+func TestEventHandlerPluginFactory_NotFound(t *testing.T) {
+	factory := NewEventHandlerPluginFactory("testdata")
+
+	_, err := factory.New("unknown", "")
+	assert.True(t, errors.Is(err, ErrHandlerNotFound))
+}
@@ -0,0 +1,205 @@
+package bridge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/schema"
+	"go.uber.org/atomic"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+const (
+	heartbeatIDColumn = "id"
+	heartbeatTSColumn = "ts"
+)
+
+// heartbeatEvent is the heartbeat of the replicator received from the binlog.
+type heartbeatEvent struct {
+	// sentAt is the time the heartbeat has been written to the source.
+	sentAt time.Time
+}
+
+// heartbeat measures the end-to-end replication lag. The replicator periodically
+// writes its clock to the source table and records the heartbeat as applied when
+// it comes from the binlog and all the preceding events are applied to the upstream.
+// Both timestamps are taken from the replicator clock, so the lag does not depend
+// on the clock skew between the servers.
+type heartbeat struct {
+	schema   string
+	table    string
+	interval time.Duration
+	// id separates the heartbeats of the replicators sharing the table.
+	id uint32
+
+	// appliedAt is the sending time of the last applied heartbeat in unix nanoseconds.
+	appliedAt *atomic.Int64
+}
+
+func newHeartbeat(cfg *config.Config, serverID uint32) *heartbeat {
+	opts := cfg.Replication.SourceOpts
+
+	// The replay and the dry run do not write to the source, the heartbeat rows are skipped.
+	table := opts.Heartbeat.Table
+	if cfg.Replication.Replay.Enabled() || cfg.Replication.DryRun.Enabled {
+		table = ""
+	}
+
+	return &heartbeat{
+		schema:    opts.Database,
+		table:     table,
+		interval:  opts.Heartbeat.Interval,
+		id:        serverID,
+		appliedAt: atomic.NewInt64(0),
+	}
+}
+
+func (h *heartbeat) enabled() bool {
+	return h.table != ""
+}
+
+func (h *heartbeat) isHeartbeat(table *schema.Table) bool {
+	return h.enabled() && table.Schema == h.schema && table.Name == h.table
+}
+
+// start marks the start time as applied, so the lag grows until the first heartbeat is applied.
+func (h *heartbeat) start(now time.Time) {
+	h.appliedAt.CAS(0, now.UnixNano())
+}
+
+func (h *heartbeat) applied(e *heartbeatEvent) {
+	at := e.sentAt.UnixNano()
+	for {
+		cur := h.appliedAt.Load()
+		if at <= cur || h.appliedAt.CAS(cur, at) {
+			return
+		}
+	}
+}
+
+// lag returns the time passed since the last applied heartbeat has been sent.
+func (h *heartbeat) lag(now time.Time) time.Duration {
+	at := h.appliedAt.Load()
+	if at == 0 {
+		return 0
+	}
+
+	lag := now.Sub(time.Unix(0, at))
+	if lag < 0 {
+		return 0
+	}
+
+	return lag
+}
+
+// parse returns the heartbeats of the replicator from the binlog rows event.
+func (h *heartbeat) parse(e *canal.RowsEvent) ([]*heartbeatEvent, error) {
+	if e.Action == canal.DeleteAction {
+		return nil, nil
+	}
+
+	idIdx := e.Table.FindColumn(heartbeatIDColumn)
+	tsIdx := e.Table.FindColumn(heartbeatTSColumn)
+	if idIdx < 0 || tsIdx < 0 {
+		return nil, fmt.Errorf("heartbeat table %s must have columns %s and %s",
+			e.Table.Name, heartbeatIDColumn, heartbeatTSColumn)
+	}
+
+	var events []*heartbeatEvent
+	for i, row := range e.Rows {
+		// Only after-images matter for the updates.
+		if e.Action == canal.UpdateAction && i%2 == 0 {
+			continue
+		}
+
+		id, ok := toInt64(row[idIdx])
+		if !ok || id != int64(h.id) {
+			continue
+		}
+
+		ts, ok := toInt64(row[tsIdx])
+		if !ok {
+			return nil, fmt.Errorf("unexpected heartbeat timestamp: %v", row[tsIdx])
+		}
+
+		events = append(events, &heartbeatEvent{
+			sentAt: time.Unix(0, ts),
+		})
+	}
+
+	return events, nil
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// runHeartbeat writes the heartbeats to the source until the bridge is closed.
+func (b *Bridge) runHeartbeat() {
+	b.heartbeat.start(time.Now())
+
+	ticker := time.NewTicker(b.heartbeat.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.writeHeartbeat(time.Now()); err != nil {
+				b.logger.Warn().Err(err).Msg("could not write heartbeat")
+			}
+		case <-b.ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *Bridge) writeHeartbeat(now time.Time) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s.%s (%s, %s) VALUES (?, ?) ON DUPLICATE KEY UPDATE %s = VALUES(%s)",
+		quoteName(b.heartbeat.schema), quoteName(b.heartbeat.table),
+		heartbeatIDColumn, heartbeatTSColumn, heartbeatTSColumn, heartbeatTSColumn,
+	)
+
+	_, err := b.currentCanal().Execute(query, b.heartbeat.id, now.UnixNano())
+
+	return err
+}
+
+// onHeartbeat places the heartbeats into the sync queue in the binlog order.
+func (b *Bridge) onHeartbeat(e *canal.RowsEvent) error {
+	events, err := b.heartbeat.parse(e)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err = b.queue.push(b.ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HeartbeatLag returns the time passed since the last heartbeat applied to the upstream
+// has been written to the source. It returns false if the heartbeat is disabled.
+func (b *Bridge) HeartbeatLag() (time.Duration, bool) {
+	if !b.heartbeat.enabled() {
+		return 0, false
+	}
+
+	return b.heartbeat.lag(time.Now()), true
+}
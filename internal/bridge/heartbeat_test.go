@@ -0,0 +1,132 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+func newTestHeartbeat() *heartbeat {
+	var cfg config.Config
+	cfg.Replication.SourceOpts.Database = "city"
+	cfg.Replication.SourceOpts.Heartbeat.Table = "mymy_heartbeat"
+	cfg.Replication.SourceOpts.Heartbeat.Interval = time.Second
+
+	return newHeartbeat(&cfg, 100)
+}
+
+func TestHeartbeat_Parse(t *testing.T) {
+	table := &schema.Table{
+		Schema: "city",
+		Name:   "mymy_heartbeat",
+		Columns: []schema.TableColumn{
+			{Name: "id"},
+			{Name: "ts"},
+		},
+	}
+
+	sent := time.Unix(1600000000, 5000)
+	tests := []struct {
+		name   string
+		action string
+		rows   [][]interface{}
+		want   []*heartbeatEvent
+	}{
+		{
+			name:   "Insert",
+			action: canal.InsertAction,
+			rows:   [][]interface{}{{uint32(100), sent.UnixNano()}},
+			want:   []*heartbeatEvent{{sentAt: sent}},
+		},
+		{
+			name:   "Update",
+			action: canal.UpdateAction,
+			rows: [][]interface{}{
+				{uint32(100), sent.Add(-time.Second).UnixNano()},
+				{uint32(100), sent.UnixNano()},
+			},
+			want: []*heartbeatEvent{{sentAt: sent}},
+		},
+		{
+			name:   "AnotherReplicator",
+			action: canal.UpdateAction,
+			rows: [][]interface{}{
+				{uint32(200), sent.Add(-time.Second).UnixNano()},
+				{uint32(200), sent.UnixNano()},
+			},
+		},
+		{
+			name:   "Delete",
+			action: canal.DeleteAction,
+			rows:   [][]interface{}{{uint32(100), sent.UnixNano()}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHeartbeat()
+			got, err := h.parse(&canal.RowsEvent{Table: table, Action: tt.action, Rows: tt.rows})
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), len(got))
+			for i := range tt.want {
+				assert.True(t, tt.want[i].sentAt.Equal(got[i].sentAt))
+			}
+		})
+	}
+}
+
+func TestHeartbeat_ParseInvalidTable(t *testing.T) {
+	table := &schema.Table{
+		Schema: "city",
+		Name:   "mymy_heartbeat",
+		Columns: []schema.TableColumn{
+			{Name: "id"},
+			{Name: "value"},
+		},
+	}
+
+	h := newTestHeartbeat()
+	_, err := h.parse(&canal.RowsEvent{Table: table, Action: canal.InsertAction, Rows: [][]interface{}{{uint32(100), "1"}}})
+	assert.Error(t, err)
+}
+
+func TestHeartbeat_Lag(t *testing.T) {
+	h := newTestHeartbeat()
+	now := time.Now()
+
+	assert.Equal(t, time.Duration(0), h.lag(now))
+
+	h.start(now.Add(-10 * time.Second))
+	assert.Equal(t, 10*time.Second, h.lag(now))
+
+	h.applied(&heartbeatEvent{sentAt: now.Add(-2 * time.Second)})
+	assert.Equal(t, 2*time.Second, h.lag(now))
+
+	// The heartbeats applied out of order do not increase the lag.
+	h.applied(&heartbeatEvent{sentAt: now.Add(-5 * time.Second)})
+	assert.Equal(t, 2*time.Second, h.lag(now))
+
+	// The start does not override the applied heartbeat.
+	h.start(now)
+	assert.Equal(t, 2*time.Second, h.lag(now))
+}
+
+func TestHeartbeat_Disabled(t *testing.T) {
+	assert.True(t, newTestHeartbeat().enabled())
+
+	var cfg config.Config
+	cfg.Replication.SourceOpts.Heartbeat.Table = "mymy_heartbeat"
+	cfg.Replication.DryRun.Enabled = true
+	assert.False(t, newHeartbeat(&cfg, 100).enabled())
+
+	cfg.Replication.DryRun.Enabled = false
+	cfg.Replication.Replay.Files = []string{"mysql-bin.000001"}
+	assert.False(t, newHeartbeat(&cfg, 100).enabled())
+}
@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/city-mobil/go-mymy/internal/client"
 	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/metrics"
 	"github.com/city-mobil/go-mymy/pkg/mymy"
 	"github.com/go-sql-driver/mysql"
 )
@@ -20,12 +22,16 @@ type loaderConfig struct {
 	upstream       *client.SQLClient
 	flushThreshold int
 	argEnclose     string
+	throttler      *throttler
+	dryRun         *dryRun
 }
 
-func newLoaderConfig(cfg *config.Config, upstream *client.SQLClient) *loaderConfig {
+func newLoaderConfig(cfg *config.Config, upstream *client.SQLClient, throttler *throttler, dryRun *dryRun) *loaderConfig {
 	return &loaderConfig{
 		database:       cfg.Replication.UpstreamOpts.Database,
 		upstream:       upstream,
+		throttler:      throttler,
+		dryRun:         dryRun,
 		flushThreshold: cfg.Replication.SourceOpts.Dump.LoadInFileFlushThreshold,
 		argEnclose:     cfg.Replication.SourceOpts.Dump.ArgEnclose,
 	}
@@ -37,6 +43,8 @@ type inFileLoader struct {
 	upstream       *client.SQLClient
 	flushThreshold int
 	argEnclose     string
+	throttler      *throttler
+	dryRun         *dryRun
 }
 
 func newInFileLoader(cfg *loaderConfig) *inFileLoader {
@@ -46,6 +54,8 @@ func newInFileLoader(cfg *loaderConfig) *inFileLoader {
 		upstream:       cfg.upstream,
 		flushThreshold: cfg.flushThreshold,
 		argEnclose:     cfg.argEnclose,
+		throttler:      cfg.throttler,
+		dryRun:         cfg.dryRun,
 	}
 }
 
@@ -82,6 +92,10 @@ func (loader *inFileLoader) flush(key string) error {
 		loader.data[key] = make(batch, 0)
 	}()
 
+	if loader.dryRun != nil {
+		return loader.writeDryRun(b)
+	}
+
 	f, err := ioutil.TempFile("", "mymy")
 	if err != nil {
 		return err
@@ -110,12 +124,36 @@ func (loader *inFileLoader) flush(key string) error {
 	mysql.RegisterLocalFile(f.Name())
 	defer mysql.DeregisterLocalFile(f.Name())
 
+	err = loader.throttler.wait(len(b), 1)
+	if err != nil {
+		return err
+	}
+
 	q := loader.buildDumpQuery(f.Name(), key)
+	start := time.Now()
 	_, err = loader.upstream.Exec(context.Background(), q)
+	metrics.ObserveFlushDuration(key, time.Since(start))
 
 	return err
 }
 
+// writeDryRun writes the rows as the separate statements, because the file is not kept.
+func (loader *inFileLoader) writeDryRun(b batch) error {
+	for _, query := range b {
+		q, args, err := query.SQL()
+		if err != nil {
+			return err
+		}
+
+		err = loader.dryRun.write(q, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (loader *inFileLoader) flushAll() error {
 	for key := range loader.data {
 		err := loader.flush(key)
@@ -0,0 +1,263 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"go.uber.org/multierr"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+var (
+	ErrReplicatorRunning = errors.New("replicator holds the ha lock, stop it first")
+	ErrEmptyPosition     = errors.New("gtid, binlog file and position or current position is required")
+	ErrStopNotChecked    = errors.New("no ha lock to check the replicator is stopped, stop it first and force the change")
+)
+
+// PositionInfo is the replication position in both forms.
+type PositionInfo struct {
+	GTIDMode bool `json:"gtid_mode"`
+	// Empty is true if nothing is saved, the replicator starts with the dump.
+	Empty bool   `json:"empty"`
+	GTID  string `json:"gtid,omitempty"`
+	File  string `json:"file,omitempty"`
+	Pos   uint32 `json:"pos,omitempty"`
+	// Error tells why the replication can not continue from the position.
+	Error string `json:"error,omitempty"`
+}
+
+// PositionTarget is the position to set: either the GTID set,
+// the binlog file and position or the current source position.
+type PositionTarget struct {
+	GTID    string
+	File    string
+	Pos     uint32
+	Current bool
+}
+
+// PositionManager reads and writes the saved position of the stopped replicator.
+type PositionManager struct {
+	*binlogInspector
+
+	saver    stateSaver
+	upstream *client.SQLClient
+	// lock is the ha lock name, empty if the HA is disabled.
+	lock string
+
+	// Force allows to change the position without the ha lock to check, i.e. with
+	// the state file or with the HA disabled. The caller makes sure the replicator is stopped.
+	Force bool
+}
+
+func NewPositionManager(cfg *config.Config) (*PositionManager, error) {
+	opts := cfg.Replication.SourceOpts
+
+	source, err := newSourceClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &PositionManager{
+		binlogInspector: newBinlogInspector(source, cfg),
+	}
+
+	state := cfg.App.State
+	if state.Table == "" {
+		m.saver, err = newFileSaver(cfg.App.DataFile, m.gtidMode, m.flavor)
+		if err != nil {
+			_ = source.Close()
+
+			return nil, err
+		}
+
+		return m, nil
+	}
+
+	m.upstream, err = newUpstreamClient(cfg)
+	if err != nil {
+		_ = source.Close()
+
+		return nil, fmt.Errorf("could not connect to upstream: %w", err)
+	}
+	if cfg.App.HA.Enabled {
+		m.lock = lockName(cfg.Replication.UpstreamOpts.Database, state.Key)
+	}
+
+	m.saver, err = newTableSaver(m.upstream, state.Table, state.Key, m.gtidMode, m.flavor, nil)
+	if err != nil {
+		_ = m.Close()
+
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Show returns the saved position and checks it is still available on the source.
+func (m *PositionManager) Show(ctx context.Context) (*PositionInfo, error) {
+	pos, err := m.saver.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.describe(ctx, pos), nil
+}
+
+// Set validates the position against the source and saves it.
+func (m *PositionManager) Set(ctx context.Context, target PositionTarget) (*PositionInfo, error) {
+	if err := m.checkStopped(ctx); err != nil {
+		return nil, err
+	}
+
+	pos, err := m.resolve(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	info := m.describe(ctx, pos)
+	if info.Error != "" {
+		return nil, errors.New(info.Error)
+	}
+
+	if _, err = m.saver.load(); err != nil {
+		return nil, err
+	}
+	if err = m.saver.save(pos, true); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Reset removes the saved position, so the replicator starts with the dump.
+func (m *PositionManager) Reset(ctx context.Context) error {
+	if err := m.checkStopped(ctx); err != nil {
+		return err
+	}
+
+	return m.saver.reset()
+}
+
+func (m *PositionManager) Close() error {
+	var err error
+	if m.upstream != nil {
+		err = m.upstream.Close()
+	}
+
+	return multierr.Append(err, m.source.Close())
+}
+
+// checkStopped returns an error if the replicator may be running. Only the holder
+// of the ha lock is detected, without the lock the change must be forced.
+func (m *PositionManager) checkStopped(ctx context.Context) error {
+	if m.lock == "" {
+		if m.Force {
+			return nil
+		}
+
+		return ErrStopNotChecked
+	}
+
+	var holder sql.NullInt64
+	err := m.upstream.QueryRow(ctx, "SELECT IS_USED_LOCK(?)", m.lock).Scan(&holder)
+	if err != nil {
+		return err
+	}
+	if holder.Valid {
+		return ErrReplicatorRunning
+	}
+
+	return nil
+}
+
+// resolve converts the target to the position of the replication mode.
+func (i *binlogInspector) resolve(ctx context.Context, target PositionTarget) (position, error) {
+	if target.Current {
+		file, pos, gtid, err := i.masterStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		target = PositionTarget{File: file, Pos: pos, GTID: gtid}
+	}
+
+	switch {
+	case i.gtidMode && target.GTID != "":
+		set, err := mysql.ParseGTIDSet(i.flavor, target.GTID)
+		if err != nil {
+			return nil, err
+		}
+
+		return newGTIDSet(i.flavor, set), nil
+	case i.gtidMode && target.File != "":
+		set, err := i.filePosToGTID(ctx, target.File, target.Pos)
+		if err != nil {
+			return nil, err
+		}
+
+		return newGTIDSet(i.flavor, set), nil
+	case !i.gtidMode && target.File != "":
+		return newBinlogPos(mysql.Position{Name: target.File, Pos: target.Pos}), nil
+	case !i.gtidMode && target.GTID != "":
+		set, err := mysql.ParseGTIDSet(i.flavor, target.GTID)
+		if err != nil {
+			return nil, err
+		}
+
+		file, pos, err := i.gtidToFilePos(ctx, set)
+		if err != nil {
+			return nil, err
+		}
+
+		return newBinlogPos(mysql.Position{Name: file, Pos: pos}), nil
+	}
+
+	return nil, ErrEmptyPosition
+}
+
+// describe fills the position in both forms. The failed conversion is reported as the error.
+func (i *binlogInspector) describe(ctx context.Context, pos position) *PositionInfo {
+	info := &PositionInfo{GTIDMode: i.gtidMode}
+
+	switch p := pos.(type) {
+	case *gtidSet:
+		info.GTID = p.String()
+		if info.GTID == "" {
+			info.Empty = true
+
+			return info
+		}
+
+		file, filePos, err := i.gtidToFilePos(ctx, p.pos)
+		if err != nil {
+			info.Error = err.Error()
+
+			return info
+		}
+		info.File, info.Pos = file, filePos
+	case *binlogPos:
+		if p.pos.Name == "" {
+			info.Empty = true
+
+			return info
+		}
+		info.File, info.Pos = p.pos.Name, p.pos.Pos
+
+		if err := i.checkFilePos(ctx, p.pos.Name, p.pos.Pos); err != nil {
+			info.Error = err.Error()
+
+			return info
+		}
+
+		set, err := i.filePosToGTID(ctx, p.pos.Name, p.pos.Pos)
+		if err == nil {
+			info.GTID = set.String()
+		}
+	}
+
+	return info
+}
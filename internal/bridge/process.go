@@ -0,0 +1,225 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/util"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+	"github.com/city-mobil/go-mymy/pkg/mymy/rpc"
+)
+
+var (
+	ErrHandlerTimeout = errors.New("handler process call timed out")
+
+	errHandlerClosed = errors.New("handler process is closed")
+)
+
+// EventHandlerProcessFactory runs the handlers as separate processes
+// talking over stdin and stdout using the protocol of the rpc package.
+// The process which does not respond within the timeout is killed and
+// started again on the next call. Zero timeout means no limit.
+type EventHandlerProcessFactory struct {
+	pluginDir string
+	timeout   time.Duration
+}
+
+func NewEventHandlerProcessFactory(pluginDir string, timeout time.Duration) EventHandlerFactory {
+	return &EventHandlerProcessFactory{
+		pluginDir: pluginDir,
+		timeout:   timeout,
+	}
+}
+
+func (f *EventHandlerProcessFactory) New(name, cfgPath string) (mymy.EventHandler, error) {
+	execPath := util.AbsPath(path.Join(f.pluginDir, name))
+	info, err := os.Stat(execPath)
+	if os.IsNotExist(err) || (err == nil && (info.IsDir() || info.Mode()&0111 == 0)) {
+		return nil, fmt.Errorf("%w: %s, executable: %s", ErrHandlerNotFound, name, execPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	h := &processHandler{
+		execPath: execPath,
+		cfgPath:  util.AbsPath(cfgPath),
+		timeout:  f.timeout,
+		mu:       &sync.Mutex{},
+	}
+	if err = h.start(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// processHandler calls the handler process. If the process exits
+// it is started again on the next call.
+type processHandler struct {
+	execPath string
+	cfgPath  string
+	timeout  time.Duration
+
+	mu     *sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	client *rpc.Client
+	// source is the last table info to pass to the restarted process.
+	source *mymy.SourceInfo
+	closed bool
+}
+
+func (h *processHandler) start() error {
+	cmd := exec.Command(h.execPath)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start handler process %s: %w", h.execPath, err)
+	}
+
+	h.cmd = cmd
+	h.stdin = stdin
+	h.stdout = stdout
+	h.client = rpc.NewClient(stdout, stdin)
+
+	err = h.invoke(func(c *rpc.Client) error {
+		if errInit := c.Init(h.cfgPath); errInit != nil {
+			return errInit
+		}
+		if h.source != nil {
+			return c.OnTableChanged(*h.source)
+		}
+
+		return nil
+	})
+	if err != nil {
+		h.stop()
+
+		return fmt.Errorf("failed to init handler process %s: %w", h.execPath, err)
+	}
+
+	return nil
+}
+
+func (h *processHandler) stop() {
+	if h.cmd == nil {
+		return
+	}
+
+	_ = h.stdin.Close()
+	_ = h.cmd.Process.Kill()
+	_ = h.cmd.Wait()
+
+	h.cmd = nil
+	h.stdin = nil
+	h.stdout = nil
+	h.client = nil
+}
+
+// invoke runs fn with the handler client killing the process
+// if fn does not return within the timeout.
+func (h *processHandler) invoke(fn func(c *rpc.Client) error) error {
+	if h.timeout <= 0 {
+		return fn(h.client)
+	}
+
+	cmd, stdout := h.cmd, h.stdout
+	timer := time.AfterFunc(h.timeout, func() {
+		// Closing stdout unblocks the read even if the process
+		// has children holding the pipe open.
+		_ = cmd.Process.Kill()
+		_ = stdout.Close()
+	})
+
+	err := fn(h.client)
+	if !timer.Stop() {
+		h.stop()
+
+		return fmt.Errorf("%w: %s, timeout: %s", ErrHandlerTimeout, h.execPath, h.timeout)
+	}
+
+	return err
+}
+
+// call runs fn with the handler client restarting the process once
+// if it is not available.
+func (h *processHandler) call(fn func(c *rpc.Client) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return errHandlerClosed
+	}
+
+	if h.client == nil {
+		if err := h.start(); err != nil {
+			return err
+		}
+	}
+
+	// The timed out call is not retried, the hanging handler would
+	// only double the delay. The process is restarted on the next call.
+	err := h.invoke(fn)
+	var remoteErr *rpc.RemoteError
+	if err == nil || errors.As(err, &remoteErr) || errors.Is(err, ErrHandlerTimeout) {
+		return err
+	}
+
+	h.stop()
+	if err = h.start(); err != nil {
+		return err
+	}
+
+	return h.invoke(fn)
+}
+
+func (h *processHandler) OnTableChanged(info mymy.SourceInfo) error {
+	return h.call(func(c *rpc.Client) error {
+		if err := c.OnTableChanged(info); err != nil {
+			return err
+		}
+		h.source = &info
+
+		return nil
+	})
+}
+
+func (h *processHandler) OnRows(e *mymy.RowsEvent) ([]*mymy.Query, error) {
+	var queries []*mymy.Query
+	err := h.call(func(c *rpc.Client) error {
+		var err error
+		queries, err = c.OnRows(e)
+
+		return err
+	})
+
+	return queries, err
+}
+
+// Close stops the handler process.
+func (h *processHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stop()
+	h.closed = true
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+	"github.com/city-mobil/go-mymy/pkg/mymy/rpc"
+)
+
+const helperProcessEnv = "MYMY_WANT_HELPER_PROCESS"
+
+// TestHelperProcess is not a real test. It serves the handler
+// when the test binary is started by the process factory.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv(helperProcessEnv) != "1" {
+		return
+	}
+
+	err := rpc.Serve(func(cfgPath string) (mymy.EventHandler, error) {
+		h := mymy.NewBaseEventHandler(filepath.Base(cfgPath))
+		if filepath.Base(cfgPath) == "slow" {
+			return &slowHandler{EventHandler: h}, nil
+		}
+
+		return h, nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// slowHandler never responds to the rows in time.
+type slowHandler struct {
+	mymy.EventHandler
+}
+
+func (h *slowHandler) OnRows(_ *mymy.RowsEvent) ([]*mymy.Query, error) {
+	time.Sleep(time.Minute)
+
+	return nil, nil
+}
+
+func TestEventHandlerProcessFactory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-process")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec %q -test.run=TestHelperProcess\n", helperProcessEnv, os.Args[0])
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "clients"), []byte(script), 0755))
+
+	factory := NewEventHandlerProcessFactory(dir, 0)
+
+	_, err = factory.New("unknown", "")
+	assert.True(t, errors.Is(err, ErrHandlerNotFound))
+
+	h, err := factory.New("clients", "clients")
+	require.NoError(t, err)
+
+	require.NoError(t, h.OnTableChanged(tSnapshotSource))
+
+	e := &mymy.RowsEvent{
+		Action: mymy.ActionInsert,
+		Source: tSnapshotSource,
+		Rows:   [][]interface{}{{uint64(1), "bob"}},
+	}
+	want := []*mymy.Query{
+		{
+			Action: mymy.ActionInsert,
+			Table:  "clients",
+			Values: []mymy.QueryArg{{Field: "id", Value: uint64(1)}, {Field: "name", Value: "bob"}},
+		},
+	}
+
+	got, err := h.OnRows(e)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// The handler is restarted with the last table info after the process exit.
+	ph := h.(*processHandler)
+	require.NoError(t, ph.cmd.Process.Kill())
+
+	got, err = h.OnRows(e)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	ph.stop()
+}
+
+func TestEventHandlerProcessFactory_Timeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-process")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec %q -test.run=TestHelperProcess\n", helperProcessEnv, os.Args[0])
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "clients"), []byte(script), 0755))
+
+	factory := NewEventHandlerProcessFactory(dir, 500*time.Millisecond)
+
+	h, err := factory.New("clients", "slow")
+	require.NoError(t, err)
+	ph := h.(*processHandler)
+	defer func() {
+		assert.NoError(t, ph.Close())
+	}()
+
+	require.NoError(t, h.OnTableChanged(tSnapshotSource))
+
+	start := time.Now()
+	_, err = h.OnRows(&mymy.RowsEvent{
+		Action: mymy.ActionInsert,
+		Source: tSnapshotSource,
+		Rows:   [][]interface{}{{uint64(1), "bob"}},
+	})
+	assert.True(t, errors.Is(err, ErrHandlerTimeout))
+	assert.Less(t, int64(time.Since(start)), int64(10*time.Second))
+
+	// The killed process is started again on the next call.
+	assert.Nil(t, ph.cmd)
+	require.NoError(t, h.OnTableChanged(tSnapshotSource))
+	assert.NotNil(t, ph.cmd)
+}
@@ -0,0 +1,330 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/metrics"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+const (
+	// queryOverhead is an approximate size of the query without the values.
+	queryOverhead = 64
+	// eventOverhead is an approximate size of the event without the queries.
+	eventOverhead = 64
+)
+
+// eventQueue buffers the events between the binlog reader and the upstream writer.
+// The buffer is limited by the number of the events, the source rows and the approximate size of the queries.
+// The binlog reader is blocked when the buffer is full unless the spilling to disk is enabled.
+type eventQueue struct {
+	ch chan interface{}
+
+	maxEvents int
+	maxRows   int
+	maxBytes  int64
+
+	mu *sync.Mutex
+	// changed is closed and replaced on every state change to wake up the waiters.
+	changed chan struct{}
+	events  int
+	rows    int
+	bytes   int64
+
+	// spill keeps the events in order while the memory buffer is full, nil if disabled.
+	spill *spillFile
+	// spilling is true while the spill file contains the events not yet moved to the memory.
+	spilling bool
+	spillCh  chan struct{}
+
+	// blocked is true while the binlog reader waits for the buffer space.
+	blocked *atomic.Bool
+}
+
+func newEventQueue(cfg config.BufferConfig) (*eventQueue, error) {
+	q := &eventQueue{
+		ch:        make(chan interface{}, cfg.MaxEvents),
+		maxEvents: cfg.MaxEvents,
+		maxRows:   cfg.MaxRows,
+		maxBytes:  cfg.MaxBytes,
+		mu:        &sync.Mutex{},
+		changed:   make(chan struct{}),
+		spillCh:   make(chan struct{}, 1),
+		blocked:   atomic.NewBool(false),
+	}
+
+	if cfg.SpillDir != "" {
+		spill, err := openSpillFile(cfg.SpillDir, cfg.SpillMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		q.spill = spill
+	}
+
+	return q, nil
+}
+
+// push places the event into the buffer. It blocks while the buffer is full
+// and the event can not be spilled to disk.
+func (q *eventQueue) push(ctx context.Context, item interface{}) error {
+	rows, bytes := eventSize(item)
+
+	q.mu.Lock()
+	if q.spill != nil && (q.spilling || !q.fits(rows, bytes)) {
+		// Wait until the spilled events are moved to the memory,
+		// so the spill file starts from scratch.
+		for q.spilling && q.spill.full() {
+			changed := q.changed
+			q.mu.Unlock()
+			if err := q.waitReader(ctx, changed); err != nil {
+				return err
+			}
+			q.mu.Lock()
+		}
+
+		if q.spilling || !q.fits(rows, bytes) {
+			err := q.spill.write(item)
+			if err == nil {
+				q.spilling = true
+				select {
+				case q.spillCh <- struct{}{}:
+				default:
+				}
+			}
+			q.mu.Unlock()
+
+			return err
+		}
+	}
+	q.mu.Unlock()
+
+	return q.enqueue(ctx, item, rows, bytes, true)
+}
+
+func (q *eventQueue) enqueue(ctx context.Context, item interface{}, rows int, bytes int64, reader bool) error {
+	q.mu.Lock()
+	for !q.fits(rows, bytes) {
+		changed := q.changed
+		q.mu.Unlock()
+
+		var err error
+		if reader {
+			err = q.waitReader(ctx, changed)
+		} else {
+			err = wait(ctx, changed)
+		}
+		if err != nil {
+			return err
+		}
+
+		q.mu.Lock()
+	}
+	q.events++
+	q.rows += rows
+	q.bytes += bytes
+	q.mu.Unlock()
+
+	// The channel capacity is not exceeded, because the events are counted before sending.
+	q.ch <- item
+
+	return nil
+}
+
+// fits returns true if the event can be placed into the memory buffer.
+func (q *eventQueue) fits(rows int, bytes int64) bool {
+	if q.events >= q.maxEvents {
+		return false
+	}
+
+	// A single event exceeding the limits is accepted by the empty buffer.
+	// The events without queries are limited only by the number of events.
+	if q.events == 0 || rows == 0 {
+		return true
+	}
+
+	if q.maxRows > 0 && q.rows+rows > q.maxRows {
+		return false
+	}
+
+	return q.maxBytes <= 0 || q.bytes+bytes <= q.maxBytes
+}
+
+// waitReader waits for the buffer space on behalf of the binlog reader.
+func (q *eventQueue) waitReader(ctx context.Context, changed <-chan struct{}) error {
+	q.blocked.Store(true)
+	start := time.Now()
+	defer func() {
+		q.blocked.Store(false)
+		metrics.AddBufferBlocked(time.Since(start))
+	}()
+
+	return wait(ctx, changed)
+}
+
+func wait(ctx context.Context, changed <-chan struct{}) error {
+	select {
+	case <-changed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *eventQueue) out() <-chan interface{} {
+	return q.ch
+}
+
+func (q *eventQueue) len() int {
+	return len(q.ch)
+}
+
+// release frees the buffer space of the event received from the queue.
+func (q *eventQueue) release(item interface{}) {
+	rows, bytes := eventSize(item)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.events--
+	q.rows -= rows
+	q.bytes -= bytes
+	q.notify()
+}
+
+func (q *eventQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// run moves the spilled events to the memory buffer until the context is done.
+func (q *eventQueue) run(ctx context.Context) error {
+	if q.spill == nil {
+		return nil
+	}
+
+	for {
+		select {
+		case <-q.spillCh:
+		case <-ctx.Done():
+			return nil
+		}
+
+		for {
+			q.mu.Lock()
+			if q.spill.pending() == 0 {
+				q.spilling = false
+				err := q.spill.reset()
+				q.notify()
+				q.mu.Unlock()
+				if err != nil {
+					return err
+				}
+
+				break
+			}
+			q.mu.Unlock()
+
+			item, err := q.spill.read()
+			if err != nil {
+				return err
+			}
+
+			rows, bytes := eventSize(item)
+			if err = q.enqueue(ctx, item, rows, bytes, false); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// QueueStatus is a state of the buffer between the binlog reader and the upstream writer.
+type QueueStatus struct {
+	Length   int   `json:"length"`
+	Capacity int   `json:"capacity"`
+	Rows     int   `json:"rows"`
+	MaxRows  int   `json:"max_rows"`
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"max_bytes"`
+	// Spilled is the number of the events written to disk.
+	Spilled      int   `json:"spilled"`
+	SpilledBytes int64 `json:"spilled_bytes"`
+	// Blocked is true while the binlog reader waits for the buffer space.
+	Blocked bool `json:"blocked"`
+}
+
+func (q *eventQueue) status() QueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	st := QueueStatus{
+		Length:   q.events,
+		Capacity: q.maxEvents,
+		Rows:     q.rows,
+		MaxRows:  q.maxRows,
+		Bytes:    q.bytes,
+		MaxBytes: q.maxBytes,
+		Blocked:  q.blocked.Load(),
+	}
+
+	if q.spill != nil {
+		st.Spilled = q.spill.pending()
+		st.SpilledBytes = q.spill.sizeBytes()
+	}
+
+	return st
+}
+
+func (q *eventQueue) close() error {
+	if q.spill == nil {
+		return nil
+	}
+
+	return q.spill.close()
+}
+
+// eventSize returns the number of the source rows and the approximate size of the event.
+func eventSize(item interface{}) (rows int, bytes int64) {
+	switch v := item.(type) {
+	case *rowsEvent:
+		return v.rows, eventOverhead + v.queries.size()
+	case batch:
+		// The re-snapshot chunk does not keep the source rows,
+		// the handlers usually build a query per row.
+		return len(v), eventOverhead + v.size()
+	default:
+		return 0, eventOverhead
+	}
+}
+
+func (queries batch) size() int64 {
+	var size int64
+	for _, query := range queries {
+		size += queryOverhead + int64(len(query.Table))
+		size += argsSize(query.Values) + argsSize(query.Where)
+	}
+
+	return size
+}
+
+func argsSize(args []mymy.QueryArg) int64 {
+	var size int64
+	for _, arg := range args {
+		size += int64(len(arg.Field))
+		switch v := arg.Value.(type) {
+		case nil:
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		default:
+			size += 8
+		}
+	}
+
+	return size
+}
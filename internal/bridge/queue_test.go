@@ -0,0 +1,184 @@
+package bridge
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func newTestRowsEvent(id int) *rowsEvent {
+	return &rowsEvent{
+		queries: batch{
+			{
+				Action: mymy.ActionInsert,
+				Table:  "clients",
+				Values: []mymy.QueryArg{
+					{Field: "id", Value: int64(id)},
+					{Field: "name", Value: "bob"},
+				},
+			},
+		},
+		rows:   1,
+		table:  "city.users",
+		action: "insert",
+		at:     time.Unix(1600000000+int64(id), 0),
+	}
+}
+
+func TestEventQueue_Limits(t *testing.T) {
+	q, err := newEventQueue(config.BufferConfig{
+		MaxEvents: 10,
+		MaxRows:   2,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The event exceeding the limits is accepted by the empty buffer.
+	big := &rowsEvent{queries: append(newTestRowsEvent(1).queries, newTestRowsEvent(2).queries...), rows: 3}
+	big.queries = append(big.queries, newTestRowsEvent(3).queries...)
+	require.NoError(t, q.push(ctx, big))
+
+	// Non-row events always fit into the limits.
+	require.NoError(t, q.push(ctx, &heartbeatEvent{sentAt: time.Now()}))
+
+	assert.Equal(t, context.DeadlineExceeded, q.push(ctx, newTestRowsEvent(4)))
+	assert.Equal(t, QueueStatus{Length: 2, Capacity: 10, Rows: 3, MaxRows: 2, Bytes: q.status().Bytes}, q.status())
+
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- q.push(context.Background(), newTestRowsEvent(5))
+	}()
+
+	got := <-q.out()
+	q.release(got)
+	assert.Equal(t, big, got)
+
+	require.NoError(t, <-pushed)
+	st := q.status()
+	assert.Equal(t, 2, st.Length)
+	assert.Equal(t, 1, st.Rows)
+}
+
+func TestEventQueue_Spill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-spill")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	q, err := newEventQueue(config.BufferConfig{
+		MaxEvents: 2,
+		SpillDir:  dir,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	gtid, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, "de278ad0-2106-11e4-9f8e-6edd0ca20947:1-10")
+	require.NoError(t, err)
+	sentAt := time.Unix(1600000000, 500)
+
+	// The values keep their types and the time keeps the nanoseconds.
+	typed := newTestRowsEvent(1)
+	typed.at = time.Unix(1600000000, 123456789).UTC()
+	typed.queries[0].Values = append(typed.queries[0].Values,
+		mymy.QueryArg{Field: "age", Value: uint8(30)},
+		mymy.QueryArg{Field: "created_at", Value: time.Unix(1600000000, 5000).UTC()},
+		mymy.QueryArg{Field: "email", Value: nil},
+	)
+
+	want := []interface{}{
+		typed,
+		newTestRowsEvent(2),
+		&savePos{pos: newGTIDSet(mysql.MySQLFlavor, gtid), force: true},
+		batch(newTestRowsEvent(3).queries),
+		&heartbeatEvent{sentAt: sentAt},
+		newTestRowsEvent(4),
+	}
+
+	// The reader is not blocked while the events are spilled.
+	for _, item := range want {
+		require.NoError(t, q.push(ctx, item))
+	}
+
+	st := q.status()
+	assert.Equal(t, 2, st.Length)
+	assert.Equal(t, 4, st.Spilled)
+	assert.False(t, st.Blocked)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- q.run(ctx)
+	}()
+
+	for i, w := range want {
+		var got interface{}
+		select {
+		case got = <-q.out():
+			q.release(got)
+		case <-time.After(time.Second):
+			require.FailNow(t, "no event in the queue")
+		}
+
+		switch v := w.(type) {
+		case *rowsEvent:
+			e, ok := got.(*rowsEvent)
+			require.True(t, ok, i)
+			assert.Equal(t, v.queries, e.queries)
+			assert.Equal(t, v.rows, e.rows)
+			assert.Equal(t, v.table, e.table)
+			assert.Equal(t, v.action, e.action)
+			assert.Equal(t, v.at, e.at)
+		case *savePos:
+			p, ok := got.(*savePos)
+			require.True(t, ok, i)
+			assert.True(t, v.pos.equal(p.pos))
+			assert.Equal(t, v.force, p.force)
+		case *heartbeatEvent:
+			h, ok := got.(*heartbeatEvent)
+			require.True(t, ok, i)
+			assert.True(t, sentAt.Equal(h.sentAt))
+		default:
+			assert.Equal(t, w, got, i)
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		st = q.status()
+
+		return st.Spilled == 0 && st.SpilledBytes == 0
+	}, time.Second, 5*time.Millisecond)
+
+	// The events go to the memory after the spill file is drained.
+	require.NoError(t, q.push(ctx, newTestRowsEvent(5)))
+	assert.Equal(t, 0, q.status().Spilled)
+
+	cancel()
+	require.NoError(t, <-done)
+	require.NoError(t, q.close())
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestEventSize_CountsRows(t *testing.T) {
+	e := newTestRowsEvent(1)
+	e.queries = append(e.queries, newTestRowsEvent(2).queries...)
+
+	// A single source row may be written to several upstream tables.
+	rows, _ := eventSize(e)
+	assert.Equal(t, 1, rows)
+}
@@ -0,0 +1,248 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+const snapshotRetryInterval = 1 * time.Second
+
+var ErrReloadUnsupported = errors.New("config change can not be applied without restart")
+
+// ReloadResult lists the source tables which rules are changed by the reload.
+type ReloadResult struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Updated []string `json:"updated"`
+	// Snapshot lists the added tables scheduled for the re-snapshot.
+	Snapshot []string `json:"snapshot"`
+}
+
+// Reload applies the rules of the new config: creates the handlers of the added
+// and changed rules, removes the deleted rules and copies the added tables
+// using the re-snapshot if it is enabled. If the set of the tables changes,
+// the binlog reader is restarted with the new table filter at the next
+// transaction boundary.
+//
+// Only the rules and the connection credentials are taken from the new config:
+// the upstream connections are reopened at once and the binlog reader is restarted
+// with the new source credentials at the next transaction boundary. The changes of
+// the connection addresses and the replication mode are rejected, other options keep
+// their running values until restart.
+func (b *Bridge) Reload(cfg *config.Config, ehFactory EventHandlerFactory) (*ReloadResult, error) {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	if !b.Running() {
+		return nil, ErrNotRunning
+	}
+
+	if err := checkReload(b.cfg, cfg); err != nil {
+		return nil, err
+	}
+
+	db := cfg.Replication.SourceOpts.Database
+	oldRules := ruleConfigs(b.cfg)
+	newRules := ruleConfigs(cfg)
+
+	res := &ReloadResult{}
+	created := make(map[string]*mymy.Rule)
+	for table, ruleCfg := range newRules {
+		old, ok := oldRules[table]
+		if ok && old == ruleCfg {
+			continue
+		}
+
+		rule, err := b.newRule(cfg, ruleCfg, ehFactory)
+		if err != nil {
+			closeRuleHandlers(created)
+
+			return nil, fmt.Errorf("failed to create rule for table %s: %w", table, err)
+		}
+		created[table] = rule
+
+		if ok {
+			res.Updated = append(res.Updated, table)
+		} else {
+			res.Added = append(res.Added, table)
+		}
+	}
+	for table := range oldRules {
+		if _, ok := newRules[table]; !ok {
+			res.Removed = append(res.Removed, table)
+		}
+	}
+
+	if err := b.setUpstreamCredentials(cfg.Replication.UpstreamOpts); err != nil {
+		closeRuleHandlers(created)
+
+		return nil, fmt.Errorf("could not reconnect to upstream: %w", err)
+	}
+
+	replaced := make(map[string]*mymy.Rule)
+	b.rulesMu.Lock()
+	for table, rule := range created {
+		key := mymy.RuleKey(db, table)
+		if old, ok := b.rules[key]; ok {
+			replaced[table] = old
+		}
+		b.rules[key] = rule
+	}
+	for _, table := range res.Removed {
+		key := mymy.RuleKey(db, table)
+		replaced[table] = b.rules[key]
+		delete(b.rules, key)
+	}
+	b.rulesMu.Unlock()
+
+	b.closeHandlers(replaced)
+
+	// The restarted canal and the other components are built from the running config,
+	// so only the rules and the credentials are copied to it.
+	running := *b.cfg
+	running.Replication.Rules = cfg.Replication.Rules
+	running.Replication.UpstreamOpts.User = cfg.Replication.UpstreamOpts.User
+	running.Replication.UpstreamOpts.Password = cfg.Replication.UpstreamOpts.Password
+	running.Replication.SourceOpts.User = cfg.Replication.SourceOpts.User
+	running.Replication.SourceOpts.Password = cfg.Replication.SourceOpts.Password
+	sourceChanged := running.Replication.SourceOpts.User != b.cfg.Replication.SourceOpts.User ||
+		running.Replication.SourceOpts.Password != b.cfg.Replication.SourceOpts.Password
+	b.cfg = &running
+
+	// The replay reads the files without the canal.
+	if (len(res.Added) > 0 || len(res.Removed) > 0 || sourceChanged) && !b.replay {
+		b.restart.Store(true)
+	}
+
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	sort.Strings(res.Updated)
+
+	if len(res.Added) > 0 {
+		if b.snapshot.enabled() {
+			res.Snapshot = res.Added
+			go b.snapshotTables(res.Added)
+		} else {
+			b.logger.Warn().Strs("tables", res.Added).Msg("re-snapshot is disabled, the existing rows of the added tables are not copied")
+		}
+	}
+
+	b.logger.Info().
+		Strs("added", res.Added).
+		Strs("removed", res.Removed).
+		Strs("updated", res.Updated).
+		Msg("rules reloaded")
+
+	return res, nil
+}
+
+// snapshotTables copies the tables one by one waiting for the running re-snapshots.
+func (b *Bridge) snapshotTables(tables []string) {
+	for _, table := range tables {
+		for {
+			err := b.snapshot.begin(table)
+			if err == nil {
+				break
+			}
+
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(snapshotRetryInterval):
+			}
+		}
+
+		b.doSnapshot(table)
+	}
+}
+
+// setUpstreamCredentials reopens the upstream connections if the credentials are changed.
+func (b *Bridge) setUpstreamCredentials(opts config.UpstreamConfig) error {
+	user, password := opts.User, string(opts.Password)
+
+	if b.upstream != nil {
+		if err := b.upstream.SetCredentials(user, password); err != nil {
+			return err
+		}
+	}
+	if b.elector != nil {
+		if err := b.elector.client.SetCredentials(user, password); err != nil {
+			return err
+		}
+	}
+
+	return b.throttler.setCredentials(user, password)
+}
+
+// checkReload returns an error if the new config changes the options
+// which can not be applied to the running replicator.
+func checkReload(old, cfg *config.Config) error {
+	oldSrc, newSrc := old.Replication.SourceOpts, cfg.Replication.SourceOpts
+	oldUp, newUp := old.Replication.UpstreamOpts, cfg.Replication.UpstreamOpts
+
+	switch {
+	case oldSrc.Addr != newSrc.Addr:
+		return fmt.Errorf("%w: source address changed", ErrReloadUnsupported)
+	case oldSrc.Database != newSrc.Database:
+		return fmt.Errorf("%w: source database changed", ErrReloadUnsupported)
+	case oldSrc.Flavor != newSrc.Flavor:
+		return fmt.Errorf("%w: source flavor changed", ErrReloadUnsupported)
+	case oldUp.Addr != newUp.Addr:
+		return fmt.Errorf("%w: upstream address changed", ErrReloadUnsupported)
+	case old.Replication.GTIDMode != cfg.Replication.GTIDMode:
+		return fmt.Errorf("%w: GTID mode changed", ErrReloadUnsupported)
+	case !equalServerID(old.Replication.ServerID, cfg.Replication.ServerID):
+		return fmt.Errorf("%w: server id changed", ErrReloadUnsupported)
+	}
+
+	seen := make(map[string]struct{}, len(cfg.Replication.Rules))
+	for _, rule := range cfg.Replication.Rules {
+		if _, ok := seen[rule.Source.Table]; ok {
+			return fmt.Errorf("duplicate rule for table %s", rule.Source.Table)
+		}
+		seen[rule.Source.Table] = struct{}{}
+	}
+
+	return nil
+}
+
+func equalServerID(a, b *uint32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func ruleConfigs(cfg *config.Config) map[string]config.RuleConfig {
+	rules := make(map[string]config.RuleConfig, len(cfg.Replication.Rules))
+	for _, rule := range cfg.Replication.Rules {
+		rules[rule.Source.Table] = rule
+	}
+
+	return rules
+}
+
+// closeHandlers closes the handlers of the replaced rules
+// waiting for the calls in flight to finish.
+func (b *Bridge) closeHandlers(rules map[string]*mymy.Rule) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	closeRuleHandlers(rules)
+}
+
+// closeRuleHandlers releases the handlers holding resources, e.g. the handler processes.
+func closeRuleHandlers(rules map[string]*mymy.Rule) {
+	for _, rule := range rules {
+		if c, ok := rule.Handler.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
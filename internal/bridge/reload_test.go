@@ -0,0 +1,187 @@
+package bridge
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func TestCheckReload(t *testing.T) {
+	newCfg := func() *config.Config {
+		cfg := &config.Config{}
+		cfg.Replication.GTIDMode = true
+		cfg.Replication.SourceOpts.Addr = "127.0.0.1:3306"
+		cfg.Replication.SourceOpts.Database = "city"
+		cfg.Replication.SourceOpts.Flavor = config.FlavorMySQL
+		cfg.Replication.Rules = make([]config.RuleConfig, 2)
+		cfg.Replication.Rules[0].Source.Table = "users"
+		cfg.Replication.Rules[1].Source.Table = "orders"
+
+		return cfg
+	}
+	serverID := uint32(100)
+
+	tests := []struct {
+		name     string
+		modify   func(cfg *config.Config)
+		wantErr  bool
+		rejected bool
+	}{
+		{
+			name: "RulesChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.Rules[1].Source.Table = "clients"
+				cfg.Replication.UpstreamOpts.MaxRetries = 10
+			},
+		},
+		{
+			name: "AddrChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.SourceOpts.Addr = "127.0.0.1:3307"
+			},
+			wantErr:  true,
+			rejected: true,
+		},
+		{
+			name: "SourcePasswordChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.SourceOpts.Password = "secret"
+			},
+		},
+		{
+			name: "UpstreamAddrChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.UpstreamOpts.Addr = "127.0.0.1:3308"
+			},
+			wantErr:  true,
+			rejected: true,
+		},
+		{
+			name: "UpstreamUserChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.UpstreamOpts.User = "admin"
+			},
+		},
+		{
+			name: "GTIDModeChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.GTIDMode = false
+			},
+			wantErr:  true,
+			rejected: true,
+		},
+		{
+			name: "ServerIDChanged",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.ServerID = &serverID
+			},
+			wantErr:  true,
+			rejected: true,
+		},
+		{
+			name: "DuplicateRule",
+			modify: func(cfg *config.Config) {
+				cfg.Replication.Rules[1].Source.Table = "users"
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newCfg()
+			tt.modify(cfg)
+
+			err := checkReload(newCfg(), cfg)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.Error(t, err)
+			assert.Equal(t, tt.rejected, errors.Is(err, ErrReloadUnsupported))
+		})
+	}
+}
+
+type closingHandler struct {
+	mymy.EventHandler
+	closed *atomic.Bool
+}
+
+func (h *closingHandler) Close() error {
+	h.closed.Store(true)
+
+	return nil
+}
+
+func TestBridge_CloseHandlers(t *testing.T) {
+	b := &Bridge{handlersMu: &sync.RWMutex{}}
+	h := &closingHandler{
+		EventHandler: mymy.NewBaseEventHandler("clients"),
+		closed:       atomic.NewBool(false),
+	}
+	rules := map[string]*mymy.Rule{"users": {Handler: h}}
+
+	// The handler is called by the binlog reader.
+	b.handlersMu.RLock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.closeHandlers(rules)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, h.closed.Load())
+
+	b.handlersMu.RUnlock()
+	<-done
+	assert.True(t, h.closed.Load())
+}
+
+func TestTableRegexes(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Replication.SourceOpts.Database = "city"
+	cfg.Replication.SourceOpts.Snapshot.WatermarkTable = "mymy_watermark"
+	cfg.Replication.SourceOpts.Heartbeat.Table = "mymy_heartbeat"
+	cfg.Replication.Rules = make([]config.RuleConfig, 2)
+	cfg.Replication.Rules[0].Source.Table = "users"
+	cfg.Replication.Rules[1].Source.Table = "user_orders"
+
+	want := []string{
+		`^city\.users$`,
+		`^city\.user_orders$`,
+		`^city\.mymy_watermark$`,
+		`^city\.mymy_heartbeat$`,
+	}
+	assert.Equal(t, want, tableRegexes(cfg))
+}
+
+func TestBridge_StopForRestart(t *testing.T) {
+	b := &Bridge{
+		restart:   atomic.NewBool(false),
+		restartMu: &sync.Mutex{},
+	}
+	pos := newBinlogPos(mysql.Position{Name: "mysql-bin.000001", Pos: 4})
+
+	assert.False(t, b.stopForRestart(pos))
+	assert.Nil(t, b.restartPos)
+
+	b.restart.Store(true)
+	assert.True(t, b.stopForRestart(pos))
+	assert.Equal(t, pos, b.restartPos)
+
+	// The canal is restarted once per reload.
+	assert.False(t, b.stopForRestart(pos))
+}
@@ -0,0 +1,498 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+	"github.com/siddontang/go-mysql/schema"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+const maxMediumintUnsigned int32 = 16777215
+
+var (
+	ErrReplayNoGTID     = errors.New("binlog files have no GTIDs, disable gtid_mode to replay them")
+	ErrReplayFileNotSet = errors.New("start file is not in the replayed files")
+
+	// errReplayStopped stops the parsing at the stop position.
+	errReplayStopped = errors.New("replay is stopped")
+)
+
+// replayer reads the events from the local binlog files and passes them
+// to the event handler the same way the canal does with the source binlog.
+type replayer struct {
+	bridge   *Bridge
+	handler  *eventHandler
+	files    []string
+	flavor   string
+	gtidMode bool
+
+	// startFile and startPos is the binlog position to start from.
+	startFile string
+	startPos  uint32
+	// startSet is the GTID set of the transactions applied before the start.
+	startSet mysql.GTIDSet
+	startAt  time.Time
+
+	stopFile string
+	stopPos  uint32
+	stopSet  mysql.GTIDSet
+	stopAt   time.Time
+
+	// executed is the GTID set of the read transactions, nil if the binlog has no GTIDs.
+	executed mysql.GTIDSet
+	// gtid is the GTID of the current transaction.
+	gtid  string
+	inTxn bool
+	// skip is true while the transaction before the start position is read.
+	skip bool
+	// file is the name of the binlog file being read.
+	file    string
+	started bool
+	stopped bool
+	// last is the last position passed to the handler.
+	last position
+}
+
+func newReplayer(b *Bridge, cfg *config.Config, saved position) (*replayer, error) {
+	r := &replayer{
+		bridge:   b,
+		handler:  newEventHandler(b, cfg.Replication.GTIDMode, cfg.Replication.SourceOpts.Flavor),
+		files:    cfg.Replication.Replay.Files,
+		flavor:   cfg.Replication.SourceOpts.Flavor,
+		gtidMode: cfg.Replication.GTIDMode,
+	}
+
+	// The same as the replication, the start position is used only if no position is saved.
+	start := cfg.Replication.Start
+	if !isEmptyPosition(saved) {
+		switch p := saved.(type) {
+		case *gtidSet:
+			start = config.PositionConfig{GTID: p.String()}
+		case *binlogPos:
+			start = config.PositionConfig{File: p.pos.Name, Pos: p.pos.Pos}
+		}
+	}
+
+	var err error
+	r.startFile, r.startPos, r.startSet, r.startAt, err = r.parsePosition(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start position: %w", err)
+	}
+	r.stopFile, r.stopPos, r.stopSet, r.stopAt, err = r.parsePosition(cfg.Replication.Stop)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stop position: %w", err)
+	}
+
+	if r.startFile != "" && r.fileIndex(r.startFile) < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrReplayFileNotSet, r.startFile)
+	}
+	if r.startSet != nil {
+		r.executed = r.startSet.Clone()
+	}
+
+	return r, nil
+}
+
+// runReplay replays the binlog files and sets the last replayed position as the stop position,
+// so the replication is completed once everything is applied.
+func (b *Bridge) runReplay() error {
+	r, err := newReplayer(b, b.cfg, b.stateSaver.position())
+	if err != nil {
+		return err
+	}
+
+	last, err := r.run()
+	if err != nil || b.ctx.Err() != nil {
+		return err
+	}
+
+	if last == nil {
+		b.logger.Info().Msg("binlog files have nothing to replay, replication is completed")
+		b.stop.complete()
+
+		return nil
+	}
+
+	b.stop.setPosition(last)
+
+	// The sync loop may have saved the last position before the stop position is set.
+	if pos := b.stateSaver.position(); b.stop.reached(pos) {
+		if errSave := b.stateSaver.save(pos, true); errSave != nil {
+			return errSave
+		}
+
+		b.logger.Info().Str("position", pos.String()).Msg("stop position is reached, replication is completed")
+		b.stop.complete()
+	}
+
+	return nil
+}
+
+func (r *replayer) parsePosition(pc config.PositionConfig) (file string, pos uint32, set mysql.GTIDSet, at time.Time, err error) {
+	switch {
+	case pc.GTID != "":
+		set, err = mysql.ParseGTIDSet(r.flavor, pc.GTID)
+	case pc.File != "":
+		file, pos = pc.File, pc.Pos
+	case pc.Time != "":
+		at, err = pc.Timestamp()
+	}
+
+	return file, pos, set, at, err
+}
+
+func (r *replayer) fileIndex(name string) int {
+	for i, f := range r.files {
+		if filepath.Base(f) == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// run replays the files and returns the last position passed to the handler, nil if none.
+func (r *replayer) run() (position, error) {
+	files := r.files
+	if r.startFile != "" {
+		files = files[r.fileIndex(r.startFile):]
+	}
+
+	for _, path := range files {
+		r.file = filepath.Base(path)
+		r.bridge.logger.Info().Str("file", path).Msg("replaying binlog file")
+
+		parser := replication.NewBinlogParser()
+		parser.SetFlavor(r.flavor)
+		parser.SetUseDecimal(true)
+
+		err := parser.ParseFile(path, 0, r.onEvent)
+		if r.stopped {
+			return r.last, nil
+		}
+		if err != nil {
+			if r.bridge.ctx.Err() != nil {
+				return r.last, nil
+			}
+
+			return r.last, fmt.Errorf("could not replay %s: %w", path, err)
+		}
+	}
+
+	return r.last, nil
+}
+
+func (r *replayer) onEvent(ev *replication.BinlogEvent) error {
+	header := ev.Header
+	// The start of the event in the file.
+	pos := header.LogPos - header.EventSize
+
+	switch e := ev.Event.(type) {
+	case *replication.FormatDescriptionEvent, *replication.TableMapEvent:
+		return nil
+	case *replication.PreviousGTIDsEvent:
+		return r.updateExecuted(e.GTIDSets)
+	case *replication.MariadbGTIDListEvent:
+		gtids := make([]string, 0, len(e.GTIDs))
+		for _, gtid := range e.GTIDs {
+			gtids = append(gtids, gtid.String())
+		}
+
+		return r.updateExecuted(strings.Join(gtids, ","))
+	case *replication.RotateEvent:
+		if !r.started || header.LogPos == 0 {
+			return nil
+		}
+
+		return r.synced(mysql.Position{Name: string(e.NextLogName), Pos: uint32(e.Position)}, true)
+	case *replication.GTIDEvent:
+		if err := r.begin(pos, header.Timestamp); err != nil {
+			return err
+		}
+
+		// The anonymous transactions are written with the empty SID if GTID mode is off.
+		sid := e.SID
+		if strings.Trim(string(sid), "\x00") == "" {
+			return nil
+		}
+		r.gtid = fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], e.GNO)
+
+		return r.onGTID()
+	case *replication.MariadbGTIDEvent:
+		if err := r.begin(pos, header.Timestamp); err != nil {
+			return err
+		}
+		r.gtid = e.GTID.String()
+
+		return r.onGTID()
+	case *replication.QueryEvent:
+		if err := r.begin(pos, header.Timestamp); err != nil {
+			return err
+		}
+		if string(e.Query) == "BEGIN" {
+			return nil
+		}
+
+		// The DDL or the commit of the non-transactional tables ends the transaction.
+		// The DDL before the start position is applied too, the schemas are of the start of the file.
+		skip := r.commit()
+		if err := r.onDDL(e); err != nil {
+			return err
+		}
+		if skip {
+			return nil
+		}
+		if err := r.handler.OnDDL(r.position(header), e); err != nil {
+			return err
+		}
+
+		return r.synced(r.position(header), true)
+	case *replication.RowsEvent:
+		if err := r.begin(pos, header.Timestamp); err != nil {
+			return err
+		}
+		if r.skip {
+			return nil
+		}
+
+		return r.onRows(ev, e)
+	case *replication.XIDEvent:
+		skip := r.commit()
+		if skip {
+			return nil
+		}
+		if err := r.handler.OnXID(r.position(header)); err != nil {
+			return err
+		}
+
+		return r.synced(r.position(header), false)
+	}
+
+	return nil
+}
+
+func (r *replayer) position(header *replication.EventHeader) mysql.Position {
+	return mysql.Position{Name: r.file, Pos: header.LogPos}
+}
+
+// begin starts the transaction at the first event of it. The transactions before
+// the start position are skipped, the stop position stops the replay.
+func (r *replayer) begin(pos, timestamp uint32) error {
+	if r.inTxn {
+		return nil
+	}
+	r.inTxn = true
+	r.gtid = ""
+
+	if r.reachedStop(pos, timestamp) {
+		r.stopped = true
+
+		return errReplayStopped
+	}
+
+	r.skip = !r.reachedStart(pos, timestamp)
+
+	return nil
+}
+
+// onGTID skips the transaction applied before the start GTID set.
+func (r *replayer) onGTID() error {
+	if r.startSet != nil && r.startSet.Contain(r.parseGTID()) {
+		r.skip = true
+	}
+	if r.skip {
+		return nil
+	}
+
+	return r.handler.OnGTID(r.parseGTID())
+}
+
+func (r *replayer) parseGTID() mysql.GTIDSet {
+	set, err := mysql.ParseGTIDSet(r.flavor, r.gtid)
+	if err != nil {
+		return nil
+	}
+
+	return set
+}
+
+func (r *replayer) reachedStart(pos, timestamp uint32) bool {
+	if !r.started {
+		switch {
+		case r.startFile != "":
+			r.started = r.file != r.startFile || pos >= r.startPos
+		case !r.startAt.IsZero():
+			r.started = !time.Unix(int64(timestamp), 0).Before(r.startAt)
+		default:
+			r.started = true
+		}
+	}
+
+	return r.started
+}
+
+func (r *replayer) reachedStop(pos, timestamp uint32) bool {
+	switch {
+	case r.stopFile != "":
+		cur := mysql.Position{Name: r.file, Pos: pos}
+
+		return cur.Compare(mysql.Position{Name: r.stopFile, Pos: r.stopPos}) >= 0
+	case r.stopSet != nil:
+		return r.executed != nil && r.executed.Contain(r.stopSet)
+	case !r.stopAt.IsZero():
+		return !time.Unix(int64(timestamp), 0).Before(r.stopAt)
+	}
+
+	return false
+}
+
+// commit ends the transaction and returns true if it is skipped.
+func (r *replayer) commit() bool {
+	if r.gtid != "" {
+		if r.executed == nil {
+			r.executed, _ = mysql.ParseGTIDSet(r.flavor, "")
+		}
+		if r.executed != nil {
+			_ = r.executed.Update(r.gtid)
+		}
+	}
+
+	skip := r.skip
+	r.inTxn, r.skip = false, false
+
+	return skip
+}
+
+func (r *replayer) updateExecuted(gtids string) error {
+	if gtids == "" {
+		return nil
+	}
+
+	if r.executed == nil {
+		set, err := mysql.ParseGTIDSet(r.flavor, gtids)
+		if err != nil {
+			return err
+		}
+		r.executed = set
+
+		return nil
+	}
+
+	return r.executed.Update(gtids)
+}
+
+// onDDL applies the DDL to the table schemas and updates the rules the same way the canal does.
+func (r *replayer) onDDL(e *replication.QueryEvent) error {
+	changed, err := r.bridge.replaySchema.apply(string(e.Schema), string(e.Query))
+	if errors.Is(err, errParseDDL) {
+		// The same as the canal, the statement is skipped.
+		r.bridge.logger.Warn().Err(err).Str("query", string(e.Query)).Msg("skipping the query")
+
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, t := range changed {
+		err = r.handler.OnTableChanged(t.schema, t.name)
+		if err != nil && !errors.Is(err, schema.ErrTableNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *replayer) onRows(ev *replication.BinlogEvent, e *replication.RowsEvent) error {
+	db, name := string(e.Table.Schema), string(e.Table.Table)
+	// The same as the canal table filter, only the rule tables are read.
+	if _, ok := r.bridge.rule(mymy.RuleKey(db, name)); !ok {
+		return nil
+	}
+
+	table, err := r.bridge.replaySchema.GetTable(db, name)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", db, name, err)
+	}
+	if uint64(len(table.Columns)) != e.Table.ColumnCount {
+		return fmt.Errorf("%w: %s has %d columns, the binlog has %d",
+			ErrReplaySchemaMismatch, table, len(table.Columns), e.Table.ColumnCount)
+	}
+
+	var action string
+	switch ev.Header.EventType {
+	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		action = canal.InsertAction
+	case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		action = canal.DeleteAction
+	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		action = canal.UpdateAction
+	default:
+		return fmt.Errorf("%s is not supported", ev.Header.EventType)
+	}
+
+	handleUnsigned(table, e.Rows)
+
+	return r.handler.OnRow(&canal.RowsEvent{
+		Table:  table,
+		Action: action,
+		Rows:   e.Rows,
+		Header: ev.Header,
+	})
+}
+
+// synced passes the position after the transaction to the handler to save it.
+func (r *replayer) synced(pos mysql.Position, force bool) error {
+	if r.gtidMode {
+		if r.executed == nil {
+			return ErrReplayNoGTID
+		}
+		r.last = newGTIDSet(r.flavor, r.executed.Clone())
+	} else {
+		r.last = newBinlogPos(pos)
+	}
+	r.bridge.replayed.Store(r.last.String())
+
+	var set mysql.GTIDSet
+	if r.executed != nil {
+		set = r.executed.Clone()
+	}
+
+	return r.handler.OnPosSynced(pos, set, force)
+}
+
+// handleUnsigned converts the unsigned columns as the canal does, the binlog
+// has the signed integers only.
+func handleUnsigned(table *schema.Table, rows [][]interface{}) {
+	for _, row := range rows {
+		for _, idx := range table.UnsignedColumns {
+			switch value := row[idx].(type) {
+			case int8:
+				row[idx] = uint8(value)
+			case int16:
+				row[idx] = uint16(value)
+			case int32:
+				// The mediumint is a 3-byte type, so the negative value is converted manually.
+				if value < 0 && table.Columns[idx].Type == schema.TYPE_MEDIUM_INT {
+					row[idx] = uint32(maxMediumintUnsigned + value + 1)
+				} else {
+					row[idx] = uint32(value)
+				}
+			case int64:
+				row[idx] = uint64(value)
+			case int:
+				row[idx] = uint(value)
+			}
+		}
+	}
+}
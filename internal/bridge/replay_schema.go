@@ -0,0 +1,373 @@
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/charset"
+	"github.com/siddontang/go-mysql/schema"
+
+	"github.com/city-mobil/go-mymy/internal/util"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+var (
+	ErrReplayNoSchema       = errors.New("replay requires the table schemas in replication.replay.schema_file")
+	ErrReplaySchemaMismatch = errors.New("table schema does not match the binlog")
+
+	// errParseDDL is returned if the statements are not parsed.
+	errParseDDL = errors.New("could not parse DDL")
+)
+
+// replaySchema keeps the table schemas for the replay instead of the source:
+// they are read from the schema file and changed by the DDL of the replayed files.
+type replaySchema struct {
+	database string
+	tables   map[string]*replayTable
+
+	mu *sync.RWMutex
+}
+
+// tableName is the schema and the name of the table.
+type tableName struct {
+	schema string
+	name   string
+}
+
+func (n tableName) key() string {
+	return mymy.RuleKey(n.schema, n.name)
+}
+
+// replayTable is the table definition the schema is built from.
+type replayTable struct {
+	tableName
+
+	cols []*ast.ColumnDef
+	// pks are the lower case names of the primary key columns.
+	pks []string
+}
+
+// newReplaySchema reads the CREATE TABLE statements from the file, e.g. made by mysqldump --no-data.
+// The tables without the database name belong to the source database.
+func newReplaySchema(path, database string) (*replaySchema, error) {
+	if path == "" {
+		return nil, ErrReplayNoSchema
+	}
+
+	data, err := ioutil.ReadFile(util.AbsPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not read schema file: %w", err)
+	}
+
+	s := &replaySchema{
+		database: database,
+		tables:   make(map[string]*replayTable),
+		mu:       &sync.RWMutex{},
+	}
+
+	if _, err = s.apply(database, string(data)); err != nil {
+		return nil, fmt.Errorf("could not parse schema file %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+// GetTable returns the table schema the same way the canal does.
+func (s *replaySchema) GetTable(db, table string) (*schema.Table, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.tables[mymy.RuleKey(db, table)]
+	if !ok {
+		return nil, schema.ErrTableNotExist
+	}
+
+	return t.build(), nil
+}
+
+// apply changes the schemas by the DDL statements and returns the changed tables.
+// The statements not changing the table columns are skipped.
+func (s *replaySchema) apply(db, query string) ([]tableName, error) {
+	stmts, _, err := parser.New().Parse(query, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errParseDDL, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []tableName
+	for _, stmt := range stmts {
+		switch v := stmt.(type) {
+		case *ast.CreateTableStmt:
+			changed = append(changed, s.create(db, v))
+		case *ast.DropTableStmt:
+			if v.IsView {
+				continue
+			}
+			for _, tn := range v.Tables {
+				name := s.name(db, tn)
+				delete(s.tables, name.key())
+				changed = append(changed, name)
+			}
+		case *ast.RenameTableStmt:
+			for _, tt := range v.TableToTables {
+				changed = append(changed, s.rename(db, tt.OldTable, tt.NewTable)...)
+			}
+		case *ast.AlterTableStmt:
+			keys, errAlter := s.alter(db, v)
+			if errAlter != nil {
+				return nil, errAlter
+			}
+			changed = append(changed, keys...)
+		}
+	}
+
+	return changed, nil
+}
+
+func (s *replaySchema) name(db string, tn *ast.TableName) tableName {
+	if tn.Schema.O != "" {
+		db = tn.Schema.O
+	}
+	if db == "" {
+		db = s.database
+	}
+
+	return tableName{schema: db, name: tn.Name.O}
+}
+
+func (s *replaySchema) create(db string, stmt *ast.CreateTableStmt) tableName {
+	t := &replayTable{tableName: s.name(db, stmt.Table)}
+	if stmt.ReferTable != nil {
+		if src, ok := s.tables[s.name(db, stmt.ReferTable).key()]; ok {
+			t.cols = append(t.cols, src.cols...)
+			t.pks = append(t.pks, src.pks...)
+		}
+	}
+
+	for _, col := range stmt.Cols {
+		t.cols = append(t.cols, col)
+		t.addPK(col)
+	}
+	for _, c := range stmt.Constraints {
+		if c.Tp == ast.ConstraintPrimaryKey {
+			t.setPK(c.Keys)
+		}
+	}
+
+	s.tables[t.key()] = t
+
+	return t.tableName
+}
+
+func (s *replaySchema) rename(db string, from, to *ast.TableName) []tableName {
+	oldName, newName := s.name(db, from), s.name(db, to)
+
+	t, ok := s.tables[oldName.key()]
+	if !ok {
+		return nil
+	}
+	delete(s.tables, oldName.key())
+	t.tableName = newName
+	s.tables[newName.key()] = t
+
+	return []tableName{oldName, newName}
+}
+
+func (s *replaySchema) alter(db string, stmt *ast.AlterTableStmt) ([]tableName, error) {
+	name := s.name(db, stmt.Table)
+
+	t, ok := s.tables[name.key()]
+	if !ok {
+		return nil, nil
+	}
+
+	changed := []tableName{name}
+	for _, spec := range stmt.Specs {
+		var err error
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			pos := spec.Position
+			for _, col := range spec.NewColumns {
+				err = t.insert(col, pos)
+				if err != nil {
+					break
+				}
+				// The next columns of ADD COLUMN (a, b) AFTER c follow the previous one.
+				if pos != nil && pos.Tp != ast.ColumnPositionNone {
+					pos = &ast.ColumnPosition{Tp: ast.ColumnPositionAfter, RelativeColumn: col.Name}
+				}
+			}
+		case ast.AlterTableDropColumn:
+			err = t.drop(spec.OldColumnName.Name.L)
+		case ast.AlterTableModifyColumn:
+			err = t.replace(spec.NewColumns[0].Name.Name.L, spec.NewColumns[0], spec.Position)
+		case ast.AlterTableChangeColumn:
+			err = t.replace(spec.OldColumnName.Name.L, spec.NewColumns[0], spec.Position)
+		case ast.AlterTableAddConstraint:
+			if spec.Constraint.Tp == ast.ConstraintPrimaryKey {
+				t.setPK(spec.Constraint.Keys)
+			}
+		case ast.AlterTableDropPrimaryKey:
+			t.pks = nil
+		case ast.AlterTableRenameTable:
+			changed = append(changed, s.rename(db, stmt.Table, spec.NewTable)...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("table %s.%s: %w", name.schema, name.name, err)
+		}
+	}
+
+	return changed, nil
+}
+
+func (t *replayTable) index(name string) int {
+	for i, col := range t.cols {
+		if col.Name.Name.L == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (t *replayTable) insert(col *ast.ColumnDef, pos *ast.ColumnPosition) error {
+	idx := len(t.cols)
+	if pos != nil {
+		switch pos.Tp {
+		case ast.ColumnPositionFirst:
+			idx = 0
+		case ast.ColumnPositionAfter:
+			idx = t.index(pos.RelativeColumn.Name.L)
+			if idx < 0 {
+				return fmt.Errorf("unknown column %s", pos.RelativeColumn.Name.O)
+			}
+			idx++
+		}
+	}
+
+	t.cols = append(t.cols, nil)
+	copy(t.cols[idx+1:], t.cols[idx:])
+	t.cols[idx] = col
+	t.addPK(col)
+
+	return nil
+}
+
+func (t *replayTable) drop(name string) error {
+	idx := t.index(name)
+	if idx < 0 {
+		return fmt.Errorf("unknown column %s", name)
+	}
+	t.cols = append(t.cols[:idx], t.cols[idx+1:]...)
+
+	for i, pk := range t.pks {
+		if pk == name {
+			t.pks = append(t.pks[:i], t.pks[i+1:]...)
+
+			break
+		}
+	}
+
+	return nil
+}
+
+// replace changes the column definition, the name and the position of it.
+func (t *replayTable) replace(name string, col *ast.ColumnDef, pos *ast.ColumnPosition) error {
+	idx := t.index(name)
+	if idx < 0 {
+		return fmt.Errorf("unknown column %s", name)
+	}
+
+	for i, pk := range t.pks {
+		if pk == name {
+			t.pks[i] = col.Name.Name.L
+		}
+	}
+
+	if pos == nil || pos.Tp == ast.ColumnPositionNone {
+		t.cols[idx] = col
+		t.addPK(col)
+
+		return nil
+	}
+
+	t.cols = append(t.cols[:idx], t.cols[idx+1:]...)
+
+	return t.insert(col, pos)
+}
+
+func (t *replayTable) addPK(col *ast.ColumnDef) {
+	for _, opt := range col.Options {
+		if opt.Tp == ast.ColumnOptionPrimaryKey {
+			t.pks = []string{col.Name.Name.L}
+		}
+	}
+}
+
+func (t *replayTable) setPK(keys []*ast.IndexColName) {
+	t.pks = make([]string, 0, len(keys))
+	for _, key := range keys {
+		t.pks = append(t.pks, key.Column.Name.L)
+	}
+}
+
+// build returns the schema as it is read from the source by the canal.
+func (t *replayTable) build() *schema.Table {
+	table := &schema.Table{
+		Schema: t.schema,
+		Name:   t.name,
+	}
+
+	for _, col := range t.cols {
+		table.AddColumn(col.Name.Name.O, strings.ToLower(col.Tp.InfoSchemaStr()), columnCollation(col), columnExtra(col))
+	}
+
+	if len(t.pks) > 0 {
+		idx := table.AddIndex("PRIMARY")
+		for _, pk := range t.pks {
+			i := t.index(pk)
+			if i < 0 {
+				continue
+			}
+			idx.AddColumn(table.Columns[i].Name, 0)
+			table.PKColumns = append(table.PKColumns, i)
+		}
+	}
+
+	return table
+}
+
+func columnCollation(col *ast.ColumnDef) string {
+	for _, opt := range col.Options {
+		if opt.Tp == ast.ColumnOptionCollate {
+			return opt.StrValue
+		}
+	}
+
+	if col.Tp.Collate == charset.CollationBin {
+		return ""
+	}
+
+	return col.Tp.Collate
+}
+
+// columnExtra returns the column extra as it is shown by SHOW FULL COLUMNS.
+func columnExtra(col *ast.ColumnDef) string {
+	for _, opt := range col.Options {
+		switch {
+		case opt.Tp == ast.ColumnOptionAutoIncrement:
+			return "auto_increment"
+		case opt.Tp == ast.ColumnOptionGenerated && !opt.Stored:
+			return "VIRTUAL GENERATED"
+		}
+	}
+
+	return ""
+}
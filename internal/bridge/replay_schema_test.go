@@ -0,0 +1,208 @@
+package bridge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/siddontang/go-mysql/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const replaySchemaDump = "/*!40101 SET @saved_cs_client     = @@character_set_client */;\n" +
+	"DROP TABLE IF EXISTS `users`;\n" +
+	"CREATE TABLE `users` (\n" +
+	"  `id` int unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `name` varchar(255) COLLATE utf8mb4_0900_ai_ci NOT NULL DEFAULT '',\n" +
+	"  `level` mediumint unsigned DEFAULT NULL,\n" +
+	"  `status` enum('active','banned') NOT NULL,\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;\n" +
+	"CREATE TABLE `logs` (`id` bigint PRIMARY KEY, `message` text);\n"
+
+func TestReplaySchema_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-replay")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "schema.sql")
+	require.NoError(t, ioutil.WriteFile(path, []byte(replaySchemaDump), 0600))
+
+	s, err := newReplaySchema(path, "city")
+	require.NoError(t, err)
+
+	users, err := s.GetTable("city", "users")
+	require.NoError(t, err)
+	assert.Equal(t, "city", users.Schema)
+	assert.Equal(t, []string{"id", "name", "level", "status"}, columnNames(users))
+	assert.Equal(t, []int{0}, users.PKColumns)
+	assert.Equal(t, []int{0, 2}, users.UnsignedColumns)
+	assert.True(t, users.Columns[0].IsAuto)
+	assert.Equal(t, "utf8mb4_0900_ai_ci", users.Columns[1].Collation)
+	assert.Equal(t, schema.TYPE_MEDIUM_INT, users.Columns[2].Type)
+	assert.Equal(t, []string{"active", "banned"}, users.Columns[3].EnumValues)
+
+	logs, err := s.GetTable("city", "logs")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, logs.PKColumns)
+
+	_, err = s.GetTable("city", "orders")
+	assert.ErrorIs(t, err, schema.ErrTableNotExist)
+
+	_, err = newReplaySchema("", "city")
+	assert.ErrorIs(t, err, ErrReplayNoSchema)
+}
+
+func TestReplaySchema_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      string
+		query   string
+		table   string
+		cols    []string
+		pks     []string
+		changed []tableName
+		missing []string
+		wantErr bool
+		errIs   error
+	}{
+		{
+			name:    "AddColumns",
+			query:   "ALTER TABLE users ADD COLUMN email varchar(64) AFTER id, ADD COLUMN age tinyint FIRST",
+			table:   "users",
+			cols:    []string{"age", "id", "email", "name"},
+			pks:     []string{"id"},
+			changed: []tableName{{"city", "users"}},
+		},
+		{
+			name:    "AddColumnList",
+			query:   "ALTER TABLE users ADD COLUMN (a int, b int)",
+			table:   "users",
+			cols:    []string{"id", "name", "a", "b"},
+			pks:     []string{"id"},
+			changed: []tableName{{"city", "users"}},
+		},
+		{
+			name:    "ChangeColumn",
+			query:   "ALTER TABLE `city`.`users` CHANGE `id` `user_id` bigint NOT NULL AFTER name",
+			table:   "users",
+			cols:    []string{"name", "user_id"},
+			pks:     []string{"user_id"},
+			changed: []tableName{{"city", "users"}},
+		},
+		{
+			name:    "ModifyAndDropColumn",
+			query:   "ALTER TABLE users MODIFY name text FIRST, DROP COLUMN id, ADD PRIMARY KEY (name)",
+			table:   "users",
+			cols:    []string{"name"},
+			pks:     []string{"name"},
+			changed: []tableName{{"city", "users"}},
+		},
+		{
+			name:    "DropPrimaryKey",
+			query:   "ALTER TABLE users DROP PRIMARY KEY",
+			table:   "users",
+			cols:    []string{"id", "name"},
+			changed: []tableName{{"city", "users"}},
+		},
+		{
+			name:    "RenameTable",
+			query:   "RENAME TABLE users TO clients",
+			table:   "clients",
+			cols:    []string{"id", "name"},
+			pks:     []string{"id"},
+			changed: []tableName{{"city", "users"}, {"city", "clients"}},
+			missing: []string{"users"},
+		},
+		{
+			name:    "CreateLike",
+			db:      "city",
+			query:   "CREATE TABLE clients LIKE users",
+			table:   "clients",
+			cols:    []string{"id", "name"},
+			pks:     []string{"id"},
+			changed: []tableName{{"city", "clients"}},
+		},
+		{
+			name:    "DropTable",
+			query:   "DROP TABLE users",
+			changed: []tableName{{"city", "users"}},
+			missing: []string{"users"},
+		},
+		{
+			name:  "OtherDatabase",
+			query: "ALTER TABLE town.users DROP COLUMN name",
+			table: "users",
+			cols:  []string{"id", "name"},
+			pks:   []string{"id"},
+		},
+		{
+			name:    "UnknownColumn",
+			query:   "ALTER TABLE users DROP COLUMN email",
+			wantErr: true,
+		},
+		{
+			name:    "NotParsed",
+			query:   "ALTER TABLE users FOO",
+			wantErr: true,
+			errIs:   errParseDDL,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := &replaySchema{
+				database: "city",
+				tables:   make(map[string]*replayTable),
+				mu:       &sync.RWMutex{},
+			}
+			_, err := s.apply("city", "CREATE TABLE users (id int PRIMARY KEY, name varchar(32))")
+			require.NoError(t, err)
+
+			changed, err := s.apply(tt.db, tt.query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.errIs != nil {
+					assert.ErrorIs(t, err, tt.errIs)
+				}
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.changed, changed)
+
+			for _, name := range tt.missing {
+				_, err = s.GetTable("city", name)
+				assert.ErrorIs(t, err, schema.ErrTableNotExist)
+			}
+			if tt.table == "" {
+				return
+			}
+
+			table, err := s.GetTable("city", tt.table)
+			require.NoError(t, err)
+			assert.Equal(t, tt.cols, columnNames(table))
+
+			var pks []string
+			for _, idx := range table.PKColumns {
+				pks = append(pks, table.Columns[idx].Name)
+			}
+			assert.Equal(t, tt.pks, pks)
+		})
+	}
+}
+
+func columnNames(table *schema.Table) []string {
+	names := make([]string, 0, len(table.Columns))
+	for _, col := range table.Columns {
+		names = append(names, col.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,178 @@
+package bridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayer_Begin(t *testing.T) {
+	const uuid = "de278ad0-2106-11e4-9f8e-6edd0ca20947"
+
+	at := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	ts := uint32(at.Unix())
+
+	parse := func(s string) mysql.GTIDSet {
+		set, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, s)
+		require.NoError(t, err)
+
+		return set
+	}
+
+	tests := []struct {
+		name        string
+		r           *replayer
+		pos         uint32
+		timestamp   uint32
+		wantSkip    bool
+		wantStopped bool
+	}{
+		{
+			name:      "NoPositions",
+			r:         &replayer{file: "mysql-bin.000001"},
+			pos:       4,
+			timestamp: ts,
+		},
+		{
+			name:      "BeforeStartPos",
+			r:         &replayer{file: "mysql-bin.000001", startFile: "mysql-bin.000001", startPos: 1000},
+			pos:       500,
+			timestamp: ts,
+			wantSkip:  true,
+		},
+		{
+			name:      "AtStartPos",
+			r:         &replayer{file: "mysql-bin.000001", startFile: "mysql-bin.000001", startPos: 1000},
+			pos:       1000,
+			timestamp: ts,
+		},
+		{
+			name:      "AfterStartFile",
+			r:         &replayer{file: "mysql-bin.000002", startFile: "mysql-bin.000001", startPos: 1000},
+			pos:       4,
+			timestamp: ts,
+		},
+		{
+			name:      "BeforeStartTime",
+			r:         &replayer{file: "mysql-bin.000001", startAt: at.Add(time.Second)},
+			pos:       4,
+			timestamp: ts,
+			wantSkip:  true,
+		},
+		{
+			name:      "AtStartTime",
+			r:         &replayer{file: "mysql-bin.000001", startAt: at},
+			pos:       4,
+			timestamp: ts,
+		},
+		{
+			name:      "BeforeStopPos",
+			r:         &replayer{file: "mysql-bin.000001", stopFile: "mysql-bin.000001", stopPos: 1000},
+			pos:       500,
+			timestamp: ts,
+		},
+		{
+			name:        "AtStopPos",
+			r:           &replayer{file: "mysql-bin.000001", stopFile: "mysql-bin.000001", stopPos: 1000},
+			pos:         1000,
+			timestamp:   ts,
+			wantStopped: true,
+		},
+		{
+			name:        "AfterStopFile",
+			r:           &replayer{file: "mysql-bin.000002", stopFile: "mysql-bin.000001", stopPos: 1000},
+			pos:         4,
+			timestamp:   ts,
+			wantStopped: true,
+		},
+		{
+			name:        "AtStopTime",
+			r:           &replayer{file: "mysql-bin.000001", stopAt: at},
+			pos:         4,
+			timestamp:   ts,
+			wantStopped: true,
+		},
+		{
+			name:      "BeforeStopGTID",
+			r:         &replayer{file: "mysql-bin.000001", executed: parse(uuid + ":1-9"), stopSet: parse(uuid + ":1-10")},
+			pos:       4,
+			timestamp: ts,
+		},
+		{
+			name:        "AtStopGTID",
+			r:           &replayer{file: "mysql-bin.000001", executed: parse(uuid + ":1-10"), stopSet: parse(uuid + ":1-10")},
+			pos:         4,
+			timestamp:   ts,
+			wantStopped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.r.begin(tt.pos, tt.timestamp)
+			if tt.wantStopped {
+				assert.ErrorIs(t, err, errReplayStopped)
+				assert.True(t, tt.r.stopped)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSkip, tt.r.skip)
+		})
+	}
+}
+
+func TestReplayer_Commit(t *testing.T) {
+	const uuid = "de278ad0-2106-11e4-9f8e-6edd0ca20947"
+
+	r := &replayer{flavor: mysql.MySQLFlavor, file: "mysql-bin.000001", startFile: "mysql-bin.000001", startPos: 1000}
+
+	// The skipped transaction is executed before the start, so it is added to the executed set.
+	require.NoError(t, r.begin(500, 0))
+	r.gtid = uuid + ":1"
+	assert.True(t, r.commit())
+
+	require.NoError(t, r.begin(1000, 0))
+	r.gtid = uuid + ":2"
+	assert.False(t, r.commit())
+
+	// The started replay does not skip the transactions anymore.
+	require.NoError(t, r.begin(500, 0))
+	assert.False(t, r.commit())
+
+	require.NotNil(t, r.executed)
+	assert.Equal(t, uuid+":1-2", r.executed.String())
+}
+
+func TestHandleUnsigned(t *testing.T) {
+	table := &schema.Table{
+		Columns: []schema.TableColumn{
+			{Name: "tiny", Type: schema.TYPE_NUMBER},
+			{Name: "medium", Type: schema.TYPE_MEDIUM_INT},
+			{Name: "int", Type: schema.TYPE_NUMBER},
+			{Name: "big", Type: schema.TYPE_NUMBER},
+			{Name: "signed", Type: schema.TYPE_NUMBER},
+		},
+		UnsignedColumns: []int{0, 1, 2, 3},
+	}
+
+	rows := [][]interface{}{
+		{int8(-1), int32(-1), int32(-1), int64(-1), int32(-1)},
+		{int8(1), int32(1), int32(1), int64(1), int32(1)},
+		{nil, nil, nil, nil, nil},
+	}
+
+	handleUnsigned(table, rows)
+
+	assert.Equal(t, [][]interface{}{
+		{uint8(255), uint32(16777215), uint32(4294967295), uint64(18446744073709551615), int32(-1)},
+		{uint8(1), uint32(1), uint32(1), uint64(1), int32(1)},
+		{nil, nil, nil, nil, nil},
+	}, rows)
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/siddontang/go-mysql/canal"
-	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/schema"
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
 
@@ -20,7 +21,6 @@ import (
 )
 
 const (
-	eventsBufSize    = 4096
 	dumpPollInterval = 25 * time.Millisecond
 )
 
@@ -29,11 +29,49 @@ var ErrRuleNotExist = errors.New("rule is not exist")
 type batch []*mymy.Query
 
 type Bridge struct {
-	rules map[string]*mymy.Rule
-
-	canal      *canal.Canal
+	cfg      *config.Config
+	rules    map[string]*mymy.Rule
+	rulesMu  *sync.RWMutex
+	reloadMu *sync.Mutex
+	// handlersMu is held for reading while the rule handlers are called,
+	// so the reload closes the replaced handlers after the calls in flight.
+	handlersMu *sync.RWMutex
+
+	// canal is replaced by the reload changing the replicated tables,
+	// use currentCanal outside of the binlog reader goroutine. It is nil on replay.
+	canal   *canal.Canal
+	canalMu *sync.RWMutex
+	// restart asks the binlog reader to stop at the next transaction
+	// boundary, so the canal is recreated with the new table filter.
+	restart *atomic.Bool
+	// restartPos is the position to start the recreated canal from.
+	restartPos position
+	restartMu  *sync.Mutex
+	serverID   uint32
+	// replay is true if the events are read from the local binlog files instead of the source.
+	replay bool
+	// replaySchema keeps the table schemas on replay, nil otherwise.
+	replaySchema *replaySchema
+	// replayed is the last replayed position.
+	replayed *atomic.String
+	// upstream is the upstream database connection, nil if the queries are written to the files.
 	upstream   *client.SQLClient
+	sink       sink
 	stateSaver stateSaver
+	elector    *elector
+	snapshot   *snapshotter
+	heartbeat  *heartbeat
+	pauser     *pauser
+	throttler  *throttler
+	skipper    *skipper
+	stats      *ruleStats
+	stop       *stopper
+	delayer    *delayer
+	// dryRun writes the statements out instead of executing them, nil if disabled.
+	dryRun *dryRun
+
+	gtidMode     bool
+	pauseOnError bool
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -44,7 +82,7 @@ type Bridge struct {
 	syncedAt *atomic.Int64
 
 	dumpDoneCh chan struct{}
-	syncCh     chan interface{}
+	queue      *eventQueue
 	closeOnce  *sync.Once
 
 	dumpLoadInFileEnabled        bool
@@ -54,42 +92,116 @@ type Bridge struct {
 
 func New(cfg *config.Config, ehFactory EventHandlerFactory, logger zerolog.Logger) (*Bridge, error) {
 	b := &Bridge{
+		cfg:        cfg,
+		rulesMu:    &sync.RWMutex{},
+		reloadMu:   &sync.Mutex{},
+		handlersMu: &sync.RWMutex{},
+		canalMu:    &sync.RWMutex{},
+		restartMu:  &sync.Mutex{},
 		logger:     logger,
 		dumping:    atomic.NewBool(false),
 		running:    atomic.NewBool(false),
+		restart:    atomic.NewBool(false),
 		syncedAt:   atomic.NewInt64(0),
+		replayed:   atomic.NewString(""),
 		dumpDoneCh: make(chan struct{}),
-		syncCh:     make(chan interface{}, eventsBufSize),
 		closeOnce:  &sync.Once{},
+		snapshot:   newSnapshotter(cfg),
+		pauser:     newPauser(),
+		skipper:    newSkipper(),
+		stats:      newRuleStats(),
+		delayer:    newDelayer(cfg.Replication.Delay),
+
+		replay:       cfg.Replication.Replay.Enabled(),
+		gtidMode:     cfg.Replication.GTIDMode,
+		pauseOnError: cfg.Replication.PauseOnError,
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	b.ctx = ctx
 	b.cancel = cancel
 
-	if err := b.newStateSaver(cfg); err != nil {
+	queue, err := newEventQueue(cfg.Replication.Buffer)
+	if err != nil {
 		return nil, err
 	}
+	b.queue = queue
 
-	if err := b.newCanal(cfg); err != nil {
+	b.serverID = canal.NewDefaultConfig().ServerID
+	if cfg.Replication.ServerID != nil {
+		b.serverID = *cfg.Replication.ServerID
+	}
+
+	// The replay does not connect to the source, the table schemas are read from the file.
+	if b.replay {
+		b.replaySchema, err = newReplaySchema(cfg.Replication.Replay.SchemaFile, cfg.Replication.SourceOpts.Database)
+	} else {
+		b.canal, err = b.newCanal(cfg)
+	}
+	if err != nil {
 		return nil, err
 	}
+	b.heartbeat = newHeartbeat(cfg, b.serverID)
 
 	if err := b.newRules(cfg, ehFactory); err != nil {
 		return nil, err
 	}
 
 	// We must use binlog full row image.
-	if err := b.canal.CheckBinlogRowImage("FULL"); err != nil {
+	if !b.replay {
+		if err := b.canal.CheckBinlogRowImage("FULL"); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Replication.Sink.Type == config.SinkUpstream {
+		if err := b.newUpstream(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	b.sink, err = newSink(cfg, b.upstream, logger)
+	if err != nil {
 		return nil, err
 	}
 
-	if err := b.newUpstream(cfg); err != nil {
+	if cfg.Replication.DryRun.Enabled {
+		d, err := newDryRun(cfg.Replication.DryRun, logger)
+		if err != nil {
+			return nil, err
+		}
+		b.dryRun = d
+
+		logger.Warn().Msg("dry run: the statements are not executed and the position is not saved")
+	}
+
+	// The dry run does not write and the replay does not follow the source,
+	// so they do not compete for the leadership.
+	if cfg.App.HA.Enabled && b.dryRun == nil && !b.replay {
+		e, err := newElector(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		b.elector = e
+	}
+
+	if err := b.newStateSaver(cfg); err != nil {
 		return nil, err
 	}
 
+	b.stop, err = newStopper(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	throttler, err := newThrottler(ctx, cfg, b.upstream, logger)
+	if err != nil {
+		return nil, err
+	}
+	b.throttler = throttler
+
 	dumpCfg := cfg.Replication.SourceOpts.Dump
-	loaderCfg := newLoaderConfig(cfg, b.upstream)
+	loaderCfg := newLoaderConfig(cfg, b.upstream, b.throttler, b.dryRun)
 	b.dumpLoadInFileEnabled = dumpCfg.LoadInFileEnabled
 	b.dumpLoadInFileFlushThreshold = dumpCfg.LoadInFileFlushThreshold
 	b.dumpInFileLoader = newInFileLoader(loaderCfg)
@@ -98,57 +210,57 @@ func New(cfg *config.Config, ehFactory EventHandlerFactory, logger zerolog.Logge
 }
 
 func (b *Bridge) newStateSaver(cfg *config.Config) error {
-	fs, err := newFileSaver(cfg.App.DataFile, cfg.Replication.GTIDMode)
+	// The dry run only reads the saved position, the saver would write it back on close.
+	if b.dryRun != nil {
+		pos, err := readPosition(cfg, b.upstream)
+		if err != nil {
+			return err
+		}
+		b.stateSaver = newMemorySaver(pos)
+
+		return nil
+	}
+
+	var (
+		saver stateSaver
+		err   error
+	)
+
+	gtidMode := cfg.Replication.GTIDMode
+	flavor := cfg.Replication.SourceOpts.Flavor
+	if state := cfg.App.State; state.Table != "" {
+		saver, err = newTableSaver(b.upstream, state.Table, state.Key, gtidMode, flavor, b.elector)
+	} else {
+		saver, err = newFileSaver(cfg.App.DataFile, gtidMode, flavor)
+	}
 	if err != nil {
 		return err
 	}
 
-	_, err = fs.load()
-	if err != nil {
+	if _, err = saver.load(); err != nil {
 		return err
 	}
 
-	b.stateSaver = fs
+	if fs, ok := b.sink.(*fileSink); ok {
+		saver = newFileSinkSaver(saver, fs, cfg.Replication.Sink.File.SyncInterval)
+	}
+
+	b.stateSaver = saver
 
 	return nil
 }
 
 func (b *Bridge) newRules(cfg *config.Config, ehFactory EventHandlerFactory) error {
 	db := cfg.Replication.SourceOpts.Database
-	pluginDir := cfg.App.PluginDir
 
 	rules := make(map[string]*mymy.Rule, len(cfg.Replication.Rules))
-	for _, rule := range cfg.Replication.Rules {
-		pluginCfg := rule.Upstream.Plugin
-		uh, err := ehFactory.New(pluginCfg.Name, pluginCfg.Config)
-		if err != nil {
-			return fmt.Errorf("create handler error: plugin dir: %s, name: %s, err: %w", pluginDir, pluginCfg.Name, err)
-		}
-
-		table := rule.Source.Table
-		tableInfo, err := b.canal.GetTable(db, table)
+	for _, ruleCfg := range cfg.Replication.Rules {
+		rule, err := b.newRule(cfg, ruleCfg, ehFactory)
 		if err != nil {
 			return err
 		}
 
-		pks := newColumnsFromPKs(tableInfo)
-		if len(pks) == 0 {
-			return fmt.Errorf("no primary keys found, schema: %s, table: %s", db, table)
-		}
-
-		cols := newColumnsFromNonPKs(tableInfo)
-
-		rule := &mymy.Rule{
-			Source: mymy.SourceInfo{
-				Schema: db,
-				Table:  table,
-				PKs:    pks,
-				Cols:   cols,
-			},
-			Handler: uh,
-		}
-
-		key := mymy.RuleKey(db, table)
+		key := mymy.RuleKey(db, ruleCfg.Source.Table)
 		rules[key] = rule
 	}
 
@@ -158,13 +270,49 @@ func (b *Bridge) newRules(cfg *config.Config, ehFactory EventHandlerFactory) err
 	return nil
 }
 
+func (b *Bridge) newRule(cfg *config.Config, ruleCfg config.RuleConfig, ehFactory EventHandlerFactory) (*mymy.Rule, error) {
+	pluginCfg := ruleCfg.Upstream.Plugin
+	uh, err := ehFactory.New(pluginCfg.Name, pluginCfg.Config)
+	if err != nil {
+		return nil, fmt.Errorf("create handler error: plugin dir: %s, name: %s, err: %w", cfg.App.PluginDir, pluginCfg.Name, err)
+	}
+
+	tableInfo, err := b.getTable(cfg.Replication.SourceOpts.Database, ruleCfg.Source.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := newSourceInfo(tableInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mymy.Rule{
+		Source:  info,
+		Handler: uh,
+	}, nil
+}
+
+// rule returns the rule of the source table.
+func (b *Bridge) rule(key string) (*mymy.Rule, bool) {
+	b.rulesMu.RLock()
+	defer b.rulesMu.RUnlock()
+
+	rule, ok := b.rules[key]
+
+	return rule, ok
+}
+
 func (b *Bridge) updateRule(schema, table string) (*mymy.Rule, error) {
+	b.rulesMu.Lock()
+	defer b.rulesMu.Unlock()
+
 	rule, ok := b.rules[mymy.RuleKey(schema, table)]
 	if !ok {
 		return nil, ErrRuleNotExist
 	}
 
-	tableInfo, err := b.canal.GetTable(schema, table)
+	tableInfo, err := b.getTable(schema, table)
 	if err != nil {
 		return nil, err
 	}
@@ -175,46 +323,174 @@ func (b *Bridge) updateRule(schema, table string) (*mymy.Rule, error) {
 	return rule, nil
 }
 
-func (b *Bridge) newCanal(cfg *config.Config) error {
+func (b *Bridge) newCanal(cfg *config.Config) (*canal.Canal, error) {
 	canalCfg := canal.NewDefaultConfig()
 
 	myCfg := cfg.Replication.SourceOpts
-	if cfg.Replication.ServerID != nil {
-		canalCfg.ServerID = *cfg.Replication.ServerID
-	}
+	// The recreated canal keeps the server id, the heartbeats are told apart by it.
+	canalCfg.ServerID = b.serverID
 	canalCfg.Addr = myCfg.Addr
 	canalCfg.User = myCfg.User
-	canalCfg.Password = myCfg.Password
+	canalCfg.Password = string(myCfg.Password)
 	canalCfg.Charset = myCfg.Charset
-	canalCfg.Flavor = mysql.MySQLFlavor
+	canalCfg.Flavor = myCfg.Flavor
 	canalCfg.SemiSyncEnabled = false
 
+	tlsCfg, err := sourceTLSConfig(myCfg)
+	if err != nil {
+		return nil, err
+	}
+	canalCfg.TLSConfig = tlsCfg
+
 	canalCfg.Dump.ExecutionPath = myCfg.Dump.ExecPath
 	canalCfg.Dump.DiscardErr = false
 	canalCfg.Dump.SkipMasterData = myCfg.Dump.SkipMasterData
-	canalCfg.Dump.ExtraOptions = myCfg.Dump.ExtraOptions
+	canalCfg.Dump.ExtraOptions = append(dumpTLSOptions(myCfg.TLS, myCfg.Flavor), myCfg.Dump.ExtraOptions...)
 
-	syncOnly := make([]string, 0, len(cfg.Replication.Rules))
-	for _, mapping := range cfg.Replication.Rules {
-		regex := fmt.Sprintf("%s\\.%s", myCfg.Database, mapping.Source.Table)
-		syncOnly = append(syncOnly, regex)
-	}
-	canalCfg.IncludeTableRegex = syncOnly
+	canalCfg.IncludeTableRegex = tableRegexes(cfg)
 
 	cn, err := canal.NewCanal(canalCfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	eH := newEventHandler(b, cfg.Replication.GTIDMode)
+	eH := newEventHandler(b, cfg.Replication.GTIDMode, cfg.Replication.SourceOpts.Flavor)
 	cn.SetEventHandler(eH)
 
+	return cn, nil
+}
+
+// tableRegexes returns the canal table filter matching the rule tables
+// and the service tables written by the replicator.
+func tableRegexes(cfg *config.Config) []string {
+	opts := cfg.Replication.SourceOpts
+
+	tables := ruleTables(cfg)
+	if opts.Snapshot.WatermarkTable != "" {
+		tables = append(tables, opts.Snapshot.WatermarkTable)
+	}
+	if opts.Heartbeat.Table != "" {
+		tables = append(tables, opts.Heartbeat.Table)
+	}
+
+	regexes := make([]string, 0, len(tables))
+	for _, table := range tables {
+		regexes = append(regexes, fmt.Sprintf("^%s\\.%s$", regexp.QuoteMeta(opts.Database), regexp.QuoteMeta(table)))
+	}
+
+	return regexes
+}
+
+// getTable returns the source table schema read by the canal or kept by the replay.
+func (b *Bridge) getTable(db, table string) (*schema.Table, error) {
+	if b.replay {
+		return b.replaySchema.GetTable(db, table)
+	}
+
+	return b.currentCanal().GetTable(db, table)
+}
+
+// currentCanal returns the canal which may be replaced by the reload.
+func (b *Bridge) currentCanal() *canal.Canal {
+	b.canalMu.RLock()
+	defer b.canalMu.RUnlock()
+
+	return b.canal
+}
+
+// stopForRestart is called by the binlog reader at the transaction boundary.
+// It returns true if the canal must be stopped to be recreated from the position.
+func (b *Bridge) stopForRestart(pos position) bool {
+	if !b.restart.CAS(true, false) {
+		return false
+	}
+
+	b.restartMu.Lock()
+	b.restartPos = pos
+	b.restartMu.Unlock()
+
+	return true
+}
+
+// runCanal reads the binlog from the saved position until the canal stops.
+// The canal is recreated if the reload has changed the replicated tables.
+func (b *Bridge) runCanal() error {
+	pos := b.stateSaver.position()
+	for {
+		var err error
+		switch p := pos.(type) {
+		case *gtidSet:
+			err = b.canal.StartFromGTID(p.pos)
+		case *binlogPos:
+			err = b.canal.RunFrom(p.pos)
+		default:
+			err = errors.New("unsupported master position: expected GTID set or binlog file position")
+		}
+		if b.ctx.Err() != nil {
+			// The canal closed by Close may fail to start.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		b.restartMu.Lock()
+		pos, b.restartPos = b.restartPos, nil
+		b.restartMu.Unlock()
+
+		if pos == nil || b.ctx.Err() != nil {
+			return nil
+		}
+
+		if err = b.recreateCanal(); err != nil {
+			return err
+		}
+	}
+}
+
+// recreateCanal replaces the stopped canal with the one reading the tables of the current rules.
+func (b *Bridge) recreateCanal() error {
+	b.reloadMu.Lock()
+	cfg := b.cfg
+	b.reloadMu.Unlock()
+
+	cn, err := b.newCanal(cfg)
+	if err != nil {
+		return err
+	}
+
+	b.canalMu.Lock()
+	old := b.canal
 	b.canal = cn
+	closed := b.ctx.Err() != nil
+	b.canalMu.Unlock()
+
+	// The canal calls the event handler on close, so it is closed without the lock.
+	old.Close()
+	if closed {
+		cn.Close()
+	}
+
+	b.logger.Info().Strs("tables", ruleTables(cfg)).Msg("binlog reader restarted with the new tables")
 
 	return nil
 }
 
+func ruleTables(cfg *config.Config) []string {
+	tables := make([]string, 0, len(cfg.Replication.Rules))
+	for _, rule := range cfg.Replication.Rules {
+		tables = append(tables, rule.Source.Table)
+	}
+
+	return tables
+}
+
 func (b *Bridge) syncRulesAndCanalDump() {
+	// The replay does not dump the tables.
+	if b.replay {
+		return
+	}
+
 	var db string
 	dbs := map[string]struct{}{}
 	tables := make([]string, 0, len(b.rules))
@@ -237,11 +513,27 @@ func (b *Bridge) syncRulesAndCanalDump() {
 }
 
 func (b *Bridge) newUpstream(cfg *config.Config) error {
+	u, err := newUpstreamClient(cfg)
+	if err != nil {
+		return err
+	}
+	b.upstream = u
+
+	return nil
+}
+
+func newUpstreamClient(cfg *config.Config) (*client.SQLClient, error) {
 	opts := &cfg.Replication.UpstreamOpts
-	u, err := client.New(&client.Config{
+
+	tlsCfg, err := opts.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New(&client.Config{
 		Addr:           opts.Addr,
 		User:           opts.User,
-		Password:       opts.Password,
+		Password:       string(opts.Password),
 		Database:       opts.Database,
 		Charset:        opts.Charset,
 		MaxRetries:     opts.MaxRetries,
@@ -249,13 +541,8 @@ func (b *Bridge) newUpstream(cfg *config.Config) error {
 		MaxIdleConns:   opts.MaxIdleConns,
 		ConnectTimeout: opts.ConnectTimeout,
 		WriteTimeout:   opts.WriteTimeout,
+		TLS:            tlsCfg,
 	})
-	if err != nil {
-		return err
-	}
-	b.upstream = u
-
-	return nil
 }
 
 // Run syncs the data from MySQL and inserts to another MySQL
@@ -263,22 +550,88 @@ func (b *Bridge) newUpstream(cfg *config.Config) error {
 func (b *Bridge) Run() error {
 	defer b.setRunning(false)
 
+	if b.elector != nil {
+		if !b.elector.acquire(b.ctx) {
+			return nil
+		}
+
+		// The former leader has advanced the position since the start.
+		if _, err := b.stateSaver.load(); err != nil {
+			return err
+		}
+	}
+
+	// The replay starts from the position itself, the source may not have the binlog of it.
+	replay := b.replay
+	if !replay {
+		if err := b.applyStart(); err != nil {
+			return err
+		}
+	}
+
+	if b.stop.reached(b.stateSaver.position()) {
+		b.logger.Info().Msg("saved position is at or after the stop position, nothing to replicate")
+		b.stop.complete()
+
+		return nil
+	}
+
 	go b.runBackgroundJobs()
 
-	errCh := make(chan error, 2)
+	if b.heartbeat.enabled() {
+		go b.runHeartbeat()
+	}
+
+	if b.throttler.adaptive() {
+		go b.throttler.run()
+	}
+
+	errCh := make(chan error, 4)
 
 	var wg sync.WaitGroup
-	wg.Add(1)
+	if b.elector != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := b.elector.watch(b.ctx)
+			if err != nil {
+				errCh <- err
+
+				// Stop applying the events at once, the standby may be taking over.
+				if errClose := b.Close(); errClose != nil {
+					b.logger.Err(errClose).Msg("got error on closing replicator")
+				}
+			}
+		}()
+	}
+
+	wg.Add(2)
 	go func() {
 		defer wg.Done()
 
-		b.setDumping(true)
+		err := b.queue.run(b.ctx)
+		if err != nil {
+			errCh <- fmt.Errorf("spill loop error: %w", err)
+			b.cancel()
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
 
 		var err error
-		if b.dumpLoadInFileEnabled {
-			err = b.dumpLoopUsingInFile()
+		if replay {
+			// The tables are not dumped, the binlog files have all the rows.
+			close(b.dumpDoneCh)
 		} else {
-			err = b.dumpLoopOneByOne()
+			b.setDumping(true)
+
+			if b.dumpLoadInFileEnabled {
+				err = b.dumpLoopUsingInFile()
+			} else {
+				err = b.dumpLoopOneByOne()
+			}
 		}
 
 		if err != nil {
@@ -299,20 +652,24 @@ func (b *Bridge) Run() error {
 	}()
 
 	var err error
-	pos := b.stateSaver.position()
-	switch p := pos.(type) {
-	case *gtidSet:
-		err = b.canal.StartFromGTID(p.pos)
-	case *binlogPos:
-		err = b.canal.RunFrom(p.pos)
-	default:
-		err = errors.New("unsupported master position: expected GTID set or binlog file position")
+	if replay {
+		err = b.runReplay()
+	} else {
+		err = b.runCanal()
 	}
 
 	if err != nil {
 		errCh <- err
 	}
 
+	// The canal stops reading at the stop position, the queued events are applied yet.
+	if err == nil && b.stop.enabled() {
+		select {
+		case <-b.stop.done():
+		case <-b.ctx.Done():
+		}
+	}
+
 	b.cancel()
 	wg.Wait()
 	close(errCh)
@@ -333,19 +690,28 @@ func (b *Bridge) dumpLoopUsingInFile() error {
 
 	read := func(buf *batch, max int) error {
 		for txn := 0; txn < max; txn++ {
-			if len(b.syncCh) == 0 {
+			if b.queue.len() == 0 {
 				break
 			}
 
-			got := <-b.syncCh
+			got := <-b.queue.out()
+			b.queue.release(got)
 			switch v := got.(type) {
 			case *savePos:
-				err := b.stateSaver.save(v.pos, v.force)
+				err := b.savePosition(v)
 				if err != nil {
 					return err
 				}
 			case batch:
 				*buf = append(*buf, v...)
+			case *heartbeatEvent:
+				// The rows are loaded to the upstream later, so the heartbeat is not applied yet.
+			case *rowsEvent:
+				v.dequeued()
+				if v.err != nil {
+					return v.err
+				}
+				*buf = append(*buf, v.queries...)
 			}
 		}
 
@@ -367,9 +733,9 @@ func (b *Bridge) dumpLoopUsingInFile() error {
 			}
 
 			b.syncedAt.Store(time.Now().Unix())
-		case <-b.canal.WaitDumpDone():
-			store := make(batch, 0, len(b.syncCh))
-			err := read(&store, len(b.syncCh))
+		case <-b.currentCanal().WaitDumpDone():
+			store := make(batch, 0, b.queue.len())
+			err := read(&store, b.queue.len())
 			if err != nil {
 				return err
 			}
@@ -399,15 +765,22 @@ func (b *Bridge) dumpLoopOneByOne() error {
 	process := func(txn interface{}) error {
 		switch v := txn.(type) {
 		case *savePos:
-			err := b.stateSaver.save(v.pos, v.force)
+			err := b.savePosition(v)
 			if err != nil {
 				return err
 			}
 		case batch:
-			err := b.doBatch(v)
+			_, err := b.doBatch(context.Background(), v, eventMeta{})
 			if err != nil {
 				return err
 			}
+		case *rowsEvent:
+			err := b.applyEvent(v)
+			if err != nil {
+				return err
+			}
+		case *heartbeatEvent:
+			b.heartbeat.applied(v)
 		}
 
 		b.syncedAt.Store(time.Now().Unix())
@@ -417,14 +790,16 @@ func (b *Bridge) dumpLoopOneByOne() error {
 
 	for {
 		select {
-		case txn := <-b.syncCh:
+		case txn := <-b.queue.out():
+			b.queue.release(txn)
 			err := process(txn)
 			if err != nil {
 				return err
 			}
-		case <-b.canal.WaitDumpDone():
-			for len(b.syncCh) > 0 {
-				txn := <-b.syncCh
+		case <-b.currentCanal().WaitDumpDone():
+			for b.queue.len() > 0 {
+				txn := <-b.queue.out()
+				b.queue.release(txn)
 				err := process(txn)
 				if err != nil {
 					return err
@@ -439,68 +814,174 @@ func (b *Bridge) dumpLoopOneByOne() error {
 }
 
 func (b *Bridge) syncLoop() error {
+	// failed is the event to apply again after resume.
+	var failed interface{}
 	for {
-		select {
-		case got := <-b.syncCh:
-			switch v := got.(type) {
-			case *savePos:
-				err := b.stateSaver.save(v.pos, v.force)
-				if err != nil {
-					return err
-				}
-			case batch:
-				err := b.doBatch(v)
-				if err != nil {
-					return err
-				}
-			}
-			b.syncedAt.Store(time.Now().Unix())
-		case <-b.ctx.Done():
+		if b.stop.completed() {
 			return nil
 		}
+
+		if resumeCh := b.pauser.wait(); resumeCh != nil {
+			select {
+			case <-resumeCh:
+			case <-b.ctx.Done():
+				return nil
+			}
+		}
+
+		got := failed
+		if got == nil {
+			select {
+			case got = <-b.queue.out():
+				b.queue.release(got)
+			case <-b.ctx.Done():
+				return nil
+			}
+		}
+		failed = nil
+
+		// The event is applied after the resume, if the replication is paused while it is delayed.
+		paused := func() bool {
+			v, _ := b.Paused()
+
+			return v
+		}
+		if !b.delayer.wait(b.ctx, eventTime(got), paused) {
+			if b.ctx.Err() != nil {
+				return nil
+			}
+			failed = got
+
+			continue
+		}
+
+		var err error
+		switch v := got.(type) {
+		case *savePos:
+			err = b.savePosition(v)
+		case batch:
+			var n int
+			n, err = b.doBatch(context.Background(), v, eventMeta{})
+			// The retry continues with the first query not applied.
+			got = v[n:]
+		case *rowsEvent:
+			err = b.applyEvent(v)
+		case *heartbeatEvent:
+			b.heartbeat.applied(v)
+		}
+
+		if err != nil {
+			// The throttled writes are interrupted on close.
+			if b.ctx.Err() != nil {
+				return nil
+			}
+
+			if !b.pauseOnError {
+				return err
+			}
+
+			b.logger.Err(err).Msg("replication paused on error, skip the failed event or resume to retry")
+			failed = got
+			b.pauser.pause(err)
+
+			continue
+		}
+
+		b.syncedAt.Store(time.Now().Unix())
 	}
 }
 
-func (b *Bridge) doBatch(queries batch) error {
-	for _, query := range queries {
-		q, args, err := query.SQL()
-		if err != nil {
-			b.logger.Err(err).
-				Str("query", fmt.Sprintf("%+v", query)).
-				Msg("could not convert to SQL statement")
+// doBatch applies the queries one by one and returns the number of the applied ones.
+func (b *Bridge) doBatch(ctx context.Context, queries batch, meta eventMeta) (int, error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveBatchDuration(meta.table, time.Since(start))
+	}()
+
+	for i, query := range queries {
+		if b.dryRun != nil {
+			q, args, err := query.SQL()
+			if err != nil {
+				b.logger.Err(err).
+					Str("query", fmt.Sprintf("%+v", query)).
+					Msg("could not convert to SQL statement")
+			}
+			if err == nil {
+				err = b.dryRun.write(q, args)
+			}
+			if err != nil {
+				return i, err
+			}
+
+			continue
 		}
 
-		_, err = b.upstream.Exec(context.Background(), q, args...)
+		err := b.throttler.wait(1, 1)
 		if err != nil {
-			b.logger.Err(err).
-				Str("query", q).
-				Str("args", fmt.Sprintf("%+v", args)).
-				Msg("could not exec SQL query")
+			return i, err
+		}
 
-			return err
+		err = b.sink.write(ctx, query, meta)
+		if err != nil {
+			return i, err
 		}
 	}
 
-	return nil
+	return len(queries), nil
 }
 
 func (b *Bridge) Close() error {
 	var err error
 	b.closeOnce.Do(func() {
-		b.canal.Close()
+		// The lock keeps the canal from being recreated while closing.
+		b.canalMu.Lock()
+		if b.canal != nil {
+			b.canal.Close()
+		}
 		b.cancel()
+		b.canalMu.Unlock()
 
+		// The state saver syncs the file sink before saving the position, so the sink is closed after it.
 		err = multierr.Combine(
 			b.stateSaver.close(),
-			b.upstream.Close(),
+			b.sink.close(),
+			b.queue.close(),
+			b.throttler.close(),
 		)
+
+		if b.upstream != nil {
+			err = multierr.Append(err, b.upstream.Close())
+		}
+
+		if b.dryRun != nil {
+			err = multierr.Append(err, b.dryRun.close())
+		}
+
+		// The lock fences the position saved above, so it is released last.
+		if b.elector != nil {
+			err = multierr.Append(err, b.elector.close())
+		}
 	})
 
 	return err
 }
 
+// Role returns the HA role of the replicator or an empty string if the HA is disabled.
+func (b *Bridge) Role() string {
+	if b.elector == nil {
+		return ""
+	}
+
+	return b.elector.role()
+}
+
 func (b *Bridge) Delay() uint32 {
-	return b.canal.GetDelay()
+	// The replay reads the past events, so the delay is not meaningful.
+	if b.replay {
+		return 0
+	}
+
+	return b.currentCanal().GetDelay()
 }
 
 func (b *Bridge) setRunning(v bool) {
@@ -540,6 +1021,22 @@ func (b *Bridge) runBackgroundJobs() {
 		}
 	}()
 
+	go func() {
+		for range time.Tick(1 * time.Second) {
+			st := b.queue.status()
+			metrics.SetQueueLength(st.Length)
+			metrics.SetBufferStatus(st.Rows, st.Bytes, st.Spilled, st.SpilledBytes)
+		}
+	}()
+
+	if b.heartbeat.enabled() {
+		go func() {
+			for range time.Tick(1 * time.Second) {
+				metrics.SetHeartbeatLag(b.heartbeat.lag(time.Now()))
+			}
+		}()
+	}
+
 	go func() {
 		for range time.Tick(1 * time.Second) {
 			syncedAt := b.syncedAt.Load()
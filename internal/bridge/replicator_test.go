@@ -4,15 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/rs/zerolog"
+	"github.com/siddontang/go-mysql/mysql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -70,7 +74,7 @@ func TestReplication(t *testing.T) {
 	sClient, err := client.New(&client.Config{
 		Addr:       sOpts.Addr,
 		User:       sOpts.User,
-		Password:   sOpts.Password,
+		Password:   string(sOpts.Password),
 		Database:   sOpts.Database,
 		Charset:    sOpts.Charset,
 		MaxRetries: 2,
@@ -81,7 +85,7 @@ func TestReplication(t *testing.T) {
 	uClient, err := client.New(&client.Config{
 		Addr:           uOpts.Addr,
 		User:           uOpts.User,
-		Password:       uOpts.Password,
+		Password:       string(uOpts.Password),
 		Database:       uOpts.Database,
 		Charset:        uOpts.Charset,
 		MaxRetries:     uOpts.MaxRetries,
@@ -104,7 +108,7 @@ func (s *bridgeSuite) hasSyncedPos() bool {
 	syncedGTID := s.bridge.canal.SyncedGTIDSet()
 	savedPos := s.bridge.stateSaver.position()
 
-	return savedPos.equal(newGTIDSet(syncedGTID))
+	return savedPos.equal(newGTIDSet(s.cfg.Replication.SourceOpts.Flavor, syncedGTID))
 }
 
 func (s *bridgeSuite) hasSyncedData(rows int) bool {
@@ -132,6 +136,9 @@ func (s *bridgeSuite) AfterTest(_, _ string) {
 	_, err = s.upstream.Exec(context.Background(), "TRUNCATE town.clients")
 	assert.NoError(t, err)
 
+	_, err = s.upstream.Exec(context.Background(), "TRUNCATE town.mymy_state")
+	assert.NoError(t, err)
+
 	dataDir := path.Dir(s.cfg.App.DataFile)
 	err = os.RemoveAll(dataDir)
 	assert.NoError(t, err)
@@ -418,6 +425,509 @@ func (s *bridgeSuite) TestRenameColumn() {
 	assert.NoError(t, err)
 }
 
+func (s *bridgeSuite) TestHeartbeat() {
+	t := s.T()
+	dumpPath := s.cfg.Replication.SourceOpts.Dump.ExecPath
+	if !assert.FileExists(t, dumpPath) {
+		t.Skip("test requires mysqldump utility")
+	}
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(s.cfg, factory)
+
+	go func() {
+		err := s.bridge.Run()
+		assert.NoError(t, err)
+	}()
+
+	<-s.bridge.WaitDumpDone()
+	require.Eventually(t, s.bridge.Running, 1*time.Second, 5*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		lag, ok := s.bridge.HeartbeatLag()
+
+		return ok && lag > 0 && lag < 500*time.Millisecond
+	}, 2*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, s.bridge.Pause())
+	time.Sleep(time.Second)
+
+	lag, ok := s.bridge.HeartbeatLag()
+	assert.True(t, ok)
+	assert.Greater(t, int64(lag), int64(time.Second))
+
+	require.NoError(t, s.bridge.Resume())
+	require.Eventually(t, func() bool {
+		lag, _ = s.bridge.HeartbeatLag()
+
+		return lag < 500*time.Millisecond
+	}, 2*time.Second, 50*time.Millisecond)
+}
+
+func (s *bridgeSuite) TestHA() {
+	t := s.T()
+	dumpPath := s.cfg.Replication.SourceOpts.Dump.ExecPath
+	if !assert.FileExists(t, dumpPath) {
+		t.Skip("test requires mysqldump utility")
+	}
+
+	cfg := *s.cfg
+	cfg.App.State.Table = "mymy_state"
+	cfg.App.HA.Enabled = true
+	cfg.App.HA.CheckInterval = 100 * time.Millisecond
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(&cfg, factory)
+
+	standby, err := New(&cfg, factory, s.logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, standby.Close())
+	}()
+
+	go func() {
+		errRun := s.bridge.Run()
+		assert.NoError(t, errRun)
+	}()
+
+	<-s.bridge.WaitDumpDone()
+	require.Eventually(t, s.bridge.Running, 1*time.Second, 5*time.Millisecond)
+	assert.Equal(t, RoleLeader, s.bridge.Role())
+
+	go func() {
+		errRun := standby.Run()
+		assert.NoError(t, errRun)
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, RoleStandby, standby.Role())
+	assert.False(t, standby.Running())
+
+	for i := 0; i < 10; i++ {
+		_, err = s.source.Exec(context.Background(), "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", i+1, "bob", "12345", "Bob", "bob@email.com")
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool {
+		return s.hasSyncedData(10)
+	}, 1*time.Second, 50*time.Millisecond)
+
+	// The leader saves the position and releases the lock on close.
+	require.NoError(t, s.bridge.Close())
+	leaderPos := s.bridge.stateSaver.position()
+
+	<-standby.WaitDumpDone()
+	require.Eventually(t, standby.Running, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, RoleLeader, standby.Role())
+	assert.True(t, leaderPos.equal(standby.stateSaver.position()))
+
+	_, err = s.source.Exec(context.Background(), "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", 11, "bob", "12345", "Bob", "bob@email.com")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return s.hasSyncedData(11)
+	}, 1*time.Second, 50*time.Millisecond)
+}
+
+func (s *bridgeSuite) TestDryRun() {
+	t := s.T()
+	dumpPath := s.cfg.Replication.SourceOpts.Dump.ExecPath
+	if !assert.FileExists(t, dumpPath) {
+		t.Skip("test requires mysqldump utility")
+	}
+
+	dir, err := ioutil.TempDir("", "mymy-dry-run")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	statements := filepath.Join(dir, "statements.sql")
+	cfg := *s.cfg
+	cfg.Replication.DryRun = config.DryRunConfig{Enabled: true, File: statements}
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(&cfg, factory)
+
+	go func() {
+		errRun := s.bridge.Run()
+		assert.NoError(t, errRun)
+	}()
+
+	<-s.bridge.WaitDumpDone()
+	require.Eventually(t, s.bridge.Running, 1*time.Second, 5*time.Millisecond)
+
+	_, err = s.source.Exec(context.Background(), "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", 1, "bob", "12345", "Bob", "bob@email.com")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		data, errRead := ioutil.ReadFile(statements)
+
+		return errRead == nil && strings.Contains(string(data), "INSERT INTO clients")
+	}, 1*time.Second, 50*time.Millisecond)
+	assert.True(t, s.hasSyncedData(0))
+
+	require.NoError(t, s.bridge.Close())
+	assert.NoFileExists(t, s.cfg.App.DataFile)
+}
+
+func (s *bridgeSuite) TestPositionManager() {
+	t := s.T()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := s.source.Exec(ctx, "INSERT INTO city.users (username, password, name, email) VALUES (?, ?, ?, ?)", "bob", "12345", "Bob", "bob@email.com")
+		require.NoError(t, err)
+	}
+
+	m, err := NewPositionManager(s.cfg)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, m.Close())
+	}()
+
+	info, err := m.Show(ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Empty)
+
+	// Without the ha lock the stopped replicator is not detected.
+	_, err = m.Set(ctx, PositionTarget{Current: true})
+	assert.ErrorIs(t, err, ErrStopNotChecked)
+	m.Force = true
+
+	current, err := m.Set(ctx, PositionTarget{Current: true})
+	require.NoError(t, err)
+	assert.NotEmpty(t, current.GTID)
+	assert.NotEmpty(t, current.File)
+
+	info, err = m.Show(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, current, info)
+
+	// The binlog position is converted to the same GTID set.
+	info, err = m.Set(ctx, PositionTarget{File: current.File, Pos: current.Pos})
+	require.NoError(t, err)
+	assert.Equal(t, current.GTID, info.GTID)
+
+	_, err = m.Set(ctx, PositionTarget{File: "mysql-bin.999999", Pos: 4})
+	assert.Error(t, err)
+
+	require.NoError(t, m.Reset(ctx))
+	info, err = m.Show(ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Empty)
+}
+
+func (s *bridgeSuite) TestStartFromGTID() {
+	t := s.T()
+	ctx := context.Background()
+
+	insert := func(from, to int) {
+		for i := from; i <= to; i++ {
+			_, err := s.source.Exec(ctx, "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", i, "bob", "12345", "Bob", "bob@email.com")
+			require.NoError(t, err)
+		}
+	}
+
+	// The upstream is restored from the backup with the first rows.
+	insert(1, 3)
+
+	var gtid string
+	err := s.source.QueryRow(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtid)
+	require.NoError(t, err)
+
+	insert(4, 5)
+
+	cfg := *s.cfg
+	cfg.Replication.Start = config.PositionConfig{GTID: strings.ReplaceAll(gtid, "\n", "")}
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(&cfg, factory)
+
+	go func() {
+		errRun := s.bridge.Run()
+		assert.NoError(t, errRun)
+	}()
+
+	<-s.bridge.WaitDumpDone()
+
+	require.Eventually(t, func() bool {
+		return s.hasSyncedData(2)
+	}, 1*time.Second, 50*time.Millisecond)
+}
+
+func (s *bridgeSuite) TestStartFromTime() {
+	t := s.T()
+	ctx := context.Background()
+
+	insert := func(from, to int) {
+		for i := from; i <= to; i++ {
+			_, err := s.source.Exec(ctx, "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", i, "bob", "12345", "Bob", "bob@email.com")
+			require.NoError(t, err)
+		}
+	}
+
+	// The binlog timestamps have the seconds precision.
+	insert(1, 3)
+	time.Sleep(1100 * time.Millisecond)
+	at := time.Now()
+	time.Sleep(1100 * time.Millisecond)
+	insert(4, 5)
+
+	cfg := *s.cfg
+	cfg.Replication.Start = config.PositionConfig{Time: at.Format(time.RFC3339)}
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(&cfg, factory)
+
+	go func() {
+		errRun := s.bridge.Run()
+		assert.NoError(t, errRun)
+	}()
+
+	<-s.bridge.WaitDumpDone()
+
+	require.Eventually(t, func() bool {
+		return s.hasSyncedData(2)
+	}, 1*time.Second, 50*time.Millisecond)
+}
+
+func (s *bridgeSuite) TestStopAtGTID() {
+	t := s.T()
+	ctx := context.Background()
+
+	insert := func(from, to int) {
+		for i := from; i <= to; i++ {
+			_, err := s.source.Exec(ctx, "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", i, "bob", "12345", "Bob", "bob@email.com")
+			require.NoError(t, err)
+		}
+	}
+
+	status, err := queryRows(ctx, s.source, "SHOW MASTER STATUS")
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	startPos, err := strconv.ParseUint(status[0]["Position"], 10, 32)
+	require.NoError(t, err)
+
+	insert(1, 3)
+
+	var gtid string
+	err = s.source.QueryRow(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&gtid)
+	require.NoError(t, err)
+	gtid = strings.ReplaceAll(gtid, "\n", "")
+
+	insert(4, 5)
+
+	cfg := *s.cfg
+	cfg.Replication.Start = config.PositionConfig{File: status[0]["File"], Pos: uint32(startPos)}
+	cfg.Replication.Stop = config.PositionConfig{GTID: gtid}
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(&cfg, factory)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.bridge.Run()
+	}()
+
+	select {
+	case err = <-errCh:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("replication is not completed at the stop position")
+	}
+
+	assert.True(t, s.bridge.Completed())
+	assert.Equal(t, PhaseCompleted, s.bridge.Status().Phase)
+	assert.True(t, s.hasSyncedData(3))
+
+	want, err := mysql.ParseGTIDSet(cfg.Replication.SourceOpts.Flavor, gtid)
+	require.NoError(t, err)
+	saved, ok := s.bridge.stateSaver.position().(*gtidSet)
+	require.True(t, ok)
+	assert.True(t, want.Equal(saved.pos))
+}
+
+func (s *bridgeSuite) TestResnapshot() {
+	t := s.T()
+	dumpPath := s.cfg.Replication.SourceOpts.Dump.ExecPath
+	if !assert.FileExists(t, dumpPath) {
+		t.Skip("test requires mysqldump utility")
+	}
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+	s.init(s.cfg, factory)
+
+	rows := 120
+	for i := 1; i <= rows; i++ {
+		_, err := s.source.Exec(context.Background(), "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", i, "bob", "12345", "Bob", "bob@email.com")
+		require.NoError(t, err)
+	}
+
+	go func() {
+		err := s.bridge.Run()
+		assert.NoError(t, err)
+	}()
+
+	<-s.bridge.WaitDumpDone()
+
+	require.Eventually(t, func() bool {
+		return s.hasSyncedData(rows)
+	}, 1*time.Second, 50*time.Millisecond)
+	require.Eventually(t, s.bridge.Running, 100*time.Millisecond, 5*time.Millisecond)
+
+	// Imitate the upstream drift.
+	_, err := s.upstream.Exec(context.Background(), "DELETE FROM town.clients WHERE id > ?", 100)
+	require.NoError(t, err)
+	_, err = s.upstream.Exec(context.Background(), "UPDATE town.clients SET name = ? WHERE id = ?", "Eve", 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrRuleNotExist, s.bridge.Resnapshot("orders"))
+
+	err = s.bridge.Resnapshot("users")
+	require.NoError(t, err)
+
+	// Concurrent changes during the snapshot.
+	_, err = s.source.Exec(context.Background(), "UPDATE city.users SET name = ? WHERE id = ?", "Alice", 110)
+	require.NoError(t, err)
+	_, err = s.source.Exec(context.Background(), "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", rows+1, "bob", "12345", "Bob", "bob@email.com")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		list := s.bridge.Snapshots()
+
+		return len(list) == 1 && list[0].State == SnapshotDone
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		return s.hasSyncedData(rows + 1)
+	}, 1*time.Second, 50*time.Millisecond)
+
+	var name string
+	err = s.upstream.QueryRow(context.Background(), "SELECT name FROM town.clients WHERE id=?", 1).Scan(&name)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", name)
+
+	err = s.upstream.QueryRow(context.Background(), "SELECT name FROM town.clients WHERE id=?", 110).Scan(&name)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", name)
+
+	err = s.bridge.Close()
+	assert.NoError(t, err)
+}
+
+func (s *bridgeSuite) TestVerify() {
+	t := s.T()
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+
+	for i := 1; i <= 10; i++ {
+		_, err := s.source.Exec(context.Background(), "INSERT INTO city.users (id, username, password, name, email) VALUES (?, ?, ?, ?, ?)", i, "bob", "12345", "Bob", "bob@email.com")
+		require.NoError(t, err)
+	}
+
+	// The upstream misses two rows, has one different and one extra row.
+	for i := 1; i <= 8; i++ {
+		_, err := s.upstream.Exec(context.Background(), "INSERT INTO town.clients (id, name, email) VALUES (?, ?, ?)", i, "Bob", "bob@email.com")
+		require.NoError(t, err)
+	}
+	_, err := s.upstream.Exec(context.Background(), "UPDATE town.clients SET name = ? WHERE id = ?", "Eve", 3)
+	require.NoError(t, err)
+	_, err = s.upstream.Exec(context.Background(), "INSERT INTO town.clients (id, name, email) VALUES (?, ?, ?)", 20, "Eve", "eve@email.com")
+	require.NoError(t, err)
+
+	cfg := *s.cfg
+	cfg.Replication.SourceOpts.Snapshot.ChunkSize = 4
+
+	v, err := NewVerifier(&cfg, factory, s.logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, v.Close())
+	}()
+
+	_, err = v.Verify(context.Background(), "orders", VerifyOptions{})
+	assert.Equal(t, ErrRuleNotExist, err)
+
+	report, err := v.Verify(context.Background(), "users", VerifyOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, report.SourceRows)
+	assert.EqualValues(t, 10, report.Checked)
+	assert.EqualValues(t, 2, report.Missing)
+	assert.EqualValues(t, 1, report.Differing)
+	assert.EqualValues(t, 1, report.Extra)
+	assert.Empty(t, report.Unchecked)
+	assert.False(t, report.Consistent())
+
+	report, err = v.Verify(context.Background(), "users", VerifyOptions{Repair: true})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, report.Repaired)
+	assert.True(t, report.Consistent())
+
+	report, err = v.Verify(context.Background(), "users", VerifyOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Diffs)
+	assert.True(t, report.Consistent())
+	assert.True(t, s.hasSyncedData(10))
+}
+
+func (s *bridgeSuite) TestVerify_EmptySource() {
+	t := s.T()
+
+	factory := &baseFactory{
+		table: "clients",
+		skip:  []string{"username", "password"},
+	}
+
+	for i := 1; i <= 3; i++ {
+		_, err := s.upstream.Exec(context.Background(), "INSERT INTO town.clients (id, name, email) VALUES (?, ?, ?)", i, "Bob", "bob@email.com")
+		require.NoError(t, err)
+	}
+
+	v, err := NewVerifier(s.cfg, factory, s.logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, v.Close())
+	}()
+
+	report, err := v.Verify(context.Background(), "users", VerifyOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, report.Extra)
+
+	opts := VerifyOptions{UpstreamTables: []string{"clients"}}
+	report, err = v.Verify(context.Background(), "users", opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, report.Extra)
+
+	opts.Repair = true
+	report, err = v.Verify(context.Background(), "users", opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, report.Repaired)
+	assert.True(t, report.Consistent())
+	assert.True(t, s.hasSyncedData(0))
+}
+
 type mockFactory struct {
 	handler mymy.EventHandler
 }
@@ -0,0 +1,78 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+// sink writes the queries made by the rule handlers.
+type sink interface {
+	// write applies the query of the event.
+	write(ctx context.Context, query *mymy.Query, meta eventMeta) error
+	close() error
+}
+
+// eventMeta describes the event the queries are made from.
+type eventMeta struct {
+	// table is a rule key of the source table, empty for the snapshot chunks.
+	table string
+	// gtid is the GTID of the event transaction, empty in the binlog position mode.
+	gtid string
+	// at is the time the event has been written to the binlog, zero for the dumped rows.
+	at time.Time
+}
+
+func newSink(cfg *config.Config, upstream *client.SQLClient, logger zerolog.Logger) (sink, error) {
+	switch sinkCfg := cfg.Replication.Sink; sinkCfg.Type {
+	case config.SinkUpstream:
+		return &upstreamSink{
+			client: upstream,
+			logger: logger,
+		}, nil
+	case config.SinkFile:
+		return newFileSink(sinkCfg.File)
+	default:
+		return nil, fmt.Errorf("unsupported sink type: %s", sinkCfg.Type)
+	}
+}
+
+// upstreamSink executes the queries on the upstream database.
+type upstreamSink struct {
+	client *client.SQLClient
+	logger zerolog.Logger
+}
+
+func (s *upstreamSink) write(ctx context.Context, query *mymy.Query, _ eventMeta) error {
+	q, args, err := query.SQL()
+	if err != nil {
+		s.logger.Err(err).
+			Str("query", fmt.Sprintf("%+v", query)).
+			Msg("could not convert to SQL statement")
+
+		return err
+	}
+
+	_, err = s.client.Exec(ctx, q, args...)
+	if err != nil {
+		s.logger.Err(err).
+			Str("query", q).
+			Str("args", fmt.Sprintf("%+v", args)).
+			Msg("could not exec SQL query")
+
+		return err
+	}
+
+	return nil
+}
+
+// close does not close the client, it is shared with the state saver and the dump loader.
+func (s *upstreamSink) close() error {
+	return nil
+}
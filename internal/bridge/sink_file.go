@@ -0,0 +1,312 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/util"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+	"github.com/city-mobil/go-mymy/pkg/mymy/rpc"
+)
+
+// fileSinkLayout is the time layout of the file names, so the files are sorted in the write order.
+const fileSinkLayout = "20060102T150405.000000000"
+
+// FileSinkStatus is a state of the file sink.
+type FileSinkStatus struct {
+	// File is the path of the file being written, empty until the first change.
+	File string `json:"file,omitempty"`
+	// Bytes is the size of the file being written.
+	Bytes int64 `json:"bytes"`
+	// SyncedAt is the time the changes have been synced to the disk last time.
+	SyncedAt *time.Time `json:"synced_at,omitempty"`
+}
+
+// changeRecord is a line of the file sink: the query with the event it is made from.
+type changeRecord struct {
+	rpc.Query
+	// Source is a rule key of the source table, empty for the snapshot chunks.
+	Source string `json:"source,omitempty"`
+	// GTID is the GTID of the source transaction, empty in the binlog position mode.
+	GTID string `json:"gtid,omitempty"`
+	// EventTime is the time the event has been written to the binlog, nil for the dumped rows.
+	EventTime *time.Time `json:"event_time,omitempty"`
+}
+
+// fileSink writes the queries as JSON Lines to the files in the directory.
+// The next file is started once the file reaches the size or the age.
+type fileSink struct {
+	mu       *sync.Mutex
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	file     *os.File
+	w        *bufio.Writer
+	size     int64
+	openedAt time.Time
+	syncedAt time.Time
+	// dirty is true if the written changes are not synced to the disk yet.
+	dirty bool
+}
+
+func newFileSink(cfg config.FileSinkConfig) (*fileSink, error) {
+	dir := util.AbsPath(cfg.Dir)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("could not create sink dir: %w", err)
+	}
+
+	return &fileSink{
+		mu:       &sync.Mutex{},
+		dir:      dir,
+		maxBytes: cfg.MaxBytes,
+		maxAge:   cfg.MaxAge,
+	}, nil
+}
+
+func (s *fileSink) write(_ context.Context, query *mymy.Query, meta eventMeta) error {
+	queries, err := rpc.EncodeQueries([]*mymy.Query{query})
+	if err != nil {
+		return err
+	}
+
+	rec := &changeRecord{
+		Query:  queries[0],
+		Source: meta.table,
+		GTID:   meta.gtid,
+	}
+	if !meta.at.IsZero() {
+		at := meta.at
+		rec.EventTime = &at
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil && s.full() {
+		if err = s.closeFile(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		if err = s.openFile(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.w.Write(line)
+	s.size += int64(n)
+	s.dirty = true
+
+	return err
+}
+
+// full returns true if the next file must be started.
+func (s *fileSink) full() bool {
+	return (s.maxBytes > 0 && s.size >= s.maxBytes) ||
+		(s.maxAge > 0 && time.Since(s.openedAt) >= s.maxAge)
+}
+
+func (s *fileSink) openFile() error {
+	now := time.Now()
+	name := filepath.Join(s.dir, "changes-"+now.UTC().Format(fileSinkLayout)+".jsonl")
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("could not create sink file: %w", err)
+	}
+
+	// The new file must survive the crash once the changes of it are synced.
+	if err = syncDir(s.dir); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	s.file = f
+	s.w = bufio.NewWriter(f)
+	s.size = 0
+	s.openedAt = now
+
+	return nil
+}
+
+// closeFile syncs and closes the file, the next change starts a new one.
+func (s *fileSink) closeFile() error {
+	if err := s.syncFile(); err != nil {
+		return err
+	}
+
+	err := s.file.Close()
+	s.file, s.w = nil, nil
+
+	return err
+}
+
+// sync flushes the written changes to the disk. The position is saved after it,
+// so the saved position is never ahead of the files.
+func (s *fileSink) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.syncFile()
+}
+
+func (s *fileSink) syncFile() error {
+	if !s.dirty {
+		return nil
+	}
+
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	s.syncedAt = time.Now()
+
+	return nil
+}
+
+func (s *fileSink) status() *FileSinkStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := &FileSinkStatus{
+		Bytes: s.size,
+	}
+	if s.file != nil {
+		st.File = s.file.Name()
+	}
+	if !s.syncedAt.IsZero() {
+		syncedAt := s.syncedAt
+		st.SyncedAt = &syncedAt
+	}
+
+	return st
+}
+
+func (s *fileSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	return s.closeFile()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if errClose := d.Close(); err == nil {
+		err = errClose
+	}
+
+	return err
+}
+
+// fileSinkSaver saves the position only after the changes before it are synced to the disk.
+// Between the syncs the position is kept in memory, so the files are synced once per interval.
+type fileSinkSaver struct {
+	saver    stateSaver
+	sink     *fileSink
+	interval time.Duration
+
+	mu *sync.RWMutex
+	// pending is the position not saved yet, nil if the saved position is the last one.
+	pending position
+	savedAt time.Time
+}
+
+func newFileSinkSaver(saver stateSaver, sink *fileSink, interval time.Duration) *fileSinkSaver {
+	return &fileSinkSaver{
+		saver:    saver,
+		sink:     sink,
+		interval: interval,
+		mu:       &sync.RWMutex{},
+	}
+}
+
+func (s *fileSinkSaver) load() (position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = nil
+
+	return s.saver.load()
+}
+
+func (s *fileSinkSaver) save(pos position, force bool) error {
+	if pos == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = pos
+	if !force && time.Since(s.savedAt) < s.interval {
+		return nil
+	}
+
+	if err := s.sink.sync(); err != nil {
+		return fmt.Errorf("could not sync sink file, pos: %s, what: %w", pos, err)
+	}
+	if err := s.saver.save(pos, true); err != nil {
+		return err
+	}
+
+	s.pending = nil
+	s.savedAt = time.Now()
+
+	return nil
+}
+
+func (s *fileSinkSaver) position() position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.pending != nil {
+		return s.pending.clone()
+	}
+
+	return s.saver.position()
+}
+
+func (s *fileSinkSaver) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = nil
+
+	return s.saver.reset()
+}
+
+func (s *fileSinkSaver) close() error {
+	if err := s.save(s.position(), true); err != nil {
+		return err
+	}
+
+	return s.saver.close()
+}
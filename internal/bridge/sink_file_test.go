@@ -0,0 +1,159 @@
+package bridge
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func readSinkFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "changes-*.jsonl"))
+	require.NoError(t, err)
+
+	contents := make([]string, 0, len(files))
+	for _, f := range files {
+		data, errRead := ioutil.ReadFile(f)
+		require.NoError(t, errRead)
+		contents = append(contents, string(data))
+	}
+
+	return contents
+}
+
+func TestFileSink_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-sink")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	s, err := newFileSink(config.FileSinkConfig{Dir: dir})
+	require.NoError(t, err)
+
+	at := time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC)
+	err = s.write(context.Background(), &mymy.Query{
+		Action: mymy.ActionUpdate,
+		Table:  "clients",
+		Values: []mymy.QueryArg{{Field: "name", Value: "Bob"}},
+		Where:  []mymy.QueryArg{{Field: "id", Value: uint64(1)}},
+	}, eventMeta{
+		table: "city.users",
+		gtid:  "de278ad0-2106-11e4-9f8e-6edd0ca20947:10",
+		at:    at,
+	})
+	require.NoError(t, err)
+
+	// The snapshot chunks have no event.
+	err = s.write(context.Background(), &mymy.Query{
+		Action: mymy.ActionUpsert,
+		Table:  "clients",
+		Values: []mymy.QueryArg{{Field: "id", Value: uint64(2)}, {Field: "name", Value: nil}},
+	}, eventMeta{})
+	require.NoError(t, err)
+	require.NoError(t, s.close())
+
+	assert.Equal(t, []string{
+		`{"action":"update","table":"clients",` +
+			`"values":[{"field":"name","value":{"t":"string","v":"Bob"}}],` +
+			`"where":[{"field":"id","value":{"t":"uint64","v":"1"}}],` +
+			`"source":"city.users","gtid":"de278ad0-2106-11e4-9f8e-6edd0ca20947:10","event_time":"2020-01-02T15:04:05Z"}` + "\n" +
+			`{"action":"upsert","table":"clients",` +
+			`"values":[{"field":"id","value":{"t":"uint64","v":"2"}},{"field":"name","value":{"t":"null"}}]}` + "\n",
+	}, readSinkFiles(t, dir))
+}
+
+func TestFileSink_Rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-sink")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	s, err := newFileSink(config.FileSinkConfig{Dir: dir, MaxBytes: 1})
+	require.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		err = s.write(context.Background(), &mymy.Query{
+			Action: mymy.ActionDelete,
+			Table:  "clients",
+			Where:  []mymy.QueryArg{{Field: "id", Value: i}},
+		}, eventMeta{})
+		require.NoError(t, err)
+
+		// The file names have the nanosecond precision.
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, s.close())
+
+	files := readSinkFiles(t, dir)
+	require.Len(t, files, 3)
+	for i, data := range files {
+		assert.Equal(t, 1, strings.Count(data, "\n"))
+		assert.Contains(t, data, `{"t":"int","v":"`+strconv.Itoa(i+1)+`"}`)
+	}
+}
+
+func TestFileSinkSaver_Save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-sink")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	s, err := newFileSink(config.FileSinkConfig{Dir: filepath.Join(dir, "changes")})
+	require.NoError(t, err)
+
+	dataFile := filepath.Join(dir, "master.info")
+	fs, err := newFileSaver(dataFile, false, mysql.MySQLFlavor)
+	require.NoError(t, err)
+
+	saver := newFileSinkSaver(fs, s, time.Hour)
+
+	query := &mymy.Query{
+		Action: mymy.ActionDelete,
+		Table:  "clients",
+		Where:  []mymy.QueryArg{{Field: "id", Value: 1}},
+	}
+	first := newBinlogPos(mysql.Position{Name: "mysql-bin.000001", Pos: 154})
+	second := newBinlogPos(mysql.Position{Name: "mysql-bin.000001", Pos: 300})
+
+	require.NoError(t, s.write(context.Background(), query, eventMeta{}))
+	require.NoError(t, saver.save(first, true))
+
+	// The forced save syncs the written changes before the position.
+	assert.Equal(t, []string{`{"action":"delete","table":"clients","where":[{"field":"id","value":{"t":"int","v":"1"}}]}` + "\n"},
+		readSinkFiles(t, filepath.Join(dir, "changes")))
+	assert.NotNil(t, s.status().SyncedAt)
+
+	// Until the interval passes the position is kept in memory.
+	require.NoError(t, s.write(context.Background(), query, eventMeta{}))
+	require.NoError(t, saver.save(second, false))
+	assert.Equal(t, second, saver.position())
+
+	loaded, err := newFileSaver(dataFile, false, mysql.MySQLFlavor)
+	require.NoError(t, err)
+	pos, err := loaded.load()
+	require.NoError(t, err)
+	assert.Equal(t, first, pos)
+
+	// The close syncs the rest and saves the last position.
+	require.NoError(t, saver.close())
+	require.NoError(t, s.close())
+
+	pos, err = loaded.load()
+	require.NoError(t, err)
+	assert.Equal(t, second, pos)
+	assert.Equal(t, 2, strings.Count(readSinkFiles(t, filepath.Join(dir, "changes"))[0], "\n"))
+}
@@ -0,0 +1,554 @@
+package bridge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/siddontang/go-mysql/canal"
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/schema"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+const (
+	watermarkLow         = "low"
+	watermarkHigh        = "high"
+	watermarkValueColumn = "value"
+	pkKeySeparator       = "\x00"
+)
+
+var (
+	ErrSnapshotDisabled   = errors.New("re-snapshot is disabled: watermark table is not configured")
+	ErrSnapshotInProgress = errors.New("re-snapshot is already in progress")
+	ErrNotRunning         = errors.New("replication is not running")
+
+	errChunkStale = errors.New("table has been changed during the chunk read")
+)
+
+type SnapshotState string
+
+const (
+	SnapshotRunning SnapshotState = "running"
+	SnapshotDone    SnapshotState = "done"
+	SnapshotFailed  SnapshotState = "failed"
+)
+
+// SnapshotStatus describes the progress of the table re-snapshot.
+type SnapshotStatus struct {
+	Table      string        `json:"table"`
+	State      SnapshotState `json:"state"`
+	Rows       uint64        `json:"rows"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// snapshotChunk is a part of the source table read between the low and high watermarks.
+type snapshotChunk struct {
+	id      string
+	ruleKey string
+	rows    [][]interface{}
+	// collecting is true after the low watermark has been received.
+	collecting bool
+	// changed contains primary keys of the rows modified by the binlog events
+	// between the low and high watermarks.
+	changed map[string]struct{}
+	// stale is true if the table schema has been changed during the chunk read.
+	stale   bool
+	emitted int
+	done    chan error
+}
+
+func newSnapshotChunk(ruleKey string) *snapshotChunk {
+	return &snapshotChunk{
+		id:      newWatermarkID(),
+		ruleKey: ruleKey,
+		changed: make(map[string]struct{}),
+		done:    make(chan error, 1),
+	}
+}
+
+// pending returns the chunk rows which have not been changed by the binlog events.
+func (c *snapshotChunk) pending(pks []mymy.Column) [][]interface{} {
+	rows := make([][]interface{}, 0, len(c.rows))
+	for _, row := range c.rows {
+		if _, ok := c.changed[pkKey(pks, row)]; !ok {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// snapshotter re-reads the source tables in primary key chunks and merges
+// the chunks into the binlog stream using the low and high watermarks
+// written to the source database. The rows changed by the binlog events
+// between the watermarks are dropped from the chunk, so the binlog version
+// of a row always wins.
+type snapshotter struct {
+	schema    string
+	watermark string
+	chunkSize int
+
+	mu       *sync.Mutex
+	chunk    *snapshotChunk
+	statuses map[string]*SnapshotStatus
+}
+
+func newSnapshotter(cfg *config.Config) *snapshotter {
+	opts := cfg.Replication.SourceOpts
+
+	// The replay has no source to copy the tables from.
+	watermark := opts.Snapshot.WatermarkTable
+	if cfg.Replication.Replay.Enabled() {
+		watermark = ""
+	}
+
+	return &snapshotter{
+		schema:    opts.Database,
+		watermark: watermark,
+		chunkSize: opts.Snapshot.ChunkSize,
+		mu:        &sync.Mutex{},
+		statuses:  make(map[string]*SnapshotStatus),
+	}
+}
+
+func (s *snapshotter) enabled() bool {
+	return s.watermark != ""
+}
+
+func (s *snapshotter) isWatermark(table *schema.Table) bool {
+	return s.enabled() && table.Schema == s.schema && table.Name == s.watermark
+}
+
+func (s *snapshotter) begin(table string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, status := range s.statuses {
+		if status.State == SnapshotRunning {
+			return ErrSnapshotInProgress
+		}
+	}
+
+	s.statuses[table] = &SnapshotStatus{
+		Table:     table,
+		State:     SnapshotRunning,
+		StartedAt: time.Now(),
+	}
+
+	return nil
+}
+
+func (s *snapshotter) finish(table string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.statuses[table]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	status.FinishedAt = &now
+	if err != nil {
+		status.State = SnapshotFailed
+		status.Error = err.Error()
+	} else {
+		status.State = SnapshotDone
+	}
+}
+
+func (s *snapshotter) addRows(table string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status, ok := s.statuses[table]; ok {
+		status.Rows += uint64(n)
+	}
+}
+
+func (s *snapshotter) list() []SnapshotStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]SnapshotStatus, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		list = append(list, *status)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Table < list[j].Table
+	})
+
+	return list
+}
+
+func (s *snapshotter) setChunk(chunk *snapshotChunk) {
+	s.mu.Lock()
+	s.chunk = chunk
+	s.mu.Unlock()
+}
+
+func (s *snapshotter) setRows(chunk *snapshotChunk, rows [][]interface{}) {
+	s.mu.Lock()
+	chunk.rows = rows
+	s.mu.Unlock()
+}
+
+// observe remembers primary keys of the rows changed inside the current chunk window.
+func (s *snapshotter) observe(ruleKey string, pks []mymy.Column, rows [][]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunk := s.chunk
+	if chunk == nil || !chunk.collecting || chunk.ruleKey != ruleKey {
+		return
+	}
+
+	for _, row := range rows {
+		chunk.changed[pkKey(pks, row)] = struct{}{}
+	}
+}
+
+func (s *snapshotter) markStale(ruleKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.chunk != nil && s.chunk.ruleKey == ruleKey {
+		s.chunk.stale = true
+	}
+}
+
+// onWatermark handles the watermark value received from the binlog
+// and returns the current chunk if it is ready to be emitted.
+func (s *snapshotter) onWatermark(value string) *snapshotChunk {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	kind, id := parts[0], parts[1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunk := s.chunk
+	if chunk == nil || chunk.id != id {
+		// The watermark belongs to another chunk or replicator.
+		return nil
+	}
+
+	switch kind {
+	case watermarkLow:
+		chunk.collecting = true
+	case watermarkHigh:
+		if chunk.collecting {
+			s.chunk = nil
+
+			return chunk
+		}
+	}
+
+	return nil
+}
+
+// Resnapshot starts to copy the rule's source table to the upstream again
+// while the binlog replication keeps running. It returns immediately, use
+// Snapshots to track the progress. The chunk rows are upserted, so the upstream
+// rows deleted from the source are not removed; the verifier repairs them.
+func (b *Bridge) Resnapshot(table string) error {
+	if !b.snapshot.enabled() {
+		return ErrSnapshotDisabled
+	}
+
+	if !b.Running() {
+		return ErrNotRunning
+	}
+
+	key := mymy.RuleKey(b.snapshot.schema, table)
+	if _, ok := b.rule(key); !ok {
+		return ErrRuleNotExist
+	}
+
+	if err := b.snapshot.begin(table); err != nil {
+		return err
+	}
+
+	go b.doSnapshot(table)
+
+	return nil
+}
+
+// doSnapshot copies the table which snapshot has already begun.
+func (b *Bridge) doSnapshot(table string) {
+	b.logger.Info().Str("table", table).Msg("re-snapshot started")
+
+	err := b.runSnapshot(table)
+	b.snapshot.finish(table, err)
+	if err != nil {
+		b.logger.Err(err).Str("table", table).Msg("re-snapshot failed")
+	} else {
+		b.logger.Info().Str("table", table).Msg("re-snapshot finished")
+	}
+}
+
+// Snapshots returns the statuses of the started re-snapshots.
+func (b *Bridge) Snapshots() []SnapshotStatus {
+	return b.snapshot.list()
+}
+
+func (b *Bridge) runSnapshot(table string) error {
+	key := mymy.RuleKey(b.snapshot.schema, table)
+
+	var after []interface{}
+	for {
+		rule, ok := b.rule(key)
+		if !ok {
+			return ErrRuleNotExist
+		}
+
+		chunk, err := b.copyChunk(rule, after)
+		if errors.Is(err, errChunkStale) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		b.snapshot.addRows(table, chunk.emitted)
+
+		if len(chunk.rows) < b.snapshot.chunkSize {
+			return nil
+		}
+
+		after, err = pkValues(rule.Source.PKs, chunk.rows[len(chunk.rows)-1])
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Bridge) copyChunk(rule *mymy.Rule, after []interface{}) (*snapshotChunk, error) {
+	key := mymy.RuleKey(rule.Source.Schema, rule.Source.Table)
+	chunk := newSnapshotChunk(key)
+
+	b.snapshot.setChunk(chunk)
+	defer b.snapshot.setChunk(nil)
+
+	if err := b.writeWatermark(watermarkLow, chunk.id); err != nil {
+		return nil, err
+	}
+
+	rows, err := readChunk(b.currentCanal(), rule.Source, after, b.snapshot.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	b.snapshot.setRows(chunk, rows)
+
+	if err := b.writeWatermark(watermarkHigh, chunk.id); err != nil {
+		return nil, err
+	}
+
+	select {
+	case err = <-chunk.done:
+		return chunk, err
+	case <-b.ctx.Done():
+		return nil, b.ctx.Err()
+	}
+}
+
+func (b *Bridge) writeWatermark(kind, id string) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s.%s (id, %s) VALUES (1, ?) ON DUPLICATE KEY UPDATE %s = VALUES(%s)",
+		quoteName(b.snapshot.schema), quoteName(b.snapshot.watermark),
+		watermarkValueColumn, watermarkValueColumn, watermarkValueColumn,
+	)
+
+	_, err := b.currentCanal().Execute(query, kind+":"+id)
+	if err != nil {
+		return fmt.Errorf("could not write %s watermark: %w", kind, err)
+	}
+
+	return nil
+}
+
+// executor runs SQL statements on the source database.
+type executor interface {
+	Execute(cmd string, args ...interface{}) (*mysql.Result, error)
+}
+
+// readChunk reads up to limit rows of the source table ordered by the primary key
+// and placed after the given primary key values.
+func readChunk(ex executor, info mymy.SourceInfo, after []interface{}, limit int) ([][]interface{}, error) {
+	query := buildChunkQuery(info, len(after) > 0, limit)
+	res, err := ex.Execute(query, after...)
+	if err != nil {
+		return nil, fmt.Errorf("could not read chunk of %s.%s: %w", info.Schema, info.Table, err)
+	}
+
+	rows := make([][]interface{}, 0, len(res.Values))
+	for _, values := range res.Values {
+		row := make([]interface{}, len(values))
+		for i := range values {
+			row[i] = normalizeValue(values[i].Value())
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// onWatermark emits the chunk to the upstream when its high watermark is received.
+// It is called from the binlog reader goroutine, so the chunk is placed
+// into the sync queue in the binlog order.
+func (b *Bridge) onWatermark(e *canal.RowsEvent) error {
+	idx := e.Table.FindColumn(watermarkValueColumn)
+	if idx < 0 {
+		return fmt.Errorf("watermark table %s has no column %s", e.Table.Name, watermarkValueColumn)
+	}
+
+	for i, row := range e.Rows {
+		// Only after-images matter for the updates.
+		if e.Action == canal.UpdateAction && i%2 == 0 {
+			continue
+		}
+
+		chunk := b.snapshot.onWatermark(fmt.Sprintf("%s", row[idx]))
+		if chunk != nil {
+			chunk.done <- b.emitChunk(chunk)
+		}
+	}
+
+	return nil
+}
+
+func (b *Bridge) emitChunk(chunk *snapshotChunk) error {
+	if chunk.stale {
+		return errChunkStale
+	}
+
+	b.handlersMu.RLock()
+	rule, ok := b.rule(chunk.ruleKey)
+	if !ok {
+		b.handlersMu.RUnlock()
+
+		return ErrRuleNotExist
+	}
+
+	rows := chunk.pending(rule.Source.PKs)
+	if len(rows) == 0 {
+		b.handlersMu.RUnlock()
+
+		return nil
+	}
+
+	queries, err := rule.Handler.OnRows(&mymy.RowsEvent{
+		Action: mymy.ActionInsert,
+		Source: rule.Source,
+		Rows:   rows,
+	})
+	b.handlersMu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("snapshot request, what: %w", err)
+	}
+
+	// The upstream may already contain the rows.
+	for _, query := range queries {
+		if query.Action == mymy.ActionInsert {
+			query.Action = mymy.ActionUpsert
+		}
+	}
+
+	if err = b.queue.push(b.ctx, batch(queries)); err != nil {
+		return err
+	}
+	chunk.emitted = len(rows)
+
+	return nil
+}
+
+func buildChunkQuery(info mymy.SourceInfo, after bool, limit int) string {
+	pks := make([]string, 0, len(info.PKs))
+	for _, pk := range info.PKs {
+		pks = append(pks, quoteName(pk.Name))
+	}
+	pkList := strings.Join(pks, ",")
+
+	var sb strings.Builder
+	sb.WriteString("SELECT * FROM ")
+	sb.WriteString(quoteName(info.Schema))
+	sb.WriteRune('.')
+	sb.WriteString(quoteName(info.Table))
+	if after {
+		sb.WriteString(" WHERE (")
+		sb.WriteString(pkList)
+		sb.WriteString(") > (?")
+		sb.WriteString(strings.Repeat(",?", len(pks)-1))
+		sb.WriteRune(')')
+	}
+	sb.WriteString(" ORDER BY ")
+	sb.WriteString(pkList)
+	sb.WriteString(" LIMIT ")
+	sb.WriteString(fmt.Sprint(limit))
+
+	return sb.String()
+}
+
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// normalizeValue converts the value read by the SQL client
+// to the form used in the binlog rows events.
+func normalizeValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+
+	return v
+}
+
+func pkValues(pks []mymy.Column, row []interface{}) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(pks))
+	for _, pk := range pks {
+		v, err := pk.GetValue(row)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+func pkKey(pks []mymy.Column, row []interface{}) string {
+	var sb strings.Builder
+	for i, pk := range pks {
+		if i > 0 {
+			sb.WriteString(pkKeySeparator)
+		}
+
+		v, err := pk.GetValue(row)
+		if err != nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprint(normalizeValue(v)))
+	}
+
+	return sb.String()
+}
+
+func newWatermarkID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
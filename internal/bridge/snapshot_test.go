@@ -0,0 +1,125 @@
+package bridge
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+var tSnapshotSource = mymy.SourceInfo{
+	Schema: "city",
+	Table:  "users",
+	PKs: []mymy.Column{
+		{Index: 0, Name: "id", Type: mymy.TypeNumber},
+	},
+	Cols: []mymy.Column{
+		{Index: 1, Name: "name", Type: mymy.TypeString},
+	},
+}
+
+func TestBuildChunkQuery(t *testing.T) {
+	compositePK := tSnapshotSource
+	compositePK.PKs = []mymy.Column{
+		{Index: 0, Name: "id", Type: mymy.TypeNumber},
+		{Index: 1, Name: "name", Type: mymy.TypeString},
+	}
+
+	tests := []struct {
+		name  string
+		info  mymy.SourceInfo
+		after bool
+		want  string
+	}{
+		{
+			name: "FirstChunk",
+			info: tSnapshotSource,
+			want: "SELECT * FROM `city`.`users` ORDER BY `id` LIMIT 100",
+		},
+		{
+			name:  "NextChunk",
+			info:  tSnapshotSource,
+			after: true,
+			want:  "SELECT * FROM `city`.`users` WHERE (`id`) > (?) ORDER BY `id` LIMIT 100",
+		},
+		{
+			name:  "CompositePK",
+			info:  compositePK,
+			after: true,
+			want:  "SELECT * FROM `city`.`users` WHERE (`id`,`name`) > (?,?) ORDER BY `id`,`name` LIMIT 100",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildChunkQuery(tt.info, tt.after, 100)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSnapshotter_Watermarks(t *testing.T) {
+	s := &snapshotter{
+		schema:    "city",
+		watermark: "mymy_watermark",
+		chunkSize: 100,
+		mu:        &sync.Mutex{},
+		statuses:  make(map[string]*SnapshotStatus),
+	}
+
+	key := mymy.RuleKey("city", "users")
+	chunk := newSnapshotChunk(key)
+	s.setChunk(chunk)
+
+	// Changes before the low watermark are already in the chunk.
+	s.observe(key, tSnapshotSource.PKs, [][]interface{}{{int64(1), "bob"}})
+	assert.Nil(t, s.onWatermark(watermarkLow+":"+chunk.id))
+
+	s.setRows(chunk, [][]interface{}{
+		{uint64(1), "bob"},
+		{uint64(2), "alice"},
+		{uint64(3), "john"},
+	})
+
+	// Changes between the watermarks win over the chunk rows.
+	s.observe(key, tSnapshotSource.PKs, [][]interface{}{{int64(2), "eve"}})
+	s.observe(mymy.RuleKey("city", "orders"), tSnapshotSource.PKs, [][]interface{}{{int64(3), "john"}})
+
+	assert.Nil(t, s.onWatermark(watermarkHigh+":unknown"))
+
+	got := s.onWatermark(watermarkHigh + ":" + chunk.id)
+	require.Same(t, chunk, got)
+	assert.Nil(t, s.chunk)
+
+	want := [][]interface{}{
+		{uint64(1), "bob"},
+		{uint64(3), "john"},
+	}
+	assert.Equal(t, want, got.pending(tSnapshotSource.PKs))
+}
+
+func TestSnapshotter_Begin(t *testing.T) {
+	s := &snapshotter{
+		mu:       &sync.Mutex{},
+		statuses: make(map[string]*SnapshotStatus),
+	}
+
+	require.NoError(t, s.begin("users"))
+	assert.Equal(t, ErrSnapshotInProgress, s.begin("orders"))
+
+	s.addRows("users", 10)
+	s.finish("users", nil)
+	require.NoError(t, s.begin("orders"))
+
+	list := s.list()
+	require.Len(t, list, 2)
+	assert.Equal(t, "orders", list[0].Table)
+	assert.Equal(t, SnapshotRunning, list[0].State)
+	assert.Equal(t, "users", list[1].Table)
+	assert.Equal(t, SnapshotDone, list[1].State)
+	assert.EqualValues(t, 10, list[1].Rows)
+}
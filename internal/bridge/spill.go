@@ -0,0 +1,304 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+// Kinds of the spilled events.
+const (
+	spilledRows      = "rows"
+	spilledBatch     = "batch"
+	spilledPos       = "pos"
+	spilledHeartbeat = "heartbeat"
+)
+
+func init() {
+	// The query values are kept as is, the basic types are registered by gob itself.
+	gob.Register(time.Time{})
+}
+
+// spilledEvent is the event written to the spill file using gob,
+// so the query values keep their types and precision.
+type spilledEvent struct {
+	Kind    string
+	Queries []*mymy.Query
+	Rows    int
+	Table   string
+	Action  string
+	GTID    string
+	// At is the binlog time of the rows event.
+	At  time.Time
+	Err string
+	// GTIDSet or BinlogPos is the JSON encoded position to save.
+	GTIDSet   []byte
+	BinlogPos []byte
+	Force     bool
+	SentAt    time.Time
+}
+
+func encodeSpilled(item interface{}) (*spilledEvent, error) {
+	switch v := item.(type) {
+	case *rowsEvent:
+		// The trace context can not be written to disk, so the queue span ends here.
+		v.dequeued()
+
+		e := &spilledEvent{
+			Kind:    spilledRows,
+			Queries: v.queries,
+			Rows:    v.rows,
+			Table:   v.table,
+			Action:  v.action,
+			GTID:    v.gtid,
+			At:      v.at,
+		}
+		if v.err != nil {
+			e.Err = v.err.Error()
+		}
+
+		return e, nil
+	case batch:
+		return &spilledEvent{
+			Kind:    spilledBatch,
+			Queries: v,
+		}, nil
+	case *savePos:
+		e := &spilledEvent{
+			Kind:  spilledPos,
+			Force: v.force,
+		}
+
+		var err error
+		switch pos := v.pos.(type) {
+		case *gtidSet:
+			e.GTIDSet, err = json.Marshal(pos)
+		case *binlogPos:
+			e.BinlogPos, err = json.Marshal(pos)
+		default:
+			return nil, fmt.Errorf("unsupported position to spill: %T", v.pos)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return e, nil
+	case *heartbeatEvent:
+		return &spilledEvent{
+			Kind:   spilledHeartbeat,
+			SentAt: v.sentAt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported event to spill: %T", item)
+	}
+}
+
+func (e *spilledEvent) decode() (interface{}, error) {
+	switch e.Kind {
+	case spilledRows:
+		v := &rowsEvent{
+			queries: e.Queries,
+			rows:    e.Rows,
+			table:   e.Table,
+			action:  e.Action,
+			gtid:    e.GTID,
+			at:      e.At,
+		}
+		if e.Err != "" {
+			v.err = errors.New(e.Err)
+		}
+
+		return v, nil
+	case spilledBatch:
+		return batch(e.Queries), nil
+	case spilledPos:
+		v := &savePos{
+			force: e.Force,
+		}
+		switch {
+		case e.GTIDSet != nil:
+			pos := &gtidSet{}
+			if err := json.Unmarshal(e.GTIDSet, pos); err != nil {
+				return nil, err
+			}
+			v.pos = pos
+		case e.BinlogPos != nil:
+			pos := &binlogPos{}
+			if err := json.Unmarshal(e.BinlogPos, pos); err != nil {
+				return nil, err
+			}
+			v.pos = pos
+		default:
+			return nil, errors.New("spilled position is empty")
+		}
+
+		return v, nil
+	case spilledHeartbeat:
+		return &heartbeatEvent{
+			sentAt: e.SentAt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown spilled event kind: %s", e.Kind)
+	}
+}
+
+// spillFile is an append-only file of the events read sequentially.
+// The events are needed only until they are applied, so the file
+// is truncated once all of them are read and removed on close.
+// The file is a single gob stream, so the types are written once.
+type spillFile struct {
+	mu       *sync.Mutex
+	w        *os.File
+	r        *os.File
+	br       *bufio.Reader
+	buf      *bytes.Buffer
+	enc      *gob.Encoder
+	dec      *gob.Decoder
+	maxBytes int64
+
+	size   int64
+	writes int
+	reads  int
+}
+
+func openSpillFile(dir string, maxBytes int64) (*spillFile, error) {
+	w, err := ioutil.TempFile(dir, "mymy-*.spill")
+	if err != nil {
+		return nil, fmt.Errorf("could not create spill file: %w", err)
+	}
+
+	r, err := os.Open(w.Name())
+	if err != nil {
+		_ = w.Close()
+		_ = os.Remove(w.Name())
+
+		return nil, fmt.Errorf("could not open spill file: %w", err)
+	}
+
+	f := &spillFile{
+		mu:       &sync.Mutex{},
+		w:        w,
+		r:        r,
+		br:       bufio.NewReader(r),
+		buf:      &bytes.Buffer{},
+		maxBytes: maxBytes,
+	}
+	f.newStream()
+
+	return f, nil
+}
+
+// newStream starts the gob stream from the beginning of the file.
+func (f *spillFile) newStream() {
+	f.enc = gob.NewEncoder(f.buf)
+	f.dec = gob.NewDecoder(f.br)
+}
+
+func (f *spillFile) write(item interface{}) error {
+	e, err := encodeSpilled(item)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The event is written at once, so the reader never sees a part of it.
+	f.buf.Reset()
+	if err = f.enc.Encode(e); err != nil {
+		return fmt.Errorf("could not encode spilled event: %w", err)
+	}
+
+	n, err := f.w.Write(f.buf.Bytes())
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write spill file: %w", err)
+	}
+	f.writes++
+
+	return nil
+}
+
+// read returns the next event. It must be called only if there are pending events.
+func (f *spillFile) read() (interface{}, error) {
+	var e spilledEvent
+	if err := f.dec.Decode(&e); err != nil {
+		return nil, fmt.Errorf("could not read spill file: %w", err)
+	}
+
+	f.mu.Lock()
+	f.reads++
+	f.mu.Unlock()
+
+	return e.decode()
+}
+
+func (f *spillFile) pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.writes - f.reads
+}
+
+func (f *spillFile) sizeBytes() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.size
+}
+
+func (f *spillFile) full() bool {
+	return f.maxBytes > 0 && f.sizeBytes() >= f.maxBytes
+}
+
+// reset truncates the file. It must be called only if there are no pending events.
+func (f *spillFile) reset() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size == 0 {
+		return nil
+	}
+
+	if err := f.w.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.w.Seek(0, 0); err != nil {
+		return err
+	}
+	if _, err := f.r.Seek(0, 0); err != nil {
+		return err
+	}
+	f.br.Reset(f.r)
+	f.newStream()
+
+	f.size = 0
+	f.writes = 0
+	f.reads = 0
+
+	return nil
+}
+
+func (f *spillFile) close() error {
+	errW := f.w.Close()
+	errR := f.r.Close()
+	errRm := os.Remove(f.w.Name())
+
+	if errW != nil {
+		return errW
+	}
+	if errR != nil {
+		return errR
+	}
+
+	return errRm
+}
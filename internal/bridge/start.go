@@ -0,0 +1,254 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/siddontang/go-mysql/replication"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+// applyStart saves the configured start position if no position is saved yet,
+// so the replication skips the dump and continues from it.
+func (b *Bridge) applyStart() error {
+	start := b.cfg.Replication.Start
+	if !start.Enabled() {
+		return nil
+	}
+
+	if !isEmptyPosition(b.stateSaver.position()) {
+		b.logger.Info().Msg("position is saved, replication.start is ignored")
+
+		return nil
+	}
+
+	pos, err := resolvePosition(b.ctx, b.cfg, start)
+	if err != nil {
+		return fmt.Errorf("could not resolve start position: %w", err)
+	}
+
+	b.logger.Info().Str("position", pos.String()).Msg("starting from the configured position without the dump")
+
+	return b.stateSaver.save(pos, true)
+}
+
+// resolvePosition converts the configured position to the position of the replication mode
+// and checks the source still has the binlog of it.
+func resolvePosition(ctx context.Context, cfg *config.Config, pc config.PositionConfig) (position, error) {
+	source, err := newSourceClient(cfg.Replication.SourceOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = source.Close()
+	}()
+
+	inspector := newBinlogInspector(source, cfg)
+
+	target := PositionTarget{
+		GTID: pc.GTID,
+		File: pc.File,
+		Pos:  pc.Pos,
+	}
+	if target.File != "" && target.Pos == 0 {
+		target.Pos = 4
+	}
+
+	if pc.Time != "" {
+		at, errTime := pc.Timestamp()
+		if errTime != nil {
+			return nil, errTime
+		}
+
+		target.File, target.Pos, err = inspector.timeToFilePos(ctx, cfg, at)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pos, err := inspector.resolve(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if info := inspector.describe(ctx, pos); info.Error != "" {
+		return nil, errors.New(info.Error)
+	}
+
+	return pos, nil
+}
+
+// isEmptyPosition returns true if the position is not set, so the replication starts with the dump.
+func isEmptyPosition(pos position) bool {
+	switch p := pos.(type) {
+	case *gtidSet:
+		return p.String() == ""
+	case *binlogPos:
+		return p.pos.Name == ""
+	}
+
+	return pos == nil
+}
+
+// timeToFilePos returns the end of the last transaction written to the binlog before the time.
+// The binlog is read by the replication protocol, because SHOW BINLOG EVENTS has no timestamps.
+func (i *binlogInspector) timeToFilePos(ctx context.Context, cfg *config.Config, at time.Time) (string, uint32, error) {
+	// The event timestamps have the seconds precision.
+	at = at.Truncate(time.Second)
+
+	endFile, endPos, _, err := i.masterStatus(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	files, err := queryRows(ctx, i.source, "SHOW BINARY LOGS")
+	if err != nil {
+		return "", 0, err
+	}
+
+	syncerCfg, err := newSyncerConfig(cfg)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// The last file started before the time.
+	for n := len(files) - 1; n >= 0; n-- {
+		file := files[n]["Log_name"]
+
+		started, errStart := binlogStartTime(ctx, syncerCfg, file)
+		if errStart != nil {
+			return "", 0, errStart
+		}
+		if started.After(at) {
+			continue
+		}
+
+		return scanToTime(ctx, syncerCfg, file, at, mysql.Position{Name: endFile, Pos: endPos})
+	}
+
+	return "", 0, fmt.Errorf("%w: the oldest binlog file starts after %s", ErrBinlogPurged, at.Format(time.RFC3339))
+}
+
+func newSyncerConfig(cfg *config.Config) (replication.BinlogSyncerConfig, error) {
+	opts := cfg.Replication.SourceOpts
+
+	host, portStr, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+
+	tlsCfg, err := sourceTLSConfig(opts)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+
+	// The same as the canal does, it is not started yet.
+	serverID := uint32(rand.New(rand.NewSource(time.Now().Unix())).Intn(1000)) + 1001 //nolint:gosec
+	if cfg.Replication.ServerID != nil {
+		serverID = *cfg.Replication.ServerID
+	}
+
+	return replication.BinlogSyncerConfig{
+		ServerID:  serverID,
+		Flavor:    opts.Flavor,
+		Host:      host,
+		Port:      uint16(port),
+		User:      opts.User,
+		Password:  string(opts.Password),
+		Charset:   opts.Charset,
+		TLSConfig: tlsCfg,
+	}, nil
+}
+
+// binlogStartTime returns the timestamp of the first event of the binlog file.
+func binlogStartTime(ctx context.Context, cfg replication.BinlogSyncerConfig, file string) (time.Time, error) {
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(mysql.Position{Name: file, Pos: 4})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for {
+		ev, errEvent := streamer.GetEvent(ctx)
+		if errEvent != nil {
+			return time.Time{}, errEvent
+		}
+
+		// The fake rotate event has no timestamp.
+		if ev.Header.Timestamp != 0 {
+			return time.Unix(int64(ev.Header.Timestamp), 0), nil
+		}
+	}
+}
+
+// scanToTime reads the binlog from the start of the file up to the first event at or after
+// the time or up to the end position and returns the end of the last transaction before it.
+func scanToTime(
+	ctx context.Context, cfg replication.BinlogSyncerConfig, file string, at time.Time, end mysql.Position,
+) (string, uint32, error) {
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(mysql.Position{Name: file, Pos: 4})
+	if err != nil {
+		return "", 0, err
+	}
+
+	committed := mysql.Position{Name: file, Pos: 4}
+	current := file
+	for {
+		ev, errEvent := streamer.GetEvent(ctx)
+		if errEvent != nil {
+			return "", 0, errEvent
+		}
+
+		header := ev.Header
+		if e, ok := ev.Event.(*replication.RotateEvent); ok {
+			current = string(e.NextLogName)
+			committed = mysql.Position{Name: current, Pos: uint32(e.Position)}
+
+			continue
+		}
+
+		if !isHeaderEvent(ev.Event) && !time.Unix(int64(header.Timestamp), 0).Before(at) {
+			return committed.Name, committed.Pos, nil
+		}
+
+		switch e := ev.Event.(type) {
+		case *replication.XIDEvent:
+			committed = mysql.Position{Name: current, Pos: header.LogPos}
+		case *replication.QueryEvent:
+			if string(e.Query) != "BEGIN" {
+				committed = mysql.Position{Name: current, Pos: header.LogPos}
+			}
+		}
+
+		// Nothing is written after the time yet.
+		if current == end.Name && header.LogPos >= end.Pos {
+			return committed.Name, committed.Pos, nil
+		}
+	}
+}
+
+// isHeaderEvent returns true for the events written at the start of each binlog file.
+func isHeaderEvent(e replication.Event) bool {
+	switch e.(type) {
+	case *replication.FormatDescriptionEvent, *replication.PreviousGTIDsEvent, *replication.MariadbGTIDListEvent:
+		return true
+	}
+
+	return false
+}
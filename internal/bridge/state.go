@@ -3,6 +3,7 @@ package bridge
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -11,6 +12,8 @@ import (
 	"github.com/siddontang/go-mysql/mysql"
 	"github.com/siddontang/go/ioutil2"
 
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
 	"github.com/city-mobil/go-mymy/internal/util"
 )
 
@@ -31,25 +34,28 @@ type savePos struct {
 }
 
 type gtidSet struct {
-	pos mysql.GTIDSet
+	flavor string
+	pos    mysql.GTIDSet
 }
 
-func newGTIDSet(pos mysql.GTIDSet) *gtidSet {
+func newGTIDSet(flavor string, pos mysql.GTIDSet) *gtidSet {
 	return &gtidSet{
-		pos: pos,
+		flavor: flavor,
+		pos:    pos,
 	}
 }
 
 func (g *gtidSet) clone() position {
 	return &gtidSet{
-		pos: g.pos.Clone(),
+		flavor: g.flavor,
+		pos:    g.pos.Clone(),
 	}
 }
 
 func (g *gtidSet) equal(another position) bool {
 	switch v := another.(type) {
 	case *gtidSet:
-		return g.pos.Equal(v.pos)
+		return g.flavor == v.flavor && g.pos.Equal(v.pos)
 	default:
 		return false
 	}
@@ -65,25 +71,35 @@ func (g *gtidSet) String() string {
 
 func (g *gtidSet) MarshalJSON() ([]byte, error) {
 	return json.Marshal(&struct {
-		GTID string `json:"gtid"`
+		Flavor string `json:"flavor"`
+		GTID   string `json:"gtid"`
 	}{
-		GTID: g.String(),
+		Flavor: g.flavor,
+		GTID:   g.String(),
 	})
 }
 
 func (g *gtidSet) UnmarshalJSON(b []byte) error {
 	s := struct {
-		GTID string `json:"gtid"`
+		Flavor string `json:"flavor"`
+		GTID   string `json:"gtid"`
 	}{}
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
 
-	set, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, s.GTID)
+	// The state files saved before the flavor was introduced
+	// contain MySQL GTID sets only.
+	if s.Flavor == "" {
+		s.Flavor = mysql.MySQLFlavor
+	}
+
+	set, err := mysql.ParseGTIDSet(s.Flavor, s.GTID)
 	if err != nil {
 		return err
 	}
 
+	g.flavor = s.Flavor
 	g.pos = set
 
 	return nil
@@ -148,38 +164,91 @@ func (b *binlogPos) UnmarshalJSON(src []byte) error {
 	return nil
 }
 
+// emptyPosition returns the position to start from when nothing is saved yet.
+func emptyPosition(gtidMode bool, flavor string) (position, error) {
+	if !gtidMode {
+		return &binlogPos{pos: mysql.Position{}}, nil
+	}
+
+	set, err := mysql.ParseGTIDSet(flavor, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return newGTIDSet(flavor, set), nil
+}
+
+func decodePosition(r io.Reader, gtidMode bool) (position, error) {
+	var pos position
+	if gtidMode {
+		pos = &gtidSet{}
+	} else {
+		pos = &binlogPos{}
+	}
+
+	if err := json.NewDecoder(r).Decode(&pos); err != nil {
+		return nil, err
+	}
+
+	return pos, nil
+}
+
+// readPosition returns the saved position without creating the saver, so nothing
+// is written back. The upstream client is required if the position is stored in the table.
+func readPosition(cfg *config.Config, upstream *client.SQLClient) (position, error) {
+	var (
+		pos position
+		err error
+	)
+
+	gtidMode := cfg.Replication.GTIDMode
+	flavor := cfg.Replication.SourceOpts.Flavor
+	if state := cfg.App.State; state.Table != "" {
+		pos, err = readTablePosition(upstream, state.Table, state.Key, gtidMode, flavor)
+	} else {
+		pos, err = readFilePosition(util.AbsPath(cfg.App.DataFile), gtidMode, flavor)
+	}
+	if err != nil || pos != nil {
+		return pos, err
+	}
+
+	return emptyPosition(gtidMode, flavor)
+}
+
 type stateSaver interface {
 	load() (position, error)
 	save(pos position, force bool) error
 	position() position
+	// reset removes the saved position.
+	reset() error
 	close() error
 }
 
 type fileSaver struct {
 	pos      position
 	gtidMode bool
+	flavor   string
 	filepath string
 	savedAt  int64
 
 	mu *sync.RWMutex
 }
 
-func newFileSaver(path string, gtidMode bool) (*fileSaver, error) {
+func newFileSaver(path string, gtidMode bool, flavor string) (*fileSaver, error) {
 	path = util.AbsPath(path)
 	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
 		return nil, err
 	}
 
-	var pos position
-	if gtidMode {
-		pos = &gtidSet{pos: emptyGTID}
-	} else {
-		pos = &binlogPos{pos: mysql.Position{}}
+	pos, err := emptyPosition(gtidMode, flavor)
+	if err != nil {
+		return nil, err
 	}
 
 	return &fileSaver{
 		pos:      pos,
 		gtidMode: gtidMode,
+		flavor:   flavor,
 		filepath: path,
 		savedAt:  time.Now().Unix(),
 		mu:       &sync.RWMutex{},
@@ -190,29 +259,40 @@ func (s *fileSaver) load() (position, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	f, err := os.Open(s.filepath)
-	if err != nil && !os.IsNotExist(err) {
+	pos, err := readFilePosition(s.filepath, s.gtidMode, s.flavor)
+	if err != nil {
 		return nil, err
-	} else if os.IsNotExist(err) {
+	}
+	if pos == nil {
 		return s.pos, nil
 	}
+
+	s.pos = pos
+
+	return pos, nil
+}
+
+// readFilePosition returns the position saved in the state file, nil if the file does not exist.
+func readFilePosition(path string, gtidMode bool, flavor string) (position, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		_ = f.Close()
 	}()
 
-	var pos position
-	if s.gtidMode {
-		pos = &gtidSet{}
-	} else {
-		pos = &binlogPos{}
-	}
-
-	err = json.NewDecoder(f).Decode(&pos)
+	pos, err := decodePosition(f, gtidMode)
 	if err != nil {
 		return nil, err
 	}
 
-	s.pos = pos
+	if set, ok := pos.(*gtidSet); ok && set.flavor != flavor {
+		return nil, fmt.Errorf("state file %s contains %s GTID set, but source flavor is %s", path, set.flavor, flavor)
+	}
 
 	return pos, nil
 }
@@ -257,6 +337,24 @@ func (s *fileSaver) position() position {
 	return s.pos.clone()
 }
 
+func (s *fileSaver) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, err := emptyPosition(s.gtidMode, s.flavor)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(s.filepath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.pos = pos
+
+	return nil
+}
+
 func (s *fileSaver) close() error {
 	return s.save(s.position(), true)
 }
@@ -0,0 +1,202 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+)
+
+// tableSaver stores the position in the upstream table, so the instances
+// sharing the key continue from the same position.
+type tableSaver struct {
+	pos      position
+	saved    position
+	gtidMode bool
+	flavor   string
+	client   *client.SQLClient
+	table    string
+	key      string
+	savedAt  int64
+	// elector fences the writes of the instance which lost the HA lock, nil without HA.
+	elector *elector
+
+	mu *sync.RWMutex
+}
+
+func newTableSaver(c *client.SQLClient, table, key string, gtidMode bool, flavor string, e *elector) (*tableSaver, error) {
+	pos, err := emptyPosition(gtidMode, flavor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tableSaver{
+		pos:      pos,
+		gtidMode: gtidMode,
+		flavor:   flavor,
+		client:   c,
+		table:    table,
+		key:      key,
+		savedAt:  time.Now().Unix(),
+		elector:  e,
+		mu:       &sync.RWMutex{},
+	}, nil
+}
+
+func (s *tableSaver) load() (position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, err := readTablePosition(s.client, s.table, s.key, s.gtidMode, s.flavor)
+	if err != nil {
+		return nil, err
+	}
+	if pos == nil {
+		return s.pos, nil
+	}
+
+	s.pos = pos
+	s.saved = pos
+
+	return pos, nil
+}
+
+// readTablePosition returns the position saved in the state table, nil if the key is not found.
+func readTablePosition(c *client.SQLClient, table, key string, gtidMode bool, flavor string) (position, error) {
+	query := fmt.Sprintf("SELECT position FROM %s WHERE name = ?", quoteName(table))
+
+	var data string
+	err := c.QueryRow(context.Background(), query, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not load sync position from table %s: %w", table, err)
+	}
+
+	pos, err := decodePosition(strings.NewReader(data), gtidMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if set, ok := pos.(*gtidSet); ok && set.flavor != flavor {
+		return nil, fmt.Errorf("state table %s contains %s GTID set, but source flavor is %s", table, set.flavor, flavor)
+	}
+
+	return pos, nil
+}
+
+func (s *tableSaver) save(pos position, force bool) error {
+	if pos == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pos = pos
+
+	now := time.Now().Unix()
+	if !force && (now-s.savedAt < saveThreshold) {
+		return nil
+	}
+	s.savedAt = now
+
+	// The unchanged position would not be counted as affected row by the fenced write.
+	if s.saved != nil && s.saved.equal(pos) {
+		return nil
+	}
+
+	buf, err := json.Marshal(pos)
+	if err != nil {
+		return fmt.Errorf("failed to save sync position, pos: %s, what: %w", pos, err)
+	}
+
+	if err = s.write(string(buf)); err != nil {
+		return fmt.Errorf("failed to save sync position, table: %s, pos: %s, what: %w", s.table, pos, err)
+	}
+	s.saved = pos.clone()
+
+	return nil
+}
+
+func (s *tableSaver) write(data string) error {
+	ctx := context.Background()
+
+	if s.elector == nil {
+		query := fmt.Sprintf(
+			"INSERT INTO %s (name, position) VALUES (?, ?) ON DUPLICATE KEY UPDATE position = VALUES(position)",
+			quoteName(s.table),
+		)
+		_, err := s.client.Exec(ctx, query, s.key, data)
+
+		return err
+	}
+
+	// The position is written only while the lock is held by this instance,
+	// so the former leader does not overwrite the position of the new one.
+	query := fmt.Sprintf(
+		"INSERT INTO %s (name, position) SELECT ?, ? FROM DUAL WHERE IS_USED_LOCK(?) = ? "+
+			"ON DUPLICATE KEY UPDATE position = VALUES(position)",
+		quoteName(s.table),
+	)
+	res, err := s.client.Exec(ctx, query, s.key, data, s.elector.lock, s.elector.holder())
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeadershipLost
+	}
+
+	return nil
+}
+
+func (s *tableSaver) position() position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.pos == nil {
+		return nil
+	}
+
+	return s.pos.clone()
+}
+
+func (s *tableSaver) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos, err := emptyPosition(s.gtidMode, s.flavor)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE name = ?", quoteName(s.table))
+	if _, err = s.client.Exec(context.Background(), query, s.key); err != nil {
+		return fmt.Errorf("could not reset sync position in table %s: %w", s.table, err)
+	}
+	s.pos = pos
+	s.saved = nil
+
+	return nil
+}
+
+func (s *tableSaver) close() error {
+	// The standby has nothing to save.
+	if s.elector != nil && s.elector.holder() == 0 {
+		return nil
+	}
+
+	return s.save(s.position(), true)
+}
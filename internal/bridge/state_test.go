@@ -21,7 +21,7 @@ func TestPosition_Clone(t *testing.T) {
 	}{
 		{
 			name: "GTID",
-			pos:  newGTIDSet(gtid),
+			pos:  newGTIDSet(mysql.MySQLFlavor, gtid),
 		},
 		{
 			name: "Binlog",
@@ -46,6 +46,11 @@ func TestPosition_Marshal(t *testing.T) {
 	gtid, err := mysql.ParseMysqlGTIDSet("07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564")
 	require.NoError(t, err)
 
+	// MariaDB GTID set keeps the domains in a map, so a single domain
+	// is used to get a stable string representation.
+	mariaGTID, err := mysql.ParseMariadbGTIDSet("0-1-100")
+	require.NoError(t, err)
+
 	tests := []struct {
 		name string
 		pos  position
@@ -53,8 +58,13 @@ func TestPosition_Marshal(t *testing.T) {
 	}{
 		{
 			name: "GTID",
-			pos:  newGTIDSet(gtid),
-			want: `{"gtid": "07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564"}`,
+			pos:  newGTIDSet(mysql.MySQLFlavor, gtid),
+			want: `{"flavor": "mysql", "gtid": "07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564"}`,
+		},
+		{
+			name: "MariaDBGTID",
+			pos:  newGTIDSet(mysql.MariaDBFlavor, mariaGTID),
+			want: `{"flavor": "mariadb", "gtid": "0-1-100"}`,
 		},
 		{
 			name: "Binlog",
@@ -81,6 +91,9 @@ func TestPosition_Unmarshal(t *testing.T) {
 	gtid, err := mysql.ParseMysqlGTIDSet("07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564, 0d4becf8-5970-11ea-819f-1c34da0723b1:1-90")
 	require.NoError(t, err)
 
+	mariaGTID, err := mysql.ParseMariadbGTIDSet("0-1-100,1-2-50")
+	require.NoError(t, err)
+
 	tests := []struct {
 		name     string
 		pos      string
@@ -89,8 +102,20 @@ func TestPosition_Unmarshal(t *testing.T) {
 	}{
 		{
 			name:     "GTID",
+			pos:      `{"flavor": "mysql", "gtid": "07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564,0d4becf8-5970-11ea-819f-1c34da0723b1:1-90"}`,
+			want:     newGTIDSet(mysql.MySQLFlavor, gtid),
+			gtidMode: true,
+		},
+		{
+			name:     "GTIDWithoutFlavor",
 			pos:      `{"gtid": "07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564,0d4becf8-5970-11ea-819f-1c34da0723b1:1-90"}`,
-			want:     newGTIDSet(gtid),
+			want:     newGTIDSet(mysql.MySQLFlavor, gtid),
+			gtidMode: true,
+		},
+		{
+			name:     "MariaDBGTID",
+			pos:      `{"flavor": "mariadb", "gtid": "0-1-100,1-2-50"}`,
+			want:     newGTIDSet(mysql.MariaDBFlavor, mariaGTID),
 			gtidMode: true,
 		},
 		{
@@ -129,6 +154,12 @@ func TestFileSaver_SaveLoad(t *testing.T) {
 	newGTID, err := mysql.ParseMysqlGTIDSet("07812e7f-5dad-11e6-b5b3-525400d2e382:1-939900")
 	require.NoError(t, err)
 
+	oldMariaGTID, err := mysql.ParseMariadbGTIDSet("0-1-100")
+	require.NoError(t, err)
+
+	newMariaGTID, err := mysql.ParseMariadbGTIDSet("0-1-200")
+	require.NoError(t, err)
+
 	dataDir := "/tmp/mymy-save-test"
 	dataFile := path.Join(dataDir, "master.info")
 
@@ -137,12 +168,21 @@ func TestFileSaver_SaveLoad(t *testing.T) {
 		oldPos   position
 		newPos   position
 		gtidMode bool
+		flavor   string
 	}{
 		{
 			name:     "GTID",
-			oldPos:   newGTIDSet(oldGTID),
-			newPos:   newGTIDSet(newGTID),
+			oldPos:   newGTIDSet(mysql.MySQLFlavor, oldGTID),
+			newPos:   newGTIDSet(mysql.MySQLFlavor, newGTID),
+			gtidMode: true,
+			flavor:   mysql.MySQLFlavor,
+		},
+		{
+			name:     "MariaDBGTID",
+			oldPos:   newGTIDSet(mysql.MariaDBFlavor, oldMariaGTID),
+			newPos:   newGTIDSet(mysql.MariaDBFlavor, newMariaGTID),
 			gtidMode: true,
+			flavor:   mysql.MariaDBFlavor,
 		},
 		{
 			name: "Binlog",
@@ -155,13 +195,14 @@ func TestFileSaver_SaveLoad(t *testing.T) {
 				Pos:  394877900,
 			}),
 			gtidMode: false,
+			flavor:   mysql.MySQLFlavor,
 		},
 	}
 
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			fs, err := newFileSaver(dataFile, tt.gtidMode)
+			fs, err := newFileSaver(dataFile, tt.gtidMode, tt.flavor)
 			if !assert.NoError(t, err) {
 				return
 			}
@@ -189,3 +230,44 @@ func TestFileSaver_SaveLoad(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestFileSaver_FlavorMismatch(t *testing.T) {
+	gtid, err := mysql.ParseMysqlGTIDSet("07812e7f-5dad-11e6-b5b3-525400d2e382:1-939564")
+	require.NoError(t, err)
+
+	dataDir := "/tmp/mymy-flavor-test"
+	dataFile := path.Join(dataDir, "master.info")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(dataDir))
+	}()
+
+	fs, err := newFileSaver(dataFile, true, mysql.MySQLFlavor)
+	require.NoError(t, err)
+	require.NoError(t, fs.save(newGTIDSet(mysql.MySQLFlavor, gtid), true))
+
+	fs, err = newFileSaver(dataFile, true, mysql.MariaDBFlavor)
+	require.NoError(t, err)
+
+	_, err = fs.load()
+	assert.Error(t, err)
+}
+
+func TestFileSaver_Reset(t *testing.T) {
+	dataDir := "/tmp/mymy-reset-test"
+	dataFile := path.Join(dataDir, "master.info")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(dataDir))
+	}()
+
+	fs, err := newFileSaver(dataFile, false, mysql.MySQLFlavor)
+	require.NoError(t, err)
+	require.NoError(t, fs.save(newBinlogPos(mysql.Position{Name: "mysql-bin.000001", Pos: 154}), true))
+	require.FileExists(t, dataFile)
+
+	require.NoError(t, fs.reset())
+	assert.NoFileExists(t, dataFile)
+	assert.Equal(t, newBinlogPos(mysql.Position{}), fs.position())
+
+	// Nothing to remove.
+	assert.NoError(t, fs.reset())
+}
@@ -0,0 +1,288 @@
+package bridge
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+// Replication phases.
+const (
+	PhaseDumping = "dumping"
+	PhaseSyncing = "syncing"
+	PhasePaused  = "paused"
+	PhaseStopped = "stopped"
+	PhaseStandby = "standby"
+	// PhaseCompleted is the replication stopped at the configured stop position.
+	PhaseCompleted = "completed"
+)
+
+// Status is a detailed state of the replication.
+type Status struct {
+	Phase string `json:"phase"`
+	// Role is the HA role of the replicator, empty if the HA is disabled.
+	Role string `json:"role,omitempty"`
+	// DryRun is true if the statements are not executed.
+	DryRun bool `json:"dry_run,omitempty"`
+	// PauseError is the error which paused the replication.
+	PauseError string   `json:"pause_error,omitempty"`
+	Position   Position `json:"position"`
+	// Stop is the configured stop position, nil if the replication does not stop.
+	Stop                *StopStatus `json:"stop,omitempty"`
+	SecondsBehindMaster uint32      `json:"seconds_behind_master"`
+	// Delay is the state of the delayed replication, nil if the events are not delayed.
+	Delay *DelayStatus `json:"delay,omitempty"`
+	// HeartbeatLag is the end-to-end replication lag in seconds, nil if the heartbeat is disabled.
+	HeartbeatLag *float64       `json:"heartbeat_lag,omitempty"`
+	Queue        QueueStatus    `json:"queue"`
+	Throttle     ThrottleStatus `json:"throttle"`
+	Upstream     UpstreamStatus `json:"upstream"`
+	// Sink is the state of the file sink, nil if the queries are executed on the upstream.
+	Sink  *FileSinkStatus `json:"sink,omitempty"`
+	Rules []RuleStatus    `json:"rules"`
+}
+
+// StopStatus is the progress of the replication up to the stop position.
+type StopStatus struct {
+	Target string `json:"target"`
+	// Completed is true if everything before the target is applied and the position is saved.
+	Completed bool `json:"completed"`
+}
+
+// UpstreamStatus is the upstream connection pool statistics.
+type UpstreamStatus struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// RuleStatus contains the counters of the rule since the start.
+type RuleStatus struct {
+	Table       string      `json:"table"`
+	Events      uint64      `json:"events"`
+	Rows        uint64      `json:"rows"`
+	Queries     uint64      `json:"queries"`
+	LastError   string      `json:"last_error,omitempty"`
+	LastErrorAt *time.Time  `json:"last_error_at,omitempty"`
+	Dump        *DumpStatus `json:"dump,omitempty"`
+}
+
+// DumpStatus is a progress of the initial dump of the table.
+type DumpStatus struct {
+	Rows uint64 `json:"rows"`
+	// EstimatedRows is the approximate number of the table rows from the table statistics.
+	EstimatedRows uint64 `json:"estimated_rows,omitempty"`
+}
+
+// ruleStats collects the counters of the rules.
+type ruleStats struct {
+	mu    *sync.Mutex
+	rules map[string]*RuleStatus
+	// estimated is true when the dump estimates are loaded.
+	estimated bool
+}
+
+func newRuleStats() *ruleStats {
+	return &ruleStats{
+		mu:    &sync.Mutex{},
+		rules: make(map[string]*RuleStatus),
+	}
+}
+
+// get returns the rule status, the caller must hold the lock.
+func (s *ruleStats) get(table string) *RuleStatus {
+	st, ok := s.rules[table]
+	if !ok {
+		st = &RuleStatus{Table: table}
+		s.rules[table] = st
+	}
+
+	return st
+}
+
+func (s *ruleStats) addEvent(table string, action mymy.Action, rows, queries int, dumping bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The update event contains two rows for every changed row.
+	if action == mymy.ActionUpdate {
+		rows /= 2
+	}
+
+	st := s.get(table)
+	st.Events++
+	st.Rows += uint64(rows)
+	st.Queries += uint64(queries)
+
+	if dumping {
+		if st.Dump == nil {
+			st.Dump = &DumpStatus{}
+		}
+		st.Dump.Rows += uint64(rows)
+	}
+}
+
+func (s *ruleStats) setError(table string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	st := s.get(table)
+	st.LastError = err.Error()
+	st.LastErrorAt = &now
+}
+
+func (s *ruleStats) setEstimates(rows map[string]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for table, n := range rows {
+		st := s.get(table)
+		if st.Dump == nil {
+			st.Dump = &DumpStatus{}
+		}
+		st.Dump.EstimatedRows = n
+	}
+	s.estimated = true
+}
+
+func (s *ruleStats) hasEstimates() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.estimated
+}
+
+func (s *ruleStats) list(tables []string) []RuleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]RuleStatus, 0, len(tables))
+	for _, table := range tables {
+		st := *s.get(table)
+		if st.Dump != nil {
+			dump := *st.Dump
+			st.Dump = &dump
+		}
+		list = append(list, st)
+	}
+
+	return list
+}
+
+// Status returns the detailed state of the replication.
+func (b *Bridge) Status() Status {
+	st := Status{
+		Phase:               b.phase(),
+		Role:                b.Role(),
+		DryRun:              b.dryRun != nil,
+		Position:            b.Position(),
+		SecondsBehindMaster: b.Delay(),
+		Queue:               b.queue.status(),
+		Throttle:            b.throttler.state(),
+		Delay:               b.delayer.status(),
+	}
+
+	if b.stop.enabled() {
+		st.Stop = &StopStatus{
+			Target:    b.stop.position().String(),
+			Completed: b.stop.completed(),
+		}
+	}
+
+	if lag, ok := b.HeartbeatLag(); ok {
+		sec := lag.Seconds()
+		st.HeartbeatLag = &sec
+	}
+
+	if paused, err := b.Paused(); paused && err != nil {
+		st.PauseError = err.Error()
+	}
+
+	// The replay does not dump the tables and has no source to estimate them.
+	if st.Phase == PhaseDumping && !b.replay && !b.stats.hasEstimates() {
+		b.loadDumpEstimates()
+	}
+
+	if b.upstream != nil {
+		dbStats := b.upstream.Stats()
+		st.Upstream = UpstreamStatus{
+			MaxOpenConnections: dbStats.MaxOpenConnections,
+			OpenConnections:    dbStats.OpenConnections,
+			InUse:              dbStats.InUse,
+			Idle:               dbStats.Idle,
+			WaitCount:          dbStats.WaitCount,
+			WaitDuration:       dbStats.WaitDuration.String(),
+			MaxIdleClosed:      dbStats.MaxIdleClosed,
+			MaxLifetimeClosed:  dbStats.MaxLifetimeClosed,
+		}
+	}
+	if fs, ok := b.sink.(*fileSink); ok {
+		st.Sink = fs.status()
+	}
+
+	b.rulesMu.RLock()
+	tables := make([]string, 0, len(b.rules))
+	for key := range b.rules {
+		tables = append(tables, key)
+	}
+	b.rulesMu.RUnlock()
+	sort.Strings(tables)
+
+	st.Rules = b.stats.list(tables)
+
+	return st
+}
+
+func (b *Bridge) phase() string {
+	switch {
+	case b.stop.completed():
+		return PhaseCompleted
+	case b.Role() == RoleStandby:
+		return PhaseStandby
+	case b.Dumping():
+		return PhaseDumping
+	case !b.Running():
+		return PhaseStopped
+	}
+
+	if paused, _ := b.Paused(); paused {
+		return PhasePaused
+	}
+
+	return PhaseSyncing
+}
+
+// loadDumpEstimates reads the approximate number of rows of the dumped tables.
+func (b *Bridge) loadDumpEstimates() {
+	res, err := b.currentCanal().Execute(
+		"SELECT TABLE_SCHEMA, TABLE_NAME, TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?",
+		b.snapshot.schema,
+	)
+	if err != nil {
+		b.logger.Err(err).Msg("failed to load table statistics")
+
+		return
+	}
+
+	rows := make(map[string]uint64)
+	for i := 0; i < res.RowNumber(); i++ {
+		schema, _ := res.GetString(i, 0)
+		table, _ := res.GetString(i, 1)
+		n, _ := res.GetUint(i, 2)
+
+		key := mymy.RuleKey(schema, table)
+		if _, ok := b.rule(key); ok {
+			rows[key] = n
+		}
+	}
+
+	b.stats.setEstimates(rows)
+}
@@ -0,0 +1,35 @@
+package bridge
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func TestRuleStats(t *testing.T) {
+	s := newRuleStats()
+
+	s.addEvent("city.users", mymy.ActionInsert, 3, 3, true)
+	s.addEvent("city.users", mymy.ActionUpdate, 4, 2, false)
+	s.setError("city.users", errors.New("duplicate key"))
+	s.setEstimates(map[string]uint64{"city.users": 100})
+
+	list := s.list([]string{"city.orders", "city.users"})
+	require.Len(t, list, 2)
+
+	assert.Equal(t, RuleStatus{Table: "city.orders"}, list[0])
+
+	users := list[1]
+	assert.Equal(t, "city.users", users.Table)
+	assert.EqualValues(t, 2, users.Events)
+	assert.EqualValues(t, 5, users.Rows)
+	assert.EqualValues(t, 5, users.Queries)
+	assert.Equal(t, "duplicate key", users.LastError)
+	assert.NotNil(t, users.LastErrorAt)
+	assert.Equal(t, &DumpStatus{Rows: 3, EstimatedRows: 100}, users.Dump)
+	assert.True(t, s.hasEstimates())
+}
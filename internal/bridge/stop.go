@@ -0,0 +1,129 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+var ErrStopInFuture = errors.New("stop time is in the future")
+
+// stopper completes the replication once everything before the target position is applied.
+type stopper struct {
+	mu *sync.RWMutex
+	// target is the position to stop at, nil if the replication does not stop.
+	target position
+	once   *sync.Once
+	doneCh chan struct{}
+}
+
+func newStopper(ctx context.Context, cfg *config.Config) (*stopper, error) {
+	s := &stopper{
+		mu:     &sync.RWMutex{},
+		once:   &sync.Once{},
+		doneCh: make(chan struct{}),
+	}
+
+	// The replay of the binlog files stops at the position itself.
+	stop := cfg.Replication.Stop
+	if !stop.Enabled() || cfg.Replication.Replay.Enabled() {
+		return s, nil
+	}
+
+	// The binlog after the time is not written yet, so the position can not be found.
+	if stop.Time != "" {
+		at, err := stop.Timestamp()
+		if err != nil {
+			return nil, err
+		}
+		if at.After(time.Now()) {
+			return nil, fmt.Errorf("%w: %s", ErrStopInFuture, stop.Time)
+		}
+	}
+
+	target, err := resolvePosition(ctx, cfg, stop)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve stop position: %w", err)
+	}
+	s.target = target
+
+	return s, nil
+}
+
+func (s *stopper) enabled() bool {
+	return s.position() != nil
+}
+
+func (s *stopper) position() position {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.target
+}
+
+func (s *stopper) setPosition(pos position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.target = pos
+}
+
+// reached returns true if the position is at or after the target.
+func (s *stopper) reached(pos position) bool {
+	switch target := s.position().(type) {
+	case *gtidSet:
+		p, ok := pos.(*gtidSet)
+
+		return ok && p.pos != nil && p.pos.Contain(target.pos)
+	case *binlogPos:
+		p, ok := pos.(*binlogPos)
+
+		return ok && p.pos.Name != "" && p.pos.Compare(target.pos) >= 0
+	}
+
+	return false
+}
+
+// complete marks the replication completed after the target position is saved.
+func (s *stopper) complete() {
+	s.once.Do(func() {
+		close(s.doneCh)
+	})
+}
+
+func (s *stopper) completed() bool {
+	select {
+	case <-s.doneCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *stopper) done() <-chan struct{} {
+	return s.doneCh
+}
+
+// savePosition saves the position and completes the replication if the stop position is reached.
+func (b *Bridge) savePosition(v *savePos) error {
+	reached := b.stop.reached(v.pos)
+	if err := b.stateSaver.save(v.pos, v.force || reached); err != nil {
+		return err
+	}
+
+	if reached {
+		b.logger.Info().Str("position", v.pos.String()).Msg("stop position is reached, replication is completed")
+		b.stop.complete()
+	}
+
+	return nil
+}
+
+// Completed returns true if the replication is stopped at the configured position.
+func (b *Bridge) Completed() bool {
+	return b.stop.completed()
+}
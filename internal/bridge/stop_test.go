@@ -0,0 +1,88 @@
+package bridge
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopper_Reached(t *testing.T) {
+	gtid := func(s string) position {
+		set, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, s)
+		require.NoError(t, err)
+
+		return newGTIDSet(mysql.MySQLFlavor, set)
+	}
+	filePos := func(name string, pos uint32) position {
+		return newBinlogPos(mysql.Position{Name: name, Pos: pos})
+	}
+
+	const uuid = "de278ad0-2106-11e4-9f8e-6edd0ca20947"
+
+	tests := []struct {
+		name   string
+		target position
+		pos    position
+		want   bool
+	}{
+		{
+			name: "Disabled",
+			pos:  filePos("mysql-bin.000001", 4),
+		},
+		{
+			name:   "GTIDBefore",
+			target: gtid(uuid + ":1-10"),
+			pos:    gtid(uuid + ":1-9"),
+		},
+		{
+			name:   "GTIDEqual",
+			target: gtid(uuid + ":1-10"),
+			pos:    gtid(uuid + ":1-10"),
+			want:   true,
+		},
+		{
+			name:   "GTIDAfter",
+			target: gtid(uuid + ":1-10"),
+			pos:    gtid(uuid + ":1-12"),
+			want:   true,
+		},
+		{
+			name:   "GTIDEmpty",
+			target: gtid(uuid + ":1-10"),
+			pos:    gtid(""),
+		},
+		{
+			name:   "FilePosBefore",
+			target: filePos("mysql-bin.000002", 120),
+			pos:    filePos("mysql-bin.000001", 1000),
+		},
+		{
+			name:   "FilePosEqual",
+			target: filePos("mysql-bin.000002", 120),
+			pos:    filePos("mysql-bin.000002", 120),
+			want:   true,
+		},
+		{
+			name:   "FilePosAfter",
+			target: filePos("mysql-bin.000002", 120),
+			pos:    filePos("mysql-bin.000003", 4),
+			want:   true,
+		},
+		{
+			name:   "FilePosEmpty",
+			target: filePos("mysql-bin.000002", 120),
+			pos:    filePos("", 0),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := &stopper{mu: &sync.RWMutex{}, target: tt.target, once: &sync.Once{}, doneCh: make(chan struct{})}
+			assert.Equal(t, tt.want, s.reached(tt.pos))
+		})
+	}
+}
@@ -1,36 +1,38 @@
 package bridge
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/siddontang/go-mysql/canal"
 	"github.com/siddontang/go-mysql/mysql"
 	"github.com/siddontang/go-mysql/replication"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/city-mobil/go-mymy/internal/metrics"
+	"github.com/city-mobil/go-mymy/internal/tracing"
 	"github.com/city-mobil/go-mymy/pkg/mymy"
 )
 
-var emptyGTID = mustCreateGTID(mysql.MySQLFlavor, "")
-
-func mustCreateGTID(flavor, s string) mysql.GTIDSet {
-	set, err := mysql.ParseGTIDSet(flavor, s)
-	if err != nil {
-		panic(err)
-	}
-
-	return set
-}
-
 type eventHandler struct {
 	bridge   *Bridge
 	gtidMode bool
+	flavor   string
+	// gtid is the GTID of the current transaction.
+	gtid string
+	// txnCtx contains the span of the current transaction if any.
+	txnCtx  context.Context
+	txnSpan trace.Span
 }
 
-func newEventHandler(b *Bridge, gtidMode bool) *eventHandler {
+func newEventHandler(b *Bridge, gtidMode bool, flavor string) *eventHandler {
 	return &eventHandler{
 		bridge:   b,
 		gtidMode: gtidMode,
+		flavor:   flavor,
 	}
 }
 
@@ -39,6 +41,9 @@ func (h *eventHandler) OnRotate(_ *replication.RotateEvent) error {
 }
 
 func (h *eventHandler) OnTableChanged(schema, table string) error {
+	h.bridge.handlersMu.RLock()
+	defer h.bridge.handlersMu.RUnlock()
+
 	rule, err := h.bridge.updateRule(schema, table)
 	ruleExist := !errors.Is(err, ErrRuleNotExist)
 	if ruleExist {
@@ -46,6 +51,8 @@ func (h *eventHandler) OnTableChanged(schema, table string) error {
 			return err
 		}
 
+		h.bridge.snapshot.markStale(mymy.RuleKey(schema, table))
+
 		err = rule.Handler.OnTableChanged(rule.Source)
 		if err != nil {
 			return err
@@ -56,50 +63,159 @@ func (h *eventHandler) OnTableChanged(schema, table string) error {
 }
 
 func (h *eventHandler) OnDDL(_ mysql.Position, _ *replication.QueryEvent) error {
+	h.endTxn()
+
 	return h.bridge.ctx.Err()
 }
 
 func (h *eventHandler) OnXID(_ mysql.Position) error {
+	h.endTxn()
+
 	return h.bridge.ctx.Err()
 }
 
+// beginTxn starts the span of the binlog transaction.
+// The transactions are sampled as a whole, so the events spans are its children.
+func (h *eventHandler) beginTxn() {
+	h.endTxn()
+	h.txnCtx, h.txnSpan = tracing.Start(context.Background(), "mymy.transaction", tracing.GTIDKey.String(h.gtid))
+}
+
+func (h *eventHandler) endTxn() {
+	if h.txnSpan != nil {
+		h.txnSpan.End()
+	}
+
+	h.txnCtx, h.txnSpan = nil, nil
+}
+
 func (h *eventHandler) OnRow(e *canal.RowsEvent) error {
-	rule, ok := h.bridge.rules[mymy.RuleKey(e.Table.Schema, e.Table.Name)]
+	if h.bridge.snapshot.isWatermark(e.Table) {
+		err := h.bridge.onWatermark(e)
+		if err != nil {
+			return err
+		}
+
+		return h.bridge.ctx.Err()
+	}
+
+	if h.bridge.heartbeat.isHeartbeat(e.Table) {
+		err := h.bridge.onHeartbeat(e)
+		if err != nil {
+			return err
+		}
+
+		return h.bridge.ctx.Err()
+	}
+
+	key := mymy.RuleKey(e.Table.Schema, e.Table.Name)
+	h.bridge.handlersMu.RLock()
+	rule, ok := h.bridge.rule(key)
 	if !ok {
+		h.bridge.handlersMu.RUnlock()
+
 		return nil
 	}
 
+	h.bridge.snapshot.observe(key, rule.Source.PKs, e.Rows)
+
+	// The dump rows are not grouped into transactions.
+	if h.txnCtx == nil && !h.bridge.Dumping() {
+		h.beginTxn()
+	}
+	ctx := h.txnCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	attrs := []attribute.KeyValue{
+		tracing.TableKey.String(key),
+		tracing.ActionKey.String(e.Action),
+		tracing.GTIDKey.String(h.gtid),
+	}
+	ctx, span := tracing.Start(ctx, "mymy.on_row", append(attrs, tracing.RowsKey.Int(len(e.Rows)))...)
+
+	_, handlerSpan := tracing.Start(ctx, "mymy.handler.on_rows", attrs...)
 	queries, err := rule.Handler.OnRows(&mymy.RowsEvent{
 		Action: mymy.Action(e.Action),
 		Source: rule.Source,
 		Rows:   e.Rows,
 	})
+	h.bridge.handlersMu.RUnlock()
+	tracing.End(handlerSpan, err)
+	dumping := h.bridge.Dumping()
+	h.bridge.stats.addEvent(key, mymy.Action(e.Action), len(e.Rows), len(queries), dumping)
+	metrics.AddRowsEvent(e.Table.Schema, e.Table.Name, e.Action)
+	if dumping {
+		metrics.AddDumpRows(e.Table.Schema, e.Table.Name, len(e.Rows))
+	}
 	if err != nil {
-		h.bridge.cancel()
+		err = fmt.Errorf("sync %s request, what: %w", e.Action, err)
+		h.bridge.stats.setError(key, err)
+		metrics.IncHandlerErrors(e.Table.Schema, e.Table.Name)
+		if !h.bridge.pauseOnError {
+			h.bridge.cancel()
+			tracing.End(span, err)
+
+			return err
+		}
+	} else {
+		metrics.AddQueries(e.Table.Schema, e.Table.Name, e.Action, len(queries))
+	}
 
-		return fmt.Errorf("sync %s request, what: %w", e.Action, err)
+	var at time.Time
+	if e.Header != nil {
+		at = time.Unix(int64(e.Header.Timestamp), 0)
 	}
 
-	h.bridge.syncCh <- batch(queries)
+	_, queued := tracing.Start(ctx, "mymy.queue", attrs...)
+	errPush := h.bridge.queue.push(h.bridge.ctx, &rowsEvent{
+		ctx:     ctx,
+		queued:  queued,
+		queries: queries,
+		rows:    len(e.Rows),
+		table:   key,
+		action:  e.Action,
+		gtid:    h.gtid,
+		at:      at,
+		err:     err,
+	})
+	tracing.End(span, err)
+	if errPush != nil {
+		return errPush
+	}
 
 	return h.bridge.ctx.Err()
 }
 
-func (h *eventHandler) OnGTID(_ mysql.GTIDSet) error {
+func (h *eventHandler) OnGTID(set mysql.GTIDSet) error {
+	if set != nil {
+		h.gtid = set.String()
+	}
+	h.beginTxn()
+
 	return h.bridge.ctx.Err()
 }
 
 func (h *eventHandler) OnPosSynced(pos mysql.Position, set mysql.GTIDSet, force bool) error {
+	var synced position
 	if h.gtidMode {
-		h.bridge.syncCh <- &savePos{
-			pos:   newGTIDSet(set),
-			force: force,
-		}
+		synced = newGTIDSet(h.flavor, set)
 	} else {
-		h.bridge.syncCh <- &savePos{
-			pos:   newBinlogPos(pos),
-			force: force,
-		}
+		synced = newBinlogPos(pos)
+	}
+
+	err := h.bridge.queue.push(h.bridge.ctx, &savePos{
+		pos:   synced,
+		force: force,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The canal stops reading the binlog without an error on the cancellation.
+	if h.bridge.stop.reached(synced) || h.bridge.stopForRestart(synced) {
+		return context.Canceled
 	}
 
 	return h.bridge.ctx.Err()
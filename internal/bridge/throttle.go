@@ -0,0 +1,396 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/multierr"
+	"golang.org/x/time/rate"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/internal/metrics"
+)
+
+// Reasons of the throttling.
+const (
+	ThrottleRate           = "rate"
+	ThrottleThreadsRunning = "threads_running"
+	ThrottleReplicaLag     = "replica_lag"
+	// ThrottleReplicaStopped is set while the replication of any replica is stopped, so its lag is unknown.
+	ThrottleReplicaStopped = "replica_stopped"
+)
+
+// ThrottleStatus is a state of the upstream writes throttling.
+type ThrottleStatus struct {
+	Throttled bool   `json:"throttled"`
+	Reason    string `json:"reason,omitempty"`
+	// ThreadsRunning is the last checked upstream Threads_running.
+	ThreadsRunning int `json:"threads_running,omitempty"`
+	// ReplicaLag is the last checked maximum lag of the upstream replicas in seconds.
+	ReplicaLag float64 `json:"replica_lag,omitempty"`
+	// Error is the error of the last load check. The throttling state is kept until the check succeeds.
+	Error string `json:"error,omitempty"`
+}
+
+type replica struct {
+	addr   string
+	client *client.SQLClient
+}
+
+// throttler limits the rate of the upstream writes and pauses them
+// while the upstream or its replicas are overloaded.
+type throttler struct {
+	ctx    context.Context
+	logger zerolog.Logger
+
+	rows  *rate.Limiter
+	stmts *rate.Limiter
+
+	maxThreadsRunning int
+	maxReplicaLag     time.Duration
+	interval          time.Duration
+	upstream          *client.SQLClient
+	replicas          []replica
+
+	mu     *sync.Mutex
+	status ThrottleStatus
+	// resumeCh is closed when the throttling stops, nil if the writes are not throttled.
+	resumeCh chan struct{}
+}
+
+func newThrottler(ctx context.Context, cfg *config.Config, upstream *client.SQLClient, logger zerolog.Logger) (*throttler, error) {
+	opts := cfg.Replication.Throttle
+
+	t := &throttler{
+		ctx:               ctx,
+		logger:            logger,
+		maxThreadsRunning: opts.MaxThreadsRunning,
+		maxReplicaLag:     opts.MaxReplicaLag,
+		interval:          opts.CheckInterval,
+		upstream:          upstream,
+		mu:                &sync.Mutex{},
+	}
+
+	if opts.MaxRowsPerSecond > 0 {
+		t.rows = rate.NewLimiter(rate.Limit(opts.MaxRowsPerSecond), opts.MaxRowsPerSecond)
+	}
+	if opts.MaxStatementsPerSecond > 0 {
+		t.stmts = rate.NewLimiter(rate.Limit(opts.MaxStatementsPerSecond), opts.MaxStatementsPerSecond)
+	}
+
+	if opts.MaxReplicaLag > 0 {
+		upstreamOpts := cfg.Replication.UpstreamOpts
+		tlsCfg, err := upstreamOpts.TLS.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, addr := range opts.Replicas {
+			c, err := client.New(&client.Config{
+				Addr:           addr,
+				User:           upstreamOpts.User,
+				Password:       string(upstreamOpts.Password),
+				Charset:        upstreamOpts.Charset,
+				MaxOpenConns:   1,
+				MaxIdleConns:   1,
+				ConnectTimeout: upstreamOpts.ConnectTimeout,
+				TLS:            tlsCfg,
+			})
+			if err != nil {
+				_ = t.close()
+
+				return nil, fmt.Errorf("could not connect to upstream replica %s: %w", addr, err)
+			}
+
+			t.replicas = append(t.replicas, replica{addr: addr, client: c})
+		}
+	}
+
+	return t, nil
+}
+
+// adaptive returns true if the writes are throttled on the upstream load.
+func (t *throttler) adaptive() bool {
+	return t.maxThreadsRunning > 0 || (t.maxReplicaLag > 0 && len(t.replicas) > 0)
+}
+
+// wait blocks until the rows can be written to the upstream by the statements.
+func (t *throttler) wait(rows, stmts int) error {
+	for {
+		resumeCh, reason := t.throttled()
+		if resumeCh == nil {
+			break
+		}
+
+		start := time.Now()
+		select {
+		case <-resumeCh:
+		case <-t.ctx.Done():
+			return t.ctx.Err()
+		}
+		metrics.AddThrottleWait(reason, time.Since(start))
+	}
+
+	start := time.Now()
+	if err := waitN(t.ctx, t.stmts, stmts); err != nil {
+		return err
+	}
+	if err := waitN(t.ctx, t.rows, rows); err != nil {
+		return err
+	}
+	if t.rows != nil || t.stmts != nil {
+		metrics.AddThrottleWait(ThrottleRate, time.Since(start))
+	}
+
+	return nil
+}
+
+// waitN waits for n tokens taking them by the limiter burst.
+func waitN(ctx context.Context, limiter *rate.Limiter, n int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for n > 0 {
+		take := n
+		if burst := limiter.Burst(); take > burst {
+			take = burst
+		}
+
+		if err := limiter.WaitN(ctx, take); err != nil {
+			return err
+		}
+		n -= take
+	}
+
+	return nil
+}
+
+func (t *throttler) throttled() (chan struct{}, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.resumeCh, t.status.Reason
+}
+
+// run checks the upstream load until the context is done.
+func (t *throttler) run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.check()
+		case <-t.ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *throttler) check() {
+	ctx, cancel := context.WithTimeout(t.ctx, t.interval)
+	defer cancel()
+
+	threads, lag, stopped, err := t.load(ctx)
+	if err != nil {
+		t.fail(err)
+
+		return
+	}
+
+	t.set(t.evaluate(threads, lag, stopped), threads, lag)
+}
+
+// load reads the upstream Threads_running and the maximum lag of the replicas.
+// It returns true if the replication of any replica is stopped.
+func (t *throttler) load(ctx context.Context) (threads int, lag time.Duration, stopped bool, err error) {
+	if t.maxThreadsRunning > 0 {
+		threads, err = readThreadsRunning(ctx, t.upstream)
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("could not check upstream threads running: %w", err)
+		}
+		metrics.SetUpstreamThreadsRunning(threads)
+	}
+
+	if t.maxReplicaLag > 0 {
+		for _, r := range t.replicas {
+			replicaLag, replicaStopped, errLag := readReplicaLag(ctx, r.client)
+			if errLag != nil {
+				return 0, 0, false, fmt.Errorf("could not check lag of upstream replica %s: %w", r.addr, errLag)
+			}
+			if replicaStopped {
+				stopped = true
+
+				continue
+			}
+
+			metrics.SetUpstreamReplicaLag(r.addr, replicaLag)
+			if replicaLag > lag {
+				lag = replicaLag
+			}
+		}
+	}
+
+	return threads, lag, stopped, nil
+}
+
+// evaluate returns the reason to throttle the writes or an empty string.
+// The stopped replica is considered lagging.
+func (t *throttler) evaluate(threads int, lag time.Duration, stopped bool) string {
+	switch {
+	case t.maxThreadsRunning > 0 && threads > t.maxThreadsRunning:
+		return ThrottleThreadsRunning
+	case t.maxReplicaLag > 0 && stopped:
+		return ThrottleReplicaStopped
+	case t.maxReplicaLag > 0 && lag > t.maxReplicaLag:
+		return ThrottleReplicaLag
+	default:
+		return ""
+	}
+}
+
+func (t *throttler) set(reason string, threads int, lag time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.ThreadsRunning = threads
+	t.status.ReplicaLag = lag.Seconds()
+	t.status.Error = ""
+
+	throttled := reason != ""
+	if throttled == t.status.Throttled && reason == t.status.Reason {
+		return
+	}
+
+	if throttled {
+		t.logger.Warn().
+			Str("reason", reason).
+			Int("threads_running", threads).
+			Dur("replica_lag", lag).
+			Msg("upstream writes throttled")
+
+		if t.resumeCh == nil {
+			t.resumeCh = make(chan struct{})
+		}
+	} else {
+		t.logger.Info().Msg("upstream writes resumed")
+
+		close(t.resumeCh)
+		t.resumeCh = nil
+	}
+
+	t.status.Throttled = throttled
+	t.status.Reason = reason
+	metrics.SetThrottled(throttled)
+}
+
+// fail reports the error of the load check. The writes stay throttled or not
+// as before, since the load is unknown.
+func (t *throttler) fail(err error) {
+	t.logger.Warn().Err(err).Msg("upstream load check failed")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.Error = err.Error()
+}
+
+func (t *throttler) state() ThrottleStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.status
+}
+
+// setCredentials reopens the connections to the replicas, which use the upstream credentials.
+func (t *throttler) setCredentials(user, password string) error {
+	for _, r := range t.replicas {
+		if err := r.client.SetCredentials(user, password); err != nil {
+			return fmt.Errorf("upstream replica %s: %w", r.addr, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *throttler) close() error {
+	var err error
+	for _, r := range t.replicas {
+		err = multierr.Append(err, r.client.Close())
+	}
+
+	return err
+}
+
+func readThreadsRunning(ctx context.Context, c *client.SQLClient) (int, error) {
+	var name, value string
+	err := c.QueryRow(ctx, "SHOW GLOBAL STATUS LIKE 'Threads_running'").Scan(&name, &value)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(value)
+}
+
+// readReplicaLag returns Seconds_Behind_Master of the replica.
+// It returns true if the replication is stopped.
+func readReplicaLag(ctx context.Context, c *client.SQLClient) (time.Duration, bool, error) {
+	rows, err := c.Query(ctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, false, err
+	}
+
+	// The server is not a replica.
+	if !rows.Next() {
+		return 0, false, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, false, err
+	}
+
+	return replicaLag(cols, values)
+}
+
+// replicaLag reads Seconds_Behind_Master of the replica status row.
+// The value is NULL if the replication is stopped.
+func replicaLag(cols []string, values []sql.NullString) (time.Duration, bool, error) {
+	for i, col := range cols {
+		if col != "Seconds_Behind_Master" {
+			continue
+		}
+
+		if !values[i].Valid {
+			return 0, true, nil
+		}
+
+		sec, err := strconv.Atoi(values[i].String)
+		if err != nil {
+			return 0, false, err
+		}
+
+		return time.Duration(sec) * time.Second, false, nil
+	}
+
+	return 0, false, nil
+}
@@ -0,0 +1,194 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+func newTestThrottler(t *testing.T, ctx context.Context, opts config.ThrottleConfig) *throttler {
+	var cfg config.Config
+	cfg.Replication.Throttle = opts
+
+	th, err := newThrottler(ctx, &cfg, nil, zerolog.Nop())
+	require.NoError(t, err)
+
+	return th
+}
+
+func TestThrottler_Evaluate(t *testing.T) {
+	th := newTestThrottler(t, context.Background(), config.ThrottleConfig{
+		MaxThreadsRunning: 20,
+		MaxReplicaLag:     5 * time.Second,
+	})
+
+	tests := []struct {
+		name    string
+		threads int
+		lag     time.Duration
+		stopped bool
+		want    string
+	}{
+		{
+			name:    "Idle",
+			threads: 3,
+			want:    "",
+		},
+		{
+			name:    "Limits",
+			threads: 20,
+			lag:     5 * time.Second,
+			want:    "",
+		},
+		{
+			name:    "ThreadsRunning",
+			threads: 21,
+			lag:     10 * time.Second,
+			want:    ThrottleThreadsRunning,
+		},
+		{
+			name:    "ReplicaLag",
+			threads: 1,
+			lag:     6 * time.Second,
+			want:    ThrottleReplicaLag,
+		},
+		{
+			name:    "ReplicaStopped",
+			threads: 1,
+			stopped: true,
+			want:    ThrottleReplicaStopped,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, th.evaluate(tt.threads, tt.lag, tt.stopped))
+		})
+	}
+}
+
+func TestThrottler_Load(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	th := newTestThrottler(t, ctx, config.ThrottleConfig{
+		MaxThreadsRunning: 20,
+	})
+	assert.True(t, th.adaptive())
+	require.NoError(t, th.wait(1, 1))
+
+	th.set(ThrottleThreadsRunning, 30, 0)
+	assert.Equal(t, ThrottleStatus{Throttled: true, Reason: ThrottleThreadsRunning, ThreadsRunning: 30}, th.state())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- th.wait(1, 1)
+	}()
+
+	select {
+	case <-done:
+		require.FailNow(t, "writes are not throttled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	th.set("", 5, 0)
+	require.NoError(t, <-done)
+	assert.Equal(t, ThrottleStatus{ThreadsRunning: 5}, th.state())
+
+	th.set(ThrottleThreadsRunning, 30, 0)
+	go func() {
+		done <- th.wait(1, 1)
+	}()
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+func TestThrottler_CheckFailed(t *testing.T) {
+	th := newTestThrottler(t, context.Background(), config.ThrottleConfig{
+		MaxThreadsRunning: 20,
+	})
+
+	th.set(ThrottleThreadsRunning, 30, 0)
+	th.fail(errors.New("connection refused"))
+
+	// The writes stay throttled until the load is known.
+	resumeCh, reason := th.throttled()
+	assert.NotNil(t, resumeCh)
+	assert.Equal(t, ThrottleThreadsRunning, reason)
+	assert.Equal(t, ThrottleStatus{
+		Throttled:      true,
+		Reason:         ThrottleThreadsRunning,
+		ThreadsRunning: 30,
+		Error:          "connection refused",
+	}, th.state())
+
+	th.set("", 5, 0)
+	assert.Equal(t, ThrottleStatus{ThreadsRunning: 5}, th.state())
+}
+
+func TestReplicaLag(t *testing.T) {
+	cols := []string{"Slave_IO_State", "Seconds_Behind_Master"}
+
+	tests := []struct {
+		name    string
+		value   sql.NullString
+		want    time.Duration
+		stopped bool
+		wantErr bool
+	}{
+		{
+			name:  "Running",
+			value: sql.NullString{String: "7", Valid: true},
+			want:  7 * time.Second,
+		},
+		{
+			name:    "Stopped",
+			stopped: true,
+		},
+		{
+			name:    "Invalid",
+			value:   sql.NullString{String: "x", Valid: true},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			lag, stopped, err := replicaLag(cols, []sql.NullString{{}, tt.value})
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, lag)
+			assert.Equal(t, tt.stopped, stopped)
+		})
+	}
+}
+
+func TestThrottler_Rate(t *testing.T) {
+	th := newTestThrottler(t, context.Background(), config.ThrottleConfig{
+		MaxRowsPerSecond:       100,
+		MaxStatementsPerSecond: 1000,
+	})
+	assert.False(t, th.adaptive())
+
+	start := time.Now()
+	// The burst is spent at once, the rest takes a half of second.
+	require.NoError(t, th.wait(150, 1))
+
+	elapsed := time.Since(start)
+	assert.True(t, elapsed >= 400*time.Millisecond, elapsed)
+	assert.True(t, elapsed < 1*time.Second, elapsed)
+}
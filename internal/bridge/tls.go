@@ -0,0 +1,69 @@
+package bridge
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+// sourceTLSConfig returns the TLS config of the canal connections or nil if the TLS is disabled.
+func sourceTLSConfig(opts config.SourceConfig) (*tls.Config, error) {
+	tlsCfg, err := opts.TLS.Load()
+	if err != nil || tlsCfg == nil {
+		return nil, err
+	}
+
+	// Unlike the SQL driver, canal does not take the server name from the address.
+	if tlsCfg.ServerName == "" && !tlsCfg.InsecureSkipVerify {
+		host, _, errSplit := net.SplitHostPort(opts.Addr)
+		if errSplit != nil {
+			host = opts.Addr
+		}
+		tlsCfg.ServerName = host
+	}
+
+	return tlsCfg, nil
+}
+
+// dumpTLSOptions returns the mysqldump options to connect to the source using TLS.
+// The server certificate is verified by mysqldump only against the configured CA
+// and the host name of the source address.
+func dumpTLSOptions(opts config.TLS, flavor string) []string {
+	if !opts.Enabled {
+		return nil
+	}
+
+	var args []string
+	if opts.CA != "" {
+		args = append(args, "--ssl-ca="+opts.CA)
+	}
+	if opts.Cert != "" {
+		args = append(args, "--ssl-cert="+opts.Cert)
+	}
+	if opts.Key != "" {
+		args = append(args, "--ssl-key="+opts.Key)
+	}
+
+	verify := opts.CA != "" && !opts.SkipVerify
+	if flavor == config.FlavorMariaDB {
+		args = append(args, "--ssl")
+		if verify && opts.ServerName == "" {
+			args = append(args, "--ssl-verify-server-cert")
+		}
+
+		return args
+	}
+
+	switch {
+	case !verify:
+		args = append(args, "--ssl-mode=REQUIRED")
+	case opts.ServerName != "":
+		// mysqldump can not verify the overridden server name.
+		args = append(args, "--ssl-mode=VERIFY_CA")
+	default:
+		args = append(args, "--ssl-mode=VERIFY_IDENTITY")
+	}
+
+	return args
+}
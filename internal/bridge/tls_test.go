@@ -0,0 +1,98 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+func TestDumpTLSOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   config.TLS
+		flavor string
+		want   []string
+	}{
+		{
+			name:   "Disabled",
+			opts:   config.TLS{CA: "/etc/mymy/ca.pem"},
+			flavor: config.FlavorMySQL,
+			want:   nil,
+		},
+		{
+			name: "VerifyIdentity",
+			opts: config.TLS{
+				Enabled: true,
+				CA:      "/etc/mymy/ca.pem",
+				Cert:    "/etc/mymy/cert.pem",
+				Key:     "/etc/mymy/key.pem",
+			},
+			flavor: config.FlavorMySQL,
+			want: []string{
+				"--ssl-ca=/etc/mymy/ca.pem",
+				"--ssl-cert=/etc/mymy/cert.pem",
+				"--ssl-key=/etc/mymy/key.pem",
+				"--ssl-mode=VERIFY_IDENTITY",
+			},
+		},
+		{
+			name:   "VerifyCA",
+			opts:   config.TLS{Enabled: true, CA: "/etc/mymy/ca.pem", ServerName: "db.local"},
+			flavor: config.FlavorMySQL,
+			want:   []string{"--ssl-ca=/etc/mymy/ca.pem", "--ssl-mode=VERIFY_CA"},
+		},
+		{
+			name:   "SkipVerify",
+			opts:   config.TLS{Enabled: true, CA: "/etc/mymy/ca.pem", SkipVerify: true},
+			flavor: config.FlavorMySQL,
+			want:   []string{"--ssl-ca=/etc/mymy/ca.pem", "--ssl-mode=REQUIRED"},
+		},
+		{
+			name:   "NoCA",
+			opts:   config.TLS{Enabled: true},
+			flavor: config.FlavorMySQL,
+			want:   []string{"--ssl-mode=REQUIRED"},
+		},
+		{
+			name:   "MariaDB",
+			opts:   config.TLS{Enabled: true, CA: "/etc/mymy/ca.pem"},
+			flavor: config.FlavorMariaDB,
+			want:   []string{"--ssl-ca=/etc/mymy/ca.pem", "--ssl", "--ssl-verify-server-cert"},
+		},
+		{
+			name:   "MariaDBSkipVerify",
+			opts:   config.TLS{Enabled: true, CA: "/etc/mymy/ca.pem", SkipVerify: true},
+			flavor: config.FlavorMariaDB,
+			want:   []string{"--ssl-ca=/etc/mymy/ca.pem", "--ssl"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dumpTLSOptions(tt.opts, tt.flavor))
+		})
+	}
+}
+
+func TestSourceTLSConfig(t *testing.T) {
+	opts := config.SourceConfig{Addr: "db.local:3306"}
+
+	tlsCfg, err := sourceTLSConfig(opts)
+	require.NoError(t, err)
+	assert.Nil(t, tlsCfg)
+
+	opts.TLS.Enabled = true
+	tlsCfg, err = sourceTLSConfig(opts)
+	require.NoError(t, err)
+	require.NotNil(t, tlsCfg)
+	assert.Equal(t, "db.local", tlsCfg.ServerName)
+
+	opts.TLS.ServerName = "mysql.local"
+	tlsCfg, err = sourceTLSConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, "mysql.local", tlsCfg.ServerName)
+}
@@ -0,0 +1,699 @@
+package bridge
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+	myclient "github.com/siddontang/go-mysql/client"
+	"github.com/siddontang/go-mysql/schema"
+	"go.uber.org/multierr"
+
+	"github.com/city-mobil/go-mymy/internal/client"
+	"github.com/city-mobil/go-mymy/internal/config"
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+const (
+	maxReportedDiffs = 100
+	nullValue        = "\x00NULL"
+)
+
+type DiffKind string
+
+const (
+	DiffMissing   DiffKind = "missing"
+	DiffExtra     DiffKind = "extra"
+	DiffDiffering DiffKind = "differing"
+)
+
+// RowDiff describes the upstream row which does not match the expected one.
+type RowDiff struct {
+	Kind    DiffKind               `json:"kind"`
+	Table   string                 `json:"table"`
+	Key     map[string]interface{} `json:"key"`
+	Columns []string               `json:"columns,omitempty"`
+}
+
+// VerifyReport is a result of the consistency check of the rule's source table.
+type VerifyReport struct {
+	Table      string `json:"table"`
+	SourceRows uint64 `json:"source_rows"`
+	Checked    uint64 `json:"checked"`
+	Missing    uint64 `json:"missing"`
+	Extra      uint64 `json:"extra"`
+	Differing  uint64 `json:"differing"`
+	Repaired   uint64 `json:"repaired"`
+	// Unchecked lists the upstream tables which are not searched for the extra rows
+	// because their primary keys differ from the source table one.
+	Unchecked []string `json:"extra_unchecked,omitempty"`
+	// Diffs contains the first found mismatches.
+	Diffs []RowDiff `json:"diffs,omitempty"`
+}
+
+// Consistent returns true if there are no mismatches left in the upstream.
+func (r *VerifyReport) Consistent() bool {
+	return r.Missing+r.Extra+r.Differing == r.Repaired
+}
+
+func (r *VerifyReport) addDiff(diff RowDiff) {
+	switch diff.Kind {
+	case DiffMissing:
+		r.Missing++
+	case DiffExtra:
+		r.Extra++
+	case DiffDiffering:
+		r.Differing++
+	}
+
+	if len(r.Diffs) < maxReportedDiffs {
+		r.Diffs = append(r.Diffs, diff)
+	}
+}
+
+// upstreamTable is the upstream table produced by the rule.
+type upstreamTable struct {
+	name string
+	pks  []string
+	// ref and pkList are the quoted table name and primary key columns to put into the queries.
+	ref    string
+	pkList string
+	// rangeCheck is true if the upstream primary key matches the source one,
+	// so the extra rows can be found by the source primary key range.
+	rangeCheck bool
+}
+
+// VerifyOptions tunes the consistency check.
+type VerifyOptions struct {
+	// Repair fixes the found mismatches.
+	Repair bool
+	// UpstreamTables are searched for the extra rows even if the rule produces
+	// no rows for them, e.g. when the source table is empty.
+	UpstreamTables []string
+}
+
+type verifyRun struct {
+	info    mymy.SourceInfo
+	handler mymy.EventHandler
+	repair  bool
+	report  *VerifyReport
+	tables  map[string]*upstreamTable
+}
+
+// ErrVerifyFileSink is returned by the verify command if the queries are written to the files.
+var ErrVerifyFileSink = errors.New("file sink has no upstream tables to verify")
+
+// Verifier checks that the upstream tables contain exactly the rows
+// the rules produce from the source tables.
+type Verifier struct {
+	source     *myclient.Conn
+	upstream   *client.SQLClient
+	database   string
+	upstreamDB string
+	chunkSize  int
+	handlers   map[string]mymy.EventHandler
+	logger     zerolog.Logger
+}
+
+func NewVerifier(cfg *config.Config, ehFactory EventHandlerFactory, logger zerolog.Logger) (*Verifier, error) {
+	if cfg.Replication.Sink.Type == config.SinkFile {
+		return nil, ErrVerifyFileSink
+	}
+
+	opts := cfg.Replication.SourceOpts
+
+	handlers := make(map[string]mymy.EventHandler, len(cfg.Replication.Rules))
+	for _, rule := range cfg.Replication.Rules {
+		pluginCfg := rule.Upstream.Plugin
+		h, err := ehFactory.New(pluginCfg.Name, pluginCfg.Config)
+		if err != nil {
+			return nil, fmt.Errorf("create handler error: name: %s, err: %w", pluginCfg.Name, err)
+		}
+		handlers[rule.Source.Table] = h
+	}
+
+	tlsCfg, err := sourceTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var connOpts []func(*myclient.Conn)
+	if tlsCfg != nil {
+		connOpts = append(connOpts, func(c *myclient.Conn) {
+			c.SetTLSConfig(tlsCfg)
+		})
+	}
+
+	source, err := myclient.Connect(opts.Addr, opts.User, string(opts.Password), opts.Database, connOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Charset != "" {
+		if err = source.SetCharset(opts.Charset); err != nil {
+			_ = source.Close()
+
+			return nil, err
+		}
+	}
+
+	upstream, err := newUpstreamClient(cfg)
+	if err != nil {
+		_ = source.Close()
+
+		return nil, err
+	}
+
+	return &Verifier{
+		source:     source,
+		upstream:   upstream,
+		database:   opts.Database,
+		upstreamDB: cfg.Replication.UpstreamOpts.Database,
+		chunkSize:  opts.Snapshot.ChunkSize,
+		handlers:   handlers,
+		logger:     logger,
+	}, nil
+}
+
+// Verify walks the source table in primary key chunks, passes every chunk
+// to the rule's handler as inserts and compares the produced rows with
+// the upstream ones.
+func (v *Verifier) Verify(ctx context.Context, table string, opts VerifyOptions) (*VerifyReport, error) {
+	handler, ok := v.handlers[table]
+	if !ok {
+		return nil, ErrRuleNotExist
+	}
+
+	tableInfo, err := schema.NewTable(v.source, v.database, table)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := newSourceInfo(tableInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &verifyRun{
+		info:    info,
+		handler: handler,
+		repair:  opts.Repair,
+		report:  &VerifyReport{Table: table},
+		tables:  make(map[string]*upstreamTable),
+	}
+
+	for _, name := range opts.UpstreamTables {
+		if err = v.addTable(ctx, run, name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	var after []interface{}
+	for {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var rows [][]interface{}
+		rows, err = readChunk(v.source, info, after, v.chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		run.report.SourceRows += uint64(len(rows))
+
+		// The last chunk has no upper bound to find the extra rows
+		// after the last source row.
+		var upper []interface{}
+		full := len(rows) == v.chunkSize
+		if full {
+			upper, err = pkValues(info.PKs, rows[len(rows)-1])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = v.verifyChunk(ctx, run, rows, after, upper)
+		if err != nil {
+			return nil, err
+		}
+
+		v.logger.Debug().
+			Str("table", table).
+			Uint64("rows", run.report.SourceRows).
+			Msg("verified chunk")
+
+		if !full {
+			return run.report, nil
+		}
+		after = upper
+	}
+}
+
+func (v *Verifier) verifyChunk(ctx context.Context, run *verifyRun, rows [][]interface{}, after, upper []interface{}) error {
+	expected := make(map[string][]*mymy.Query)
+	if len(rows) > 0 {
+		queries, err := run.handler.OnRows(&mymy.RowsEvent{
+			Action: mymy.ActionInsert,
+			Source: run.info,
+			Rows:   rows,
+		})
+		if err != nil {
+			return fmt.Errorf("verify request, what: %w", err)
+		}
+
+		for _, query := range queries {
+			if query.Action != mymy.ActionInsert && query.Action != mymy.ActionUpsert {
+				continue
+			}
+
+			if _, ok := run.tables[query.Table]; !ok {
+				if err = v.addTable(ctx, run, query.Table, after); err != nil {
+					return err
+				}
+			}
+
+			expected[query.Table] = append(expected[query.Table], query)
+		}
+	}
+
+	names := make([]string, 0, len(run.tables))
+	for name := range run.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := v.verifyTable(ctx, run, run.tables[name], expected[name], after, upper)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addTable starts to verify the upstream table. The table found after
+// the first chunk has no expected rows before it, so all its rows
+// up to the chunk start are extra.
+func (v *Verifier) addTable(ctx context.Context, run *verifyRun, name string, after []interface{}) error {
+	if _, ok := run.tables[name]; ok {
+		return nil
+	}
+
+	t, err := v.describe(ctx, name, run.info.PKs)
+	if err != nil {
+		return err
+	}
+
+	run.tables[name] = t
+	if !t.rangeCheck {
+		run.report.Unchecked = append(run.report.Unchecked, t.name)
+
+		return nil
+	}
+
+	if len(after) == 0 {
+		return nil
+	}
+
+	return v.verifyExtra(ctx, run, t, nil, nil, after)
+}
+
+func (v *Verifier) verifyTable(ctx context.Context, run *verifyRun, t *upstreamTable, queries []*mymy.Query, after, upper []interface{}) error {
+	keys := make([]string, 0, len(queries))
+	keyValues := make([][]interface{}, 0, len(queries))
+	want := make(map[string]*mymy.Query, len(queries))
+	for _, query := range queries {
+		values, err := queryKey(t.pks, query)
+		if err != nil {
+			return err
+		}
+
+		key := formatKey(values)
+		if _, ok := want[key]; !ok {
+			keys = append(keys, key)
+			keyValues = append(keyValues, values)
+		}
+		want[key] = query
+	}
+
+	got, err := v.fetchRows(ctx, t, keyValues)
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		query := want[key]
+		diff := RowDiff{
+			Table: t.name,
+			Key:   keyMap(t.pks, keyValues[i]),
+		}
+
+		row, ok := got[key]
+		if !ok {
+			diff.Kind = DiffMissing
+		} else if cols := diffColumns(query, row); len(cols) > 0 {
+			diff.Kind = DiffDiffering
+			diff.Columns = cols
+		} else {
+			continue
+		}
+
+		run.report.addDiff(diff)
+		if run.repair {
+			if err = v.upsert(ctx, query); err != nil {
+				return err
+			}
+			run.report.Repaired++
+		}
+	}
+	run.report.Checked += uint64(len(keys))
+
+	if !t.rangeCheck {
+		return nil
+	}
+
+	return v.verifyExtra(ctx, run, t, want, after, upper)
+}
+
+// verifyExtra finds the upstream rows in the source primary key range (after, upper]
+// which are not expected.
+func (v *Verifier) verifyExtra(ctx context.Context, run *verifyRun, t *upstreamTable, want map[string]*mymy.Query, after, upper []interface{}) error {
+	extra, err := v.fetchKeys(ctx, t, after, upper)
+	if err != nil {
+		return err
+	}
+
+	for _, values := range extra {
+		if _, ok := want[formatKey(values)]; ok {
+			continue
+		}
+
+		run.report.addDiff(RowDiff{
+			Kind:  DiffExtra,
+			Table: t.name,
+			Key:   keyMap(t.pks, values),
+		})
+
+		if run.repair {
+			if err = v.delete(ctx, t, values); err != nil {
+				return err
+			}
+			run.report.Repaired++
+		}
+	}
+
+	return nil
+}
+
+// describe fetches the primary key of the upstream table.
+func (v *Verifier) describe(ctx context.Context, table string, sourcePKs []mymy.Column) (*upstreamTable, error) {
+	db, name, ref := v.upstreamDB, table, quoteName(table)
+	if idx := strings.IndexByte(table, '.'); idx >= 0 {
+		db, name = table[:idx], table[idx+1:]
+		ref = quoteName(db) + "." + quoteName(name)
+	}
+
+	rows, err := v.upstream.Query(ctx,
+		"SELECT COLUMN_NAME FROM information_schema.KEY_COLUMN_USAGE "+
+			"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY' ORDER BY ORDINAL_POSITION",
+		db, name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var pks []string
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		pks = append(pks, col)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(pks) == 0 {
+		return nil, fmt.Errorf("no primary keys found, upstream table: %s", table)
+	}
+
+	rangeCheck := len(pks) == len(sourcePKs)
+	for i := 0; rangeCheck && i < len(pks); i++ {
+		rangeCheck = strings.EqualFold(pks[i], sourcePKs[i].Name)
+	}
+
+	quoted := make([]string, 0, len(pks))
+	for _, pk := range pks {
+		quoted = append(quoted, quoteName(pk))
+	}
+
+	return &upstreamTable{
+		name:       table,
+		pks:        pks,
+		ref:        ref,
+		pkList:     strings.Join(quoted, ","),
+		rangeCheck: rangeCheck,
+	}, nil
+}
+
+func (v *Verifier) fetchRows(ctx context.Context, t *upstreamTable, keys [][]interface{}) (map[string]map[string]interface{}, error) {
+	got := make(map[string]map[string]interface{}, len(keys))
+	if len(keys) == 0 {
+		return got, nil
+	}
+
+	tuple := "(?" + strings.Repeat(",?", len(t.pks)-1) + ")"
+	query := fmt.Sprintf("SELECT * FROM %s WHERE (%s) IN (%s%s)",
+		t.ref, t.pkList, tuple, strings.Repeat(","+tuple, len(keys)-1))
+
+	args := make([]interface{}, 0, len(keys)*len(t.pks))
+	for _, key := range keys {
+		args = append(args, key...)
+	}
+
+	rows, err := v.upstream.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range list {
+		values := make([]interface{}, 0, len(t.pks))
+		for _, pk := range t.pks {
+			values = append(values, row[strings.ToLower(pk)])
+		}
+		got[formatKey(values)] = row
+	}
+
+	return got, nil
+}
+
+// fetchKeys fetches the upstream primary keys in the source primary key range (after, upper].
+func (v *Verifier) fetchKeys(ctx context.Context, t *upstreamTable, after, upper []interface{}) ([][]interface{}, error) {
+	tuple := "(?" + strings.Repeat(",?", len(t.pks)-1) + ")"
+
+	conds := make([]string, 0, 2)
+	args := make([]interface{}, 0, len(after)+len(upper))
+	if len(after) > 0 {
+		conds = append(conds, fmt.Sprintf("(%s) > %s", t.pkList, tuple))
+		args = append(args, after...)
+	}
+	if len(upper) > 0 {
+		conds = append(conds, fmt.Sprintf("(%s) <= %s", t.pkList, tuple))
+		args = append(args, upper...)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s", t.pkList, t.ref)
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY " + t.pkList
+
+	rows, err := v.upstream.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([][]interface{}, 0, len(list))
+	for _, row := range list {
+		values := make([]interface{}, 0, len(t.pks))
+		for _, pk := range t.pks {
+			values = append(values, row[strings.ToLower(pk)])
+		}
+		keys = append(keys, values)
+	}
+
+	return keys, nil
+}
+
+func (v *Verifier) upsert(ctx context.Context, query *mymy.Query) error {
+	upsert := *query
+	upsert.Action = mymy.ActionUpsert
+
+	q, args, err := upsert.SQL()
+	if err != nil {
+		return err
+	}
+
+	_, err = v.upstream.Exec(ctx, q, args...)
+
+	return err
+}
+
+func (v *Verifier) delete(ctx context.Context, t *upstreamTable, key []interface{}) error {
+	where := make([]mymy.QueryArg, 0, len(t.pks))
+	for i, pk := range t.pks {
+		where = append(where, mymy.QueryArg{Field: pk, Value: key[i]})
+	}
+
+	query := &mymy.Query{
+		Action: mymy.ActionDelete,
+		Table:  t.name,
+		Where:  where,
+	}
+
+	q, args, err := query.SQL()
+	if err != nil {
+		return err
+	}
+
+	_, err = v.upstream.Exec(ctx, q, args...)
+
+	return err
+}
+
+func (v *Verifier) Close() error {
+	return multierr.Combine(
+		v.source.Close(),
+		v.upstream.Close(),
+	)
+}
+
+// scanRows reads all rows as maps of the lower-cased column names to string values.
+func scanRows(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []map[string]interface{}
+	for rows.Next() {
+		raw := make([]sql.RawBytes, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			if raw[i] == nil {
+				row[strings.ToLower(col)] = nil
+			} else {
+				row[strings.ToLower(col)] = string(raw[i])
+			}
+		}
+		list = append(list, row)
+	}
+
+	return list, rows.Err()
+}
+
+func queryKey(pks []string, query *mymy.Query) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(pks))
+	for _, pk := range pks {
+		found := false
+		for _, arg := range query.Values {
+			if strings.EqualFold(arg.Field, pk) {
+				values = append(values, arg.Value)
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("query to %s has no primary key field %s", query.Table, pk)
+		}
+	}
+
+	return values, nil
+}
+
+func keyMap(pks []string, values []interface{}) map[string]interface{} {
+	key := make(map[string]interface{}, len(pks))
+	for i, pk := range pks {
+		key[pk] = values[i]
+	}
+
+	return key
+}
+
+func formatKey(values []interface{}) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, formatValue(v))
+	}
+
+	return strings.Join(parts, pkKeySeparator)
+}
+
+// formatValue converts the value to the form returned by the MySQL text protocol.
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return nullValue
+	case []byte:
+		return string(val)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		if val {
+			return "1"
+		}
+
+		return "0"
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// diffColumns returns the query fields which values differ from the upstream row.
+func diffColumns(query *mymy.Query, row map[string]interface{}) []string {
+	var cols []string
+	for _, arg := range query.Values {
+		if formatValue(arg.Value) != formatValue(row[strings.ToLower(arg.Field)]) {
+			cols = append(cols, arg.Field)
+		}
+	}
+
+	return cols
+}
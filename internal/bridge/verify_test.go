@@ -0,0 +1,91 @@
+package bridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "Null", value: nil, want: nullValue},
+		{name: "Signed", value: int64(-10), want: "-10"},
+		{name: "Unsigned", value: uint32(10), want: "10"},
+		{name: "Float", value: 1.5, want: "1.5"},
+		{name: "Bytes", value: []byte("bob"), want: "bob"},
+		{name: "String", value: "bob", want: "bob"},
+		{name: "Bool", value: true, want: "1"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatValue(tt.value))
+		})
+	}
+}
+
+func TestDiffColumns(t *testing.T) {
+	query := &mymy.Query{
+		Action: mymy.ActionInsert,
+		Table:  "clients",
+		Values: []mymy.QueryArg{
+			{Field: "id", Value: uint64(1)},
+			{Field: "name", Value: "bob"},
+			{Field: "email", Value: nil},
+		},
+	}
+
+	tests := []struct {
+		name string
+		row  map[string]interface{}
+		want []string
+	}{
+		{
+			name: "Equal",
+			row:  map[string]interface{}{"id": "1", "name": "bob", "email": nil},
+		},
+		{
+			name: "Differ",
+			row:  map[string]interface{}{"id": "1", "name": "alice", "email": ""},
+			want: []string{"name", "email"},
+		},
+		{
+			name: "MissingColumnIsNull",
+			row:  map[string]interface{}{"id": "1", "name": "bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, diffColumns(query, tt.row))
+		})
+	}
+}
+
+func TestQueryKey(t *testing.T) {
+	query := &mymy.Query{
+		Action: mymy.ActionInsert,
+		Table:  "clients",
+		Values: []mymy.QueryArg{
+			{Field: "id", Value: uint64(1)},
+			{Field: "name", Value: "bob"},
+		},
+	}
+
+	got, err := queryKey([]string{"ID", "name"}, query)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{uint64(1), "bob"}, got)
+	assert.Equal(t, "1"+pkKeySeparator+"bob", formatKey(got))
+
+	_, err = queryKey([]string{"uuid"}, query)
+	assert.Error(t, err)
+}
@@ -1,6 +1,8 @@
 package client
 
 import (
+	"crypto/tls"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -16,6 +18,8 @@ type Config struct {
 	MaxIdleConns   int
 	ConnectTimeout time.Duration
 	WriteTimeout   time.Duration
+	// TLS is the TLS config of the connections, nil to connect in plaintext.
+	TLS *tls.Config
 }
 
 func (c *Config) DSN() string {
@@ -40,6 +44,15 @@ func (c *Config) DSN() string {
 		builder.WriteString("&charset=")
 		builder.WriteString(c.Charset)
 	}
+	if c.TLS != nil {
+		builder.WriteString("&tls=")
+		builder.WriteString(url.QueryEscape(c.tlsKey()))
+	}
 
 	return builder.String()
 }
+
+// tlsKey is the name the TLS config is registered with in the driver.
+func (c *Config) tlsKey() string {
+	return "mymy-" + c.Addr
+}
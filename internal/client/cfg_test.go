@@ -1,6 +1,7 @@
 package client
 
 import (
+	"crypto/tls"
 	"testing"
 	"time"
 
@@ -38,6 +39,17 @@ func TestConfig_DSN(t *testing.T) {
 			},
 			want: "admin:admin1@tcp(db1.storage.ru:3306)/meta?interpolateParams=true&timeout=2m0s&writeTimeout=100ms",
 		},
+		{
+			name: "TLS",
+			cfg: &Config{
+				Addr:     "db1.storage.ru:3306",
+				User:     "admin",
+				Password: "admin1",
+				Database: "meta",
+				TLS:      &tls.Config{},
+			},
+			want: "admin:admin1@tcp(db1.storage.ru:3306)/meta?interpolateParams=true&tls=mymy-db1.storage.ru%3A3306",
+		},
 		{
 			name: "NoTimeouts",
 			cfg: &Config{
@@ -3,6 +3,14 @@ package client
 import (
 	"context"
 	"database/sql"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/city-mobil/go-mymy/internal/metrics"
+	"github.com/city-mobil/go-mymy/internal/tracing"
 )
 
 const (
@@ -10,11 +18,40 @@ const (
 )
 
 type SQLClient struct {
-	db      *sql.DB
+	cfg     Config
 	retries int
+
+	// mu guards the pool replaced on the credentials change.
+	mu *sync.RWMutex
+	db *sql.DB
 }
 
 func New(cfg *Config) (*SQLClient, error) {
+	if cfg.TLS != nil {
+		if err := mysql.RegisterTLSConfig(cfg.tlsKey(), cfg.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := cfg.MaxRetries
+	if retries < 0 {
+		retries = defaultMaxRetries
+	}
+
+	return &SQLClient{
+		cfg:     *cfg,
+		retries: retries,
+		mu:      &sync.RWMutex{},
+		db:      db,
+	}, nil
+}
+
+func open(cfg *Config) (*sql.DB, error) {
 	db, err := sql.Open("mysql", cfg.DSN())
 	if err != nil {
 		return nil, err
@@ -25,24 +62,66 @@ func New(cfg *Config) (*SQLClient, error) {
 
 	err = db.Ping()
 	if err != nil {
+		_ = db.Close()
+
 		return nil, err
 	}
 
-	retries := cfg.MaxRetries
-	if retries < 0 {
-		retries = defaultMaxRetries
+	return db, nil
+}
+
+// SetCredentials replaces the connection pool with the one connecting as the user.
+// The old pool is closed: the queries in progress and the dedicated connections
+// are not interrupted and are closed when released.
+func (c *SQLClient) SetCredentials(user, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if user == c.cfg.User && password == c.cfg.Password {
+		return nil
 	}
 
-	return &SQLClient{
-		db:      db,
-		retries: retries,
-	}, nil
+	cfg := c.cfg
+	cfg.User = user
+	cfg.Password = password
+
+	db, err := open(&cfg)
+	if err != nil {
+		return err
+	}
+
+	old := c.db
+	c.cfg = cfg
+	c.db = db
+
+	return old.Close()
+}
+
+// pool returns the current connection pool.
+func (c *SQLClient) pool() *sql.DB {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.db
 }
 
 func (c *SQLClient) Exec(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	ctx, span := tracing.Start(ctx, "mymy.upstream.exec", semconv.DBSystemMySQL, semconv.DBStatementKey.String(query))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	for attempt := 0; attempt <= c.retries; attempt++ {
-		res, err = c.db.ExecContext(ctx, query, args...)
+		if attempt > 0 {
+			span.AddEvent("retry", trace.WithAttributes(tracing.AttemptKey.Int(attempt)))
+		}
+
+		res, err = c.pool().ExecContext(ctx, query, args...)
 		if canRetry(err) {
+			if attempt < c.retries {
+				metrics.IncUpstreamRetries()
+			}
+
 			continue
 		}
 
@@ -52,10 +131,24 @@ func (c *SQLClient) Exec(ctx context.Context, query string, args ...interface{})
 	return
 }
 
+func (c *SQLClient) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.pool().QueryContext(ctx, query, args...)
+}
+
 func (c *SQLClient) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return c.db.QueryRowContext(ctx, query, args...)
+	return c.pool().QueryRowContext(ctx, query, args...)
+}
+
+// Conn returns a dedicated connection. The session state, e.g. named locks,
+// is bound to it and is not silently lost on reconnect.
+func (c *SQLClient) Conn(ctx context.Context) (*sql.Conn, error) {
+	return c.pool().Conn(ctx)
+}
+
+func (c *SQLClient) Stats() sql.DBStats {
+	return c.pool().Stats()
 }
 
 func (c *SQLClient) Close() error {
-	return c.db.Close()
+	return c.pool().Close()
 }
@@ -1,10 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"time"
 
+	"go.uber.org/multierr"
 	"gopkg.in/yaml.v3"
 )
 
@@ -12,6 +18,7 @@ const (
 	defaultListenAddr               = ":8080"
 	defaultDataFile                 = "/etc/mymy/state.info"
 	defaultPluginDir                = "plugins"
+	defaultPluginCallTimeout        = 10 * time.Second
 	defaultHealthSBM                = 10
 	defaultLogLevel                 = "debug"
 	defaultSysLogEnabled            = false
@@ -28,6 +35,39 @@ const (
 	defaultWriteTimeout             = 1 * time.Second
 	defaultLoadInFileFlushThreshold = 5000
 	defaultАrgEnclose               = `"`
+	defaultSnapshotChunkSize        = 1000
+	defaultFlavor                   = FlavorMySQL
+	defaultTracingSampleRatio       = 1.0
+	defaultHeartbeatInterval        = 1 * time.Second
+	defaultBufferMaxEvents          = 4096
+	defaultBufferMaxRows            = 100000
+	defaultBufferMaxBytes           = 64 << 20
+	defaultBufferSpillMaxBytes      = 1 << 30
+	defaultThrottleCheckInterval    = 1 * time.Second
+	defaultStateKey                 = "mymy"
+	defaultHACheckInterval          = 1 * time.Second
+	defaultSinkType                 = SinkUpstream
+	defaultFileSinkMaxBytes         = 256 << 20
+	defaultFileSinkMaxAge           = 1 * time.Hour
+	defaultFileSinkSyncInterval     = 1 * time.Second
+)
+
+// Supported source flavors.
+const (
+	FlavorMySQL   = "mysql"
+	FlavorMariaDB = "mariadb"
+)
+
+// Supported tracing exporters.
+const (
+	TracingExporterOTLP = "otlp"
+	TracingExporterFile = "file"
+)
+
+// Supported sinks.
+const (
+	SinkUpstream = "upstream"
+	SinkFile     = "file"
 )
 
 type Config struct {
@@ -39,6 +79,26 @@ type Config struct {
 		// GTIDMode indicates when to use GTID-based replication
 		// or binlog file position.
 		GTIDMode bool `yaml:"gtid_mode"`
+		// PauseOnError pauses the replication instead of stopping it
+		// when the event can not be handled or applied to the upstream.
+		PauseOnError bool `yaml:"pause_on_error"`
+		// Buffer limits the events read from the binlog but not yet applied to the upstream.
+		Buffer BufferConfig `yaml:"buffer"`
+		// Delay is the minimum age of the binlog events applied to the upstream.
+		// Zero applies the events at once.
+		Delay time.Duration `yaml:"delay"`
+		// Throttle limits the rate of writes to the upstream.
+		Throttle ThrottleConfig `yaml:"throttle"`
+		// DryRun writes the upstream statements out instead of executing them.
+		DryRun DryRunConfig `yaml:"dry_run"`
+		// Replay reads the events from the local binlog files instead of the source.
+		Replay ReplayConfig `yaml:"replay"`
+		// Start is the position to start from instead of the dump when no position is saved.
+		Start PositionConfig `yaml:"start"`
+		// Stop is the position to stop the replication at once everything before it is applied.
+		Stop PositionConfig `yaml:"stop"`
+		// Sink is where the queries made by the rules are written to.
+		Sink SinkConfig `yaml:"sink"`
 		// SourceOpts is the options of leader.
 		SourceOpts SourceConfig `yaml:"source"`
 		// UpstreamOpts is the options of follower.
@@ -54,12 +114,56 @@ type AppConfig struct {
 	PluginDir  string  `yaml:"plugin_dir"`
 	Health     Health  `yaml:"health"`
 	Logging    Logging `yaml:"logging"`
+	Admin      Admin   `yaml:"admin"`
+	Tracing    Tracing `yaml:"tracing"`
+	State      State   `yaml:"state"`
+	HA         HA      `yaml:"ha"`
+
+	// PluginCallTimeout is a maximum duration of a handler process call.
+	// The process is killed if it does not respond in time. Zero means no limit.
+	PluginCallTimeout time.Duration `yaml:"plugin_call_timeout"`
 }
 
 type Health struct {
 	SecondsBehindMaster int `yaml:"seconds_behind_master"`
 }
 
+type Admin struct {
+	// Token is a bearer token required to call the admin API.
+	// The admin API is disabled if the token is empty.
+	Token Secret `yaml:"token"`
+}
+
+type Tracing struct {
+	// Exporter is otlp or file. The tracing is disabled if the exporter is empty.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is host:port of the OTLP HTTP collector.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure disables TLS for the OTLP collector connection.
+	Insecure bool `yaml:"insecure"`
+	// File is a path to write the spans to by the file exporter.
+	File string `yaml:"file"`
+	// SampleRatio is a fraction of the binlog transactions to trace.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type State struct {
+	// Table is the upstream table to store the replication position in
+	// instead of the data file.
+	Table string `yaml:"table"`
+	// Key identifies the position in the table. The instances with the same key share the position.
+	Key string `yaml:"key"`
+}
+
+type HA struct {
+	// Enabled makes the instances with the same state key compete for the upstream lock,
+	// only the lock holder replicates. It requires the state table.
+	Enabled bool `yaml:"enabled"`
+	// CheckInterval is a period of checking the lock by the leader
+	// and of trying to take it by the standby.
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
 type Logging struct {
 	Level              string `yaml:"level"`
 	SysLogEnabled      bool   `yaml:"syslog_enabled"`
@@ -78,6 +182,7 @@ func (c *AppConfig) withDefaults() {
 	c.ListenAddr = defaultListenAddr
 	c.DataFile = defaultDataFile
 	c.PluginDir = defaultPluginDir
+	c.PluginCallTimeout = defaultPluginCallTimeout
 
 	c.Health.SecondsBehindMaster = defaultHealthSBM
 
@@ -88,6 +193,154 @@ func (c *AppConfig) withDefaults() {
 	c.Logging.MaxSize = defaultLogFileMaxSize
 	c.Logging.MaxBackups = defaultLogFileMaxBackups
 	c.Logging.MaxAge = defaultLogFileMaxAge
+
+	c.Tracing.SampleRatio = defaultTracingSampleRatio
+
+	c.State.Key = defaultStateKey
+	c.HA.CheckInterval = defaultHACheckInterval
+}
+
+type BufferConfig struct {
+	// MaxEvents is a maximum number of the buffered events.
+	MaxEvents int `yaml:"max_events"`
+	// MaxRows is a maximum number of the buffered source rows. Zero means no limit.
+	MaxRows int `yaml:"max_rows"`
+	// MaxBytes is an approximate maximum size of the buffered queries. Zero means no limit.
+	MaxBytes int64 `yaml:"max_bytes"`
+	// SpillDir is a directory to write the events to when the buffer is full instead of
+	// blocking the binlog reading. Leave it empty to disable the spilling.
+	SpillDir string `yaml:"spill_dir"`
+	// SpillMaxBytes limits the size of the spill file. Zero means no limit.
+	SpillMaxBytes int64 `yaml:"spill_max_bytes"`
+}
+
+func (c *BufferConfig) withDefaults() {
+	if c == nil {
+		return
+	}
+
+	c.MaxEvents = defaultBufferMaxEvents
+	c.MaxRows = defaultBufferMaxRows
+	c.MaxBytes = defaultBufferMaxBytes
+	c.SpillMaxBytes = defaultBufferSpillMaxBytes
+}
+
+type DryRunConfig struct {
+	// Enabled makes the replicator write the statements with the interpolated arguments
+	// to the log or to the file instead of executing them. The position is not saved.
+	Enabled bool `yaml:"enabled"`
+	// File is a path to append the statements to. The statements are logged if it is empty.
+	File string `yaml:"file"`
+}
+
+type SinkConfig struct {
+	// Type is upstream to execute the queries on the upstream database
+	// or file to write them to the JSON Lines files.
+	Type string `yaml:"type"`
+	// File is the options of the file sink.
+	File FileSinkConfig `yaml:"file"`
+}
+
+func (c *SinkConfig) withDefaults() {
+	if c == nil {
+		return
+	}
+
+	c.Type = defaultSinkType
+	c.File.MaxBytes = defaultFileSinkMaxBytes
+	c.File.MaxAge = defaultFileSinkMaxAge
+	c.File.SyncInterval = defaultFileSinkSyncInterval
+}
+
+type FileSinkConfig struct {
+	// Dir is a directory to write the files to.
+	Dir string `yaml:"dir"`
+	// MaxBytes is a size of the file to start the next one at. Zero means no limit.
+	MaxBytes int64 `yaml:"max_bytes"`
+	// MaxAge is a period to start the next file after. Zero means no limit.
+	MaxAge time.Duration `yaml:"max_age"`
+	// SyncInterval is a period of syncing the files to the disk. The position
+	// is saved only after the changes before it are synced.
+	SyncInterval time.Duration `yaml:"sync_interval"`
+}
+
+type ReplayConfig struct {
+	// Files are the paths of the binlog files to replay in order.
+	Files []string `yaml:"files"`
+	// SchemaFile is a path to the CREATE TABLE statements of the replicated tables
+	// at the start of the files, e.g. made by mysqldump --no-data. The source is not connected.
+	SchemaFile string `yaml:"schema_file"`
+}
+
+// Enabled returns true if the binlog files are replayed.
+func (c *ReplayConfig) Enabled() bool {
+	return len(c.Files) > 0
+}
+
+// PositionConfig is the binlog position set by one of the GTID set,
+// the binlog file and position or the time.
+type PositionConfig struct {
+	// GTID is the GTID set of the transactions before the position, e.g. restored from a backup.
+	GTID string `yaml:"gtid"`
+	// File and Pos is the binlog position. Pos defaults to the start of the file.
+	File string `yaml:"file"`
+	Pos  uint32 `yaml:"pos"`
+	// Time is the position of the first transaction written to the binlog at or after the time.
+	// The time is in RFC 3339 format, e.g. 2020-01-02T15:04:05+03:00.
+	Time string `yaml:"time"`
+}
+
+// Enabled returns true if the position is set.
+func (c *PositionConfig) Enabled() bool {
+	return c.GTID != "" || c.File != "" || c.Time != ""
+}
+
+// Timestamp returns the parsed time.
+func (c *PositionConfig) Timestamp() (time.Time, error) {
+	return time.Parse(time.RFC3339, c.Time)
+}
+
+// Validate checks only one kind of the position is set.
+func (c *PositionConfig) Validate() error {
+	set := 0
+	for _, value := range []string{c.GTID, c.File, c.Time} {
+		if value != "" {
+			set++
+		}
+	}
+
+	var errs error
+	if set > 1 {
+		errs = multierr.Append(errs, errors.New("only one of gtid, file or time can be set"))
+	}
+	if c.Pos != 0 && c.File == "" {
+		errs = multierr.Append(errs, errors.New("pos requires file"))
+	}
+	if c.Time != "" {
+		if _, err := c.Timestamp(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("time: %w", err))
+		}
+	}
+
+	return errs
+}
+
+type ThrottleConfig struct {
+	// MaxRowsPerSecond limits the rows written to the upstream. Zero means no limit.
+	MaxRowsPerSecond int `yaml:"max_rows_per_second"`
+	// MaxStatementsPerSecond limits the statements executed on the upstream. Zero means no limit.
+	MaxStatementsPerSecond int `yaml:"max_statements_per_second"`
+	// MaxThreadsRunning pauses the writes while the upstream Threads_running exceeds the value.
+	// Zero disables the check.
+	MaxThreadsRunning int `yaml:"max_threads_running"`
+	// MaxReplicaLag pauses the writes while the lag of any upstream replica exceeds the value.
+	// Zero disables the check.
+	MaxReplicaLag time.Duration `yaml:"max_replica_lag"`
+	// Replicas are the addresses of the upstream replicas to check the lag of.
+	// The upstream user and password are used to connect.
+	Replicas []string `yaml:"replicas"`
+	// CheckInterval is a period of checking the upstream load.
+	CheckInterval time.Duration `yaml:"check_interval"`
 }
 
 type SourceConfig struct {
@@ -106,11 +359,81 @@ type SourceConfig struct {
 		// ArgEnclose is a parameter that points to the beginning and end of the arguments in the dump file. Should be byte.
 		ArgEnclose string `yaml:"arg_enclose"`
 	} `yaml:"dump"`
+	Snapshot struct {
+		// ChunkSize is a number of rows to read from the source table at once during the re-snapshot.
+		ChunkSize int `yaml:"chunk_size"`
+		// WatermarkTable is a table in the source database used to merge the re-snapshot chunks
+		// into the binlog stream. Leave it empty to disable the re-snapshot.
+		WatermarkTable string `yaml:"watermark_table"`
+	} `yaml:"snapshot"`
+	Heartbeat struct {
+		// Table is a table in the source database the replicator periodically writes
+		// a timestamp to in order to measure the end-to-end replication lag.
+		// Leave it empty to disable the heartbeat.
+		Table string `yaml:"table"`
+		// Interval is a period of writing the heartbeat.
+		Interval time.Duration `yaml:"interval"`
+	} `yaml:"heartbeat"`
+	// Flavor is a type of the source server: mysql or mariadb.
+	Flavor   string `yaml:"flavor"`
 	Addr     string `yaml:"addr"`
 	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Database string `yaml:"database"`
-	Charset  string `yaml:"charset"`
+	Password Secret `yaml:"password"`
+	// PasswordFile is a path to read the password from instead of the password option.
+	PasswordFile string `yaml:"password_file"`
+	Database     string `yaml:"database"`
+	Charset      string `yaml:"charset"`
+	TLS          TLS    `yaml:"tls"`
+}
+
+type TLS struct {
+	// Enabled requires TLS for the connections.
+	Enabled bool `yaml:"enabled"`
+	// CA is a path to the PEM encoded CA certificates to verify the server with.
+	// The system CA pool is used if empty.
+	CA string `yaml:"ca"`
+	// Cert and Key are paths to the PEM encoded client certificate and its key.
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// ServerName overrides the host name to verify the server certificate with.
+	ServerName string `yaml:"server_name"`
+	// SkipVerify disables the server certificate verification. Use it only for tests.
+	SkipVerify bool `yaml:"skip_verify"`
+}
+
+// Load returns the TLS config of the connections or nil if the TLS is disabled.
+func (c *TLS) Load() (*tls.Config, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.SkipVerify, //nolint:gosec
+	}
+
+	if c.CA != "" {
+		pem, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read tls ca: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca %s", c.CA)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.Cert != "" || c.Key != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not load tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
 }
 
 func (c *SourceConfig) withDefaults() {
@@ -122,6 +445,9 @@ func (c *SourceConfig) withDefaults() {
 	c.Charset = defaultCharset
 	c.Dump.LoadInFileFlushThreshold = defaultLoadInFileFlushThreshold
 	c.Dump.ArgEnclose = defaultАrgEnclose
+	c.Snapshot.ChunkSize = defaultSnapshotChunkSize
+	c.Heartbeat.Interval = defaultHeartbeatInterval
+	c.Flavor = defaultFlavor
 }
 
 func findDumpExecPath() string {
@@ -150,9 +476,11 @@ func isFileExist(path string) bool {
 }
 
 type UpstreamConfig struct {
-	Addr           string        `yaml:"addr"`
-	User           string        `yaml:"user"`
-	Password       string        `yaml:"password"`
+	Addr     string `yaml:"addr"`
+	User     string `yaml:"user"`
+	Password Secret `yaml:"password"`
+	// PasswordFile is a path to read the password from instead of the password option.
+	PasswordFile   string        `yaml:"password_file"`
 	Database       string        `yaml:"database"`
 	Charset        string        `yaml:"charset"`
 	MaxRetries     int           `yaml:"max_retries"`
@@ -160,6 +488,7 @@ type UpstreamConfig struct {
 	MaxIdleConns   int           `yaml:"max_idle_conns"`
 	ConnectTimeout time.Duration `yaml:"connect_timeout"`
 	WriteTimeout   time.Duration `yaml:"write_timeout"`
+	TLS            TLS           `yaml:"tls"`
 }
 
 func (c *UpstreamConfig) withDefaults() {
@@ -201,11 +530,33 @@ func ReadFromFile(path string) (*Config, error) {
 		return nil, err
 	}
 
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = expandEnv(&doc)
+	if err != nil {
+		return nil, err
+	}
+
 	var cfg Config
 	cfg.withDefaults()
-	err = yaml.Unmarshal(data, &cfg)
+	if doc.Kind != 0 {
+		err = decodeStrict(&doc, &cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = resolvePassword(&cfg.Replication.SourceOpts.Password, cfg.Replication.SourceOpts.PasswordFile)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	err = resolvePassword(&cfg.Replication.UpstreamOpts.Password, cfg.Replication.UpstreamOpts.PasswordFile)
+	if err != nil {
+		return nil, fmt.Errorf("upstream: %w", err)
 	}
 
 	if cfg.Replication.SourceOpts.Dump.LoadInFileFlushThreshold == 0 {
@@ -216,9 +567,194 @@ func ReadFromFile(path string) (*Config, error) {
 		cfg.Replication.SourceOpts.Dump.ArgEnclose = defaultАrgEnclose
 	}
 
+	if cfg.Replication.SourceOpts.Snapshot.ChunkSize <= 0 {
+		cfg.Replication.SourceOpts.Snapshot.ChunkSize = defaultSnapshotChunkSize
+	}
+
+	if cfg.Replication.SourceOpts.Heartbeat.Interval <= 0 {
+		cfg.Replication.SourceOpts.Heartbeat.Interval = defaultHeartbeatInterval
+	}
+
+	buffer := cfg.Replication.Buffer
+	if buffer.MaxEvents <= 0 {
+		cfg.Replication.Buffer.MaxEvents = defaultBufferMaxEvents
+	}
+	if buffer.MaxRows < 0 || buffer.MaxBytes < 0 || buffer.SpillMaxBytes < 0 {
+		return nil, errors.New("buffer limits must not be negative")
+	}
+
+	throttle := cfg.Replication.Throttle
+	if throttle.MaxRowsPerSecond < 0 || throttle.MaxStatementsPerSecond < 0 ||
+		throttle.MaxThreadsRunning < 0 || throttle.MaxReplicaLag < 0 {
+		return nil, errors.New("throttle limits must not be negative")
+	}
+	if throttle.CheckInterval <= 0 {
+		cfg.Replication.Throttle.CheckInterval = defaultThrottleCheckInterval
+	}
+
+	switch cfg.Replication.SourceOpts.Flavor {
+	case "":
+		cfg.Replication.SourceOpts.Flavor = defaultFlavor
+	case FlavorMySQL, FlavorMariaDB:
+	default:
+		return nil, fmt.Errorf("unsupported source flavor: %s", cfg.Replication.SourceOpts.Flavor)
+	}
+
+	tracing := cfg.App.Tracing
+	switch tracing.Exporter {
+	case "":
+	case TracingExporterOTLP:
+		if tracing.Endpoint == "" {
+			return nil, errors.New("tracing endpoint is required for otlp exporter")
+		}
+	case TracingExporterFile:
+		if tracing.File == "" {
+			return nil, errors.New("tracing file is required for file exporter")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter: %s", tracing.Exporter)
+	}
+
+	if tracing.SampleRatio < 0 || tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing sample ratio must be in range [0, 1], got %v", tracing.SampleRatio)
+	}
+
+	if _, err = cfg.Replication.SourceOpts.TLS.Load(); err != nil {
+		return nil, fmt.Errorf("invalid source tls: %w", err)
+	}
+	if _, err = cfg.Replication.UpstreamOpts.TLS.Load(); err != nil {
+		return nil, fmt.Errorf("invalid upstream tls: %w", err)
+	}
+
+	if cfg.App.State.Table != "" && cfg.App.State.Key == "" {
+		return nil, errors.New("state key is required for state table")
+	}
+
+	if cfg.App.HA.Enabled && cfg.App.State.Table == "" {
+		return nil, errors.New("state table is required for ha")
+	}
+	if cfg.App.HA.CheckInterval <= 0 {
+		cfg.App.HA.CheckInterval = defaultHACheckInterval
+	}
+
+	if err = validateSink(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err = cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// validateSink checks the sink options. The file sink has no upstream connection,
+// so the features depending on it are not supported.
+func validateSink(cfg *Config) error {
+	sink := cfg.Replication.Sink
+	switch sink.Type {
+	case SinkUpstream:
+		return nil
+	case SinkFile:
+	default:
+		return fmt.Errorf("unsupported sink type: %s", sink.Type)
+	}
+
+	if sink.File.Dir == "" {
+		return errors.New("sink dir is required for file sink")
+	}
+	if sink.File.MaxBytes < 0 || sink.File.MaxAge < 0 {
+		return errors.New("sink file limits must not be negative")
+	}
+	if sink.File.SyncInterval <= 0 {
+		cfg.Replication.Sink.File.SyncInterval = defaultFileSinkSyncInterval
+	}
+
+	throttle := cfg.Replication.Throttle
+	switch {
+	case cfg.App.State.Table != "":
+		return errors.New("state table is not supported by file sink")
+	case cfg.Replication.SourceOpts.Dump.LoadInFileEnabled:
+		return errors.New("load in file is not supported by file sink")
+	case throttle.MaxThreadsRunning > 0 || throttle.MaxReplicaLag > 0:
+		return errors.New("upstream load throttling is not supported by file sink")
+	}
+
+	return nil
+}
+
+// decodeStrict decodes the document rejecting the unknown keys,
+// so the misspelled options are not silently ignored.
+func decodeStrict(doc *yaml.Node, out interface{}) error {
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	return dec.Decode(out)
+}
+
+// validate checks the required options. It returns all the found errors.
+func (c *Config) validate() error {
+	var errs error
+	required := func(value, name string) {
+		if value == "" {
+			errs = multierr.Append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+
+	app := c.App
+	required(app.ListenAddr, "app.listen_addr")
+	if app.State.Table == "" {
+		required(app.DataFile, "app.data_file")
+	}
+
+	src := c.Replication.SourceOpts
+	required(src.Addr, "replication.source.addr")
+	required(src.User, "replication.source.user")
+	required(src.Database, "replication.source.database")
+
+	// The file sink does not connect to the upstream.
+	if c.Replication.Sink.Type == SinkUpstream {
+		upstream := c.Replication.UpstreamOpts
+		required(upstream.Addr, "replication.upstream.addr")
+		required(upstream.User, "replication.upstream.user")
+		required(upstream.Database, "replication.upstream.database")
+	}
+
+	if len(c.Replication.Rules) == 0 {
+		errs = multierr.Append(errs, errors.New("replication.rules must contain at least one rule"))
+	}
+
+	seen := make(map[string]struct{}, len(c.Replication.Rules))
+	for i, rule := range c.Replication.Rules {
+		table := rule.Source.Table
+		required(table, fmt.Sprintf("replication.rules[%d].source.table", i))
+		required(rule.Upstream.Plugin.Name, fmt.Sprintf("replication.rules[%d].upstream.plugin.name", i))
+
+		if _, ok := seen[table]; ok && table != "" {
+			errs = multierr.Append(errs, fmt.Errorf("duplicate rule for table %s", table))
+		}
+		seen[table] = struct{}{}
+	}
+
+	if c.Replication.Delay < 0 {
+		errs = multierr.Append(errs, errors.New("replication.delay must not be negative"))
+	}
+
+	if err := c.Replication.Start.Validate(); err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("replication.start: %w", err))
+	}
+	if err := c.Replication.Stop.Validate(); err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("replication.stop: %w", err))
+	}
+
+	return errs
+}
+
 func (c *Config) withDefaults() {
 	if c == nil {
 		return
@@ -232,4 +768,12 @@ func (c *Config) withDefaults() {
 
 	destConn := &c.Replication.UpstreamOpts
 	destConn.withDefaults()
+
+	buffer := &c.Replication.Buffer
+	buffer.withDefaults()
+
+	sink := &c.Replication.Sink
+	sink.withDefaults()
+
+	c.Replication.Throttle.CheckInterval = defaultThrottleCheckInterval
 }
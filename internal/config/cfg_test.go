@@ -1,7 +1,10 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +18,291 @@ func TestReadFromFile_InvalidPath(t *testing.T) {
 	assert.Nil(t, cfg)
 }
 
+func TestReadFromFile_InvalidFlavor(t *testing.T) {
+	f, err := ioutil.TempFile("", "mymy-*.yml")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	_, err = f.WriteString("replication:\n  source:\n    flavor: 'percona'\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	cfg, err := ReadFromFile(f.Name())
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+}
+
+func TestReadFromFile_InvalidTracing(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "UnknownExporter",
+			data: "app:\n  tracing:\n    exporter: 'jaeger'\n",
+		},
+		{
+			name: "NoEndpoint",
+			data: "app:\n  tracing:\n    exporter: 'otlp'\n",
+		},
+		{
+			name: "NoFile",
+			data: "app:\n  tracing:\n    exporter: 'file'\n",
+		},
+		{
+			name: "SampleRatioOutOfRange",
+			data: "app:\n  tracing:\n    exporter: 'file'\n    file: 'spans.json'\n    sample_ratio: 1.5\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "mymy-*.yml")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.Remove(f.Name())
+			}()
+
+			_, err = f.WriteString(tt.data)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			cfg, err := ReadFromFile(f.Name())
+			assert.Error(t, err)
+			assert.Nil(t, cfg)
+		})
+	}
+}
+
+func TestReadFromFile_InvalidHA(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "NoStateTable",
+			data: "app:\n  ha:\n    enabled: true\n",
+		},
+		{
+			name: "NoStateKey",
+			data: "app:\n  state:\n    table: 'mymy_state'\n    key: ''\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "mymy-*.yml")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.Remove(f.Name())
+			}()
+
+			_, err = f.WriteString(tt.data)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			cfg, err := ReadFromFile(f.Name())
+			assert.Error(t, err)
+			assert.Nil(t, cfg)
+		})
+	}
+}
+
+func TestReadFromFile_InvalidSink(t *testing.T) {
+	const fileSink = "replication:\n  sink:\n    type: 'file'\n    file:\n      dir: '/var/lib/mymy/changes'\n"
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{
+			name: "UnknownType",
+			data: "replication:\n  sink:\n    type: 'kafka'\n",
+		},
+		{
+			name: "NoDir",
+			data: "replication:\n  sink:\n    type: 'file'\n",
+		},
+		{
+			name: "NegativeMaxBytes",
+			data: fileSink + "      max_bytes: -1\n",
+		},
+		{
+			name: "StateTable",
+			data: fileSink + "app:\n  state:\n    table: 'mymy_state'\n",
+		},
+		{
+			name: "LoadInFile",
+			data: fileSink + "  source:\n    dump:\n      load_in_file_enabled: true\n",
+		},
+		{
+			name: "ThreadsRunning",
+			data: fileSink + "  throttle:\n    max_threads_running: 100\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "mymy-*.yml")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.Remove(f.Name())
+			}()
+
+			_, err = f.WriteString(tt.data)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			cfg, err := ReadFromFile(f.Name())
+			assert.Error(t, err)
+			assert.Nil(t, cfg)
+		})
+	}
+}
+
+func TestPositionConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PositionConfig
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "GTID",
+			cfg:  PositionConfig{GTID: "de278ad0-2106-11e4-9f8e-6edd0ca20947:1-10"},
+		},
+		{
+			name: "FilePos",
+			cfg:  PositionConfig{File: "mysql-bin.000003", Pos: 120},
+		},
+		{
+			name: "Time",
+			cfg:  PositionConfig{Time: "2020-01-02T15:04:05Z"},
+		},
+		{
+			name:    "GTIDAndFile",
+			cfg:     PositionConfig{GTID: "0-1-100", File: "mysql-bin.000003"},
+			wantErr: true,
+		},
+		{
+			name:    "PosWithoutFile",
+			cfg:     PositionConfig{Pos: 120},
+			wantErr: true,
+		},
+		{
+			name:    "BadTime",
+			cfg:     PositionConfig{Time: "2020-01-02 15:04:05"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadFromFile_Strict(t *testing.T) {
+	const valid = `
+replication:
+  source:
+    addr: '127.0.0.1:3306'
+    user: 'repl'
+    database: 'city'
+  upstream:
+    addr: '127.0.0.1:3307'
+    user: 'mymy'
+    database: 'town'
+  rules:
+    - source:
+        table: 'users'
+      upstream:
+        plugin:
+          name: 'mirror'
+`
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			data: valid,
+		},
+		{
+			name:    "UnknownKey",
+			data:    valid + "  sorce:\n    addr: '127.0.0.1:3306'\n",
+			wantErr: true,
+		},
+		{
+			name:    "NoRules",
+			data:    valid[:strings.Index(valid, "  rules:")],
+			wantErr: true,
+		},
+		{
+			name:    "NoSourceAddr",
+			data:    strings.Replace(valid, "addr: '127.0.0.1:3306'", "addr: ''", 1),
+			wantErr: true,
+		},
+		{
+			name:    "NoPlugin",
+			data:    strings.Replace(valid, "name: 'mirror'", "name: ''", 1),
+			wantErr: true,
+		},
+		{
+			name: "DuplicateRule",
+			data: valid + `    - source:
+        table: 'users'
+      upstream:
+        plugin:
+          name: 'mirror'
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := ioutil.TempFile("", "mymy-*.yml")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.Remove(f.Name())
+			}()
+
+			_, err = f.WriteString(tt.data)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			cfg, err := ReadFromFile(f.Name())
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, cfg)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotNil(t, cfg)
+		})
+	}
+}
+
 func TestReadFromFile_ValidPath(t *testing.T) {
 	testConfigPath, err := filepath.Abs("testdata/mymy.yml")
 	require.NoError(t, err)
@@ -26,6 +314,7 @@ func TestReadFromFile_ValidPath(t *testing.T) {
 	assert.Equal(t, ":8081", cfg.App.ListenAddr)
 	assert.Equal(t, "/etc/mymy/state.info", cfg.App.DataFile)
 	assert.Equal(t, "/etc/mymy/plugins", cfg.App.PluginDir)
+	assert.Equal(t, 5*time.Second, cfg.App.PluginCallTimeout)
 
 	healthCfg := cfg.App.Health
 	assert.Equal(t, 5, healthCfg.SecondsBehindMaster)
@@ -39,9 +328,53 @@ func TestReadFromFile_ValidPath(t *testing.T) {
 	assert.Equal(t, 3, loggingCfg.MaxBackups)
 	assert.Equal(t, 5, loggingCfg.MaxAge)
 
+	assert.Equal(t, Secret("secret"), cfg.App.Admin.Token)
+
+	tracingCfg := cfg.App.Tracing
+	assert.Equal(t, TracingExporterOTLP, tracingCfg.Exporter)
+	assert.Equal(t, "127.0.0.1:4318", tracingCfg.Endpoint)
+	assert.True(t, tracingCfg.Insecure)
+	assert.Equal(t, "", tracingCfg.File)
+	assert.Equal(t, 0.25, tracingCfg.SampleRatio)
+
+	assert.Equal(t, "mymy_state", cfg.App.State.Table)
+	assert.Equal(t, "users", cfg.App.State.Key)
+	assert.True(t, cfg.App.HA.Enabled)
+	assert.Equal(t, 3*time.Second, cfg.App.HA.CheckInterval)
+
 	require.NotNil(t, cfg.Replication.ServerID)
 	assert.EqualValues(t, 100, *cfg.Replication.ServerID)
 	assert.True(t, cfg.Replication.GTIDMode)
+	assert.True(t, cfg.Replication.PauseOnError)
+	assert.Equal(t, time.Hour, cfg.Replication.Delay)
+
+	buffer := cfg.Replication.Buffer
+	assert.Equal(t, 1024, buffer.MaxEvents)
+	assert.Equal(t, 5000, buffer.MaxRows)
+	assert.EqualValues(t, 1048576, buffer.MaxBytes)
+	assert.Equal(t, "/var/lib/mymy", buffer.SpillDir)
+	assert.EqualValues(t, 0, buffer.SpillMaxBytes)
+
+	throttle := cfg.Replication.Throttle
+	assert.Equal(t, 2000, throttle.MaxRowsPerSecond)
+	assert.Equal(t, 1000, throttle.MaxStatementsPerSecond)
+	assert.Equal(t, 50, throttle.MaxThreadsRunning)
+	assert.Equal(t, 10*time.Second, throttle.MaxReplicaLag)
+	assert.Equal(t, []string{"127.0.0.1:3308"}, throttle.Replicas)
+	assert.Equal(t, 2*time.Second, throttle.CheckInterval)
+
+	assert.Equal(t, DryRunConfig{Enabled: true, File: "/var/log/mymy/statements.sql"}, cfg.Replication.DryRun)
+	assert.Equal(t, PositionConfig{Time: "2020-01-02T15:04:05+03:00"}, cfg.Replication.Start)
+	sinkCfg := cfg.Replication.Sink
+	assert.Equal(t, SinkUpstream, sinkCfg.Type)
+	assert.Equal(t, "/var/lib/mymy/changes", sinkCfg.File.Dir)
+	assert.Equal(t, int64(1<<20), sinkCfg.File.MaxBytes)
+	assert.Equal(t, 10*time.Minute, sinkCfg.File.MaxAge)
+	assert.Equal(t, 500*time.Millisecond, sinkCfg.File.SyncInterval)
+
+	assert.Equal(t, []string{"/var/backups/mysql-bin.000041", "/var/backups/mysql-bin.000042"}, cfg.Replication.Replay.Files)
+	assert.Equal(t, "/var/backups/schema.sql", cfg.Replication.Replay.SchemaFile)
+	assert.Equal(t, PositionConfig{File: "mysql-bin.000042", Pos: 1547}, cfg.Replication.Stop)
 
 	source := cfg.Replication.SourceOpts
 	assert.Equal(t, "/usr/bin/mysqldump", source.Dump.ExecPath)
@@ -49,22 +382,33 @@ func TestReadFromFile_ValidPath(t *testing.T) {
 	assert.Equal(t, []string{"--column-statistics=0"}, source.Dump.ExtraOptions)
 	assert.True(t, source.Dump.LoadInFileEnabled)
 	assert.Equal(t, 10000, source.Dump.LoadInFileFlushThreshold)
+	assert.Equal(t, 500, source.Snapshot.ChunkSize)
+	assert.Equal(t, "mymy_watermark", source.Snapshot.WatermarkTable)
+	assert.Equal(t, "mymy_heartbeat", source.Heartbeat.Table)
+	assert.Equal(t, 500*time.Millisecond, source.Heartbeat.Interval)
+	assert.Equal(t, FlavorMariaDB, source.Flavor)
 	assert.Equal(t, "127.0.0.1:3306", source.Addr)
 	assert.Equal(t, "repl", source.User)
-	assert.Equal(t, "repl", source.Password)
+	assert.Equal(t, Secret("repl"), source.Password)
 	assert.Equal(t, "city", source.Database)
 	assert.Equal(t, "utf8", source.Charset)
+	assert.Equal(t, TLS{Enabled: true, ServerName: "source.local", SkipVerify: true}, source.TLS)
 
 	upstream := cfg.Replication.UpstreamOpts
 	assert.Equal(t, "127.0.0.1:3307", upstream.Addr)
 	assert.Equal(t, "repl", upstream.User)
-	assert.Equal(t, "repl", upstream.Password)
+	assert.Equal(t, Secret("repl"), upstream.Password)
 	assert.Equal(t, "town", upstream.Database)
 	assert.Equal(t, 3, upstream.MaxRetries)
 	assert.Equal(t, 500, upstream.MaxOpenConns)
 	assert.Equal(t, 500, upstream.MaxIdleConns)
 	assert.Equal(t, 500*time.Millisecond, upstream.ConnectTimeout)
 	assert.Equal(t, 500*time.Millisecond, upstream.WriteTimeout)
+	assert.Equal(t, TLS{
+		CA:   "/etc/mymy/ca.pem",
+		Cert: "/etc/mymy/client-cert.pem",
+		Key:  "/etc/mymy/client-key.pem",
+	}, upstream.TLS)
 
 	rules := cfg.Replication.Rules
 	require.Len(t, rules, 1)
@@ -74,3 +418,61 @@ func TestReadFromFile_ValidPath(t *testing.T) {
 	assert.Equal(t, "mymy_filter", rule.Upstream.Plugin.Name)
 	assert.Equal(t, "plugins/mymy.filter.yml", rule.Upstream.Plugin.Config)
 }
+
+func TestTLS_Load(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-tls")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	noCerts := filepath.Join(dir, "ca.pem")
+	require.NoError(t, ioutil.WriteFile(noCerts, []byte("not a certificate"), 0600))
+
+	tests := []struct {
+		name    string
+		opts    TLS
+		wantNil bool
+		wantErr bool
+	}{
+		{
+			name:    "Disabled",
+			opts:    TLS{CA: filepath.Join(dir, "missing.pem")},
+			wantNil: true,
+		},
+		{
+			name: "SystemCA",
+			opts: TLS{Enabled: true},
+		},
+		{
+			name:    "MissingCA",
+			opts:    TLS{Enabled: true, CA: filepath.Join(dir, "missing.pem")},
+			wantErr: true,
+		},
+		{
+			name:    "InvalidCA",
+			opts:    TLS{Enabled: true, CA: noCerts},
+			wantErr: true,
+		},
+		{
+			name:    "MissingKey",
+			opts:    TLS{Enabled: true, Cert: noCerts},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.opts.Load()
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, got == nil)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "******"
+
+// Secret is a string which is not printed, logged or marshaled.
+// Convert it to string to get the value.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// MarshalText hides the secret in JSON and YAML.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// envRef matches ${NAME} and the escaped $${NAME}.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} in the values of the document with the environment variables.
+func expandEnv(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value, err := expandEnvString(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+
+		if value != node.Value {
+			node.Value = value
+			// Resolve the type of the plain value again, e.g. an int from ${PORT},
+			// but keep the string value resolved to null, e.g. the password "null".
+			if node.Style == 0 {
+				node.Tag = ""
+				if isNull(value) {
+					node.Tag = "!!str"
+				}
+			}
+		}
+
+		return nil
+	}
+
+	for _, child := range node.Content {
+		if err := expandEnv(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isNull returns true if the plain YAML value is resolved to null.
+func isNull(value string) bool {
+	switch value {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	default:
+		return false
+	}
+}
+
+func expandEnvString(s string) (string, error) {
+	var err error
+	expanded := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+
+		name := ref[2 : len(ref)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return value
+	})
+
+	return expanded, err
+}
+
+// readSecretFile reads the secret ignoring the trailing line break.
+func readSecretFile(path string) (Secret, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return Secret(strings.TrimRight(string(data), "\r\n")), nil
+}
+
+// resolvePassword reads the password from the file if it is set.
+func resolvePassword(password *Secret, path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if *password != "" {
+		return errors.New("both password and password_file are set")
+	}
+
+	secret, err := readSecretFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read password file: %w", err)
+	}
+	*password = secret
+
+	return nil
+}
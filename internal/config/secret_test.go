@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecret_Redacted(t *testing.T) {
+	opts := UpstreamConfig{User: "repl", Password: "s3cret"}
+
+	assert.NotContains(t, fmt.Sprintf("%v %+v %#v %s", opts, opts, opts, opts.Password), "s3cret")
+
+	data, err := json.Marshal(opts)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cret")
+	assert.Contains(t, string(data), `"Password":"******"`)
+
+	assert.Equal(t, "", Secret("").String())
+	assert.Equal(t, "s3cret", string(opts.Password))
+}
+
+func TestExpandEnvString(t *testing.T) {
+	require.NoError(t, os.Setenv("MYMY_TEST_USER", "repl"))
+	defer func() {
+		_ = os.Unsetenv("MYMY_TEST_USER")
+	}()
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "NoRefs",
+			value: "pa$$word",
+			want:  "pa$$word",
+		},
+		{
+			name:  "Ref",
+			value: "${MYMY_TEST_USER}@${MYMY_TEST_USER}",
+			want:  "repl@repl",
+		},
+		{
+			name:  "Escaped",
+			value: "$${MYMY_TEST_USER}",
+			want:  "${MYMY_TEST_USER}",
+		},
+		{
+			name:    "Unset",
+			value:   "${MYMY_TEST_UNSET}",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnvString(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadFromFile_Secrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-secrets")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	passwordFile := filepath.Join(dir, "password")
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte("from-file\n"), 0600))
+
+	require.NoError(t, os.Setenv("MYMY_TEST_PASSWORD", "from-env"))
+	require.NoError(t, os.Setenv("MYMY_TEST_PORT", "3307"))
+	defer func() {
+		_ = os.Unsetenv("MYMY_TEST_PASSWORD")
+		_ = os.Unsetenv("MYMY_TEST_PORT")
+	}()
+
+	write := func(data string) string {
+		f, errTmp := ioutil.TempFile(dir, "mymy-*.yml")
+		require.NoError(t, errTmp)
+		_, errTmp = f.WriteString(data)
+		require.NoError(t, errTmp)
+		require.NoError(t, f.Close())
+
+		return f.Name()
+	}
+
+	cfg, err := ReadFromFile(write(fmt.Sprintf(`
+app:
+  admin:
+    token: '${MYMY_TEST_PASSWORD}'
+replication:
+  source:
+    addr: '127.0.0.1:3306'
+    user: 'repl'
+    password_file: '%s'
+    database: 'city'
+  upstream:
+    addr: '127.0.0.1:${MYMY_TEST_PORT}'
+    user: 'mymy'
+    password: "${MYMY_TEST_PASSWORD}"
+    database: 'town'
+    max_retries: ${MYMY_TEST_PORT}
+  rules:
+    - source:
+        table: 'users'
+      upstream:
+        plugin:
+          name: 'mirror'
+`, passwordFile)))
+	require.NoError(t, err)
+
+	assert.Equal(t, Secret("from-env"), cfg.App.Admin.Token)
+	assert.Equal(t, Secret("from-file"), cfg.Replication.SourceOpts.Password)
+	assert.Equal(t, Secret("from-env"), cfg.Replication.UpstreamOpts.Password)
+	assert.Equal(t, "127.0.0.1:3307", cfg.Replication.UpstreamOpts.Addr)
+	assert.Equal(t, 3307, cfg.Replication.UpstreamOpts.MaxRetries)
+
+	invalid := []string{
+		"replication:\n  upstream:\n    password: '${MYMY_TEST_UNSET}'\n",
+		fmt.Sprintf("replication:\n  source:\n    password: 'repl'\n    password_file: '%s'\n", passwordFile),
+		"replication:\n  upstream:\n    password_file: '/nonexistent/password'\n",
+	}
+	for _, data := range invalid {
+		cfg, err = ReadFromFile(write(data))
+		assert.Error(t, err, data)
+		assert.Nil(t, cfg)
+	}
+}
+
+func TestReadFromFile_NullSecret(t *testing.T) {
+	f, err := ioutil.TempFile("", "mymy-*.yml")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+
+	_, err = f.WriteString(`
+replication:
+  source:
+    addr: '127.0.0.1:3306'
+    user: 'repl'
+    password: ${DB_PASS}
+    database: 'city'
+  upstream:
+    addr: '127.0.0.1:3307'
+    user: 'mymy'
+    database: 'town'
+  rules:
+    - source:
+        table: 'users'
+      upstream:
+        plugin:
+          name: 'mirror'
+`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	defer func() {
+		_ = os.Unsetenv("DB_PASS")
+	}()
+
+	for _, value := range []string{"null", "NULL", "~"} {
+		require.NoError(t, os.Setenv("DB_PASS", value))
+
+		cfg, errRead := ReadFromFile(f.Name())
+		require.NoError(t, errRead, value)
+		assert.Equal(t, Secret(value), cfg.Replication.SourceOpts.Password)
+	}
+}
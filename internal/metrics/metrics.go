@@ -1,6 +1,10 @@
 package metrics
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 type ReplState int8
 
@@ -28,12 +32,161 @@ var (
 		Name:      "state",
 		Help:      "The replication running state: 0=stopped, 1=dumping, 2=running",
 	})
+
+	rowsEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "row_events_total",
+		Help:      "Number of the binlog row events received from the source",
+	}, []string{"schema", "table", "action"})
+
+	queries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "queries_total",
+		Help:      "Number of the queries emitted by the rule handlers, labelled by the source table and event action",
+	}, []string{"schema", "table", "action"})
+
+	handlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "handler_errors_total",
+		Help:      "Number of the errors returned by the rule handlers",
+	}, []string{"schema", "table"})
+
+	dumpRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "dump_rows_total",
+		Help:      "Number of the source rows loaded during the initial dump",
+	}, []string{"schema", "table"})
+
+	upstreamRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "upstream_retries_total",
+		Help:      "Number of the retried upstream queries",
+	})
+
+	batchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "mymy",
+		Name:      "batch_duration_seconds",
+		Help:      "Duration of applying a batch of queries to the upstream, labelled by the source table",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 16),
+	}, []string{"table"})
+
+	flushDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "mymy",
+		Name:      "infile_flush_duration_seconds",
+		Help:      "Duration of loading the dumped rows to the upstream table using LOAD DATA INFILE",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"table"})
+
+	queueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "queue_length",
+		Help:      "Number of the events waiting to be applied to the upstream",
+	})
+
+	bufferRows = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "buffer_rows",
+		Help:      "Number of the buffered queries waiting to be applied to the upstream",
+	})
+
+	bufferBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "buffer_bytes",
+		Help:      "Approximate size of the buffered queries waiting to be applied to the upstream",
+	})
+
+	bufferSpilledEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "buffer_spilled_events",
+		Help:      "Number of the events spilled to disk",
+	})
+
+	bufferSpilledBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "buffer_spilled_bytes",
+		Help:      "Size of the spill file",
+	})
+
+	bufferBlocked = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "buffer_blocked_seconds_total",
+		Help:      "Time the binlog reader has been blocked waiting for the buffer space",
+	})
+
+	throttled = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "throttled",
+		Help:      "Whether the upstream writes are paused due to the upstream load: 0=no, 1=yes",
+	})
+
+	throttleWait = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "mymy",
+		Name:      "throttle_wait_seconds_total",
+		Help:      "Time the upstream writes have been delayed by the throttling, labelled by the reason",
+	}, []string{"reason"})
+
+	upstreamThreadsRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "upstream_threads_running",
+		Help:      "Last checked Threads_running of the upstream",
+	})
+
+	upstreamReplicaLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "upstream_replica_lag_seconds",
+		Help:      "Last checked replication lag of the upstream replicas",
+	}, []string{"replica"})
+
+	heartbeatLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "heartbeat_lag_seconds",
+		Help:      "Time passed since the last heartbeat applied to the upstream has been written to the source",
+	})
+
+	replicationDelay = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "replication_delay_seconds",
+		Help:      "Configured minimum age of the binlog events applied to the upstream",
+	})
+
+	appliedEventAge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "applied_event_age_seconds",
+		Help:      "Time passed since the last applied binlog event has been written to the source when it has been applied",
+	})
+
+	haLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "mymy",
+		Name:      "ha_leader",
+		Help:      "Whether the instance holds the HA lock and replicates: 0=standby, 1=leader",
+	})
 )
 
 func Init() {
 	prometheus.MustRegister(secondsBehindMaster)
 	prometheus.MustRegister(replState)
 	prometheus.MustRegister(syncedSecondsAgo)
+	prometheus.MustRegister(rowsEvents)
+	prometheus.MustRegister(queries)
+	prometheus.MustRegister(handlerErrors)
+	prometheus.MustRegister(dumpRows)
+	prometheus.MustRegister(upstreamRetries)
+	prometheus.MustRegister(batchDuration)
+	prometheus.MustRegister(flushDuration)
+	prometheus.MustRegister(queueLength)
+	prometheus.MustRegister(bufferRows)
+	prometheus.MustRegister(bufferBytes)
+	prometheus.MustRegister(bufferSpilledEvents)
+	prometheus.MustRegister(bufferSpilledBytes)
+	prometheus.MustRegister(bufferBlocked)
+	prometheus.MustRegister(throttled)
+	prometheus.MustRegister(throttleWait)
+	prometheus.MustRegister(upstreamThreadsRunning)
+	prometheus.MustRegister(upstreamReplicaLag)
+	prometheus.MustRegister(heartbeatLag)
+	prometheus.MustRegister(replicationDelay)
+	prometheus.MustRegister(appliedEventAge)
+	prometheus.MustRegister(haLeader)
 }
 
 func SetSecondsBehindMaster(value uint32) {
@@ -47,3 +200,86 @@ func SetSyncedSecondsAgo(sec int64) {
 func SetReplicationState(state ReplState) {
 	replState.Set(float64(state))
 }
+
+func AddRowsEvent(schema, table, action string) {
+	rowsEvents.WithLabelValues(schema, table, action).Inc()
+}
+
+func AddQueries(schema, table, action string, emitted int) {
+	queries.WithLabelValues(schema, table, action).Add(float64(emitted))
+}
+
+func IncHandlerErrors(schema, table string) {
+	handlerErrors.WithLabelValues(schema, table).Inc()
+}
+
+func AddDumpRows(schema, table string, rows int) {
+	dumpRows.WithLabelValues(schema, table).Add(float64(rows))
+}
+
+func IncUpstreamRetries() {
+	upstreamRetries.Inc()
+}
+
+func ObserveBatchDuration(table string, d time.Duration) {
+	batchDuration.WithLabelValues(table).Observe(d.Seconds())
+}
+
+func ObserveFlushDuration(table string, d time.Duration) {
+	flushDuration.WithLabelValues(table).Observe(d.Seconds())
+}
+
+func SetQueueLength(n int) {
+	queueLength.Set(float64(n))
+}
+
+func SetBufferStatus(rows int, bytes int64, spilled int, spilledBytes int64) {
+	bufferRows.Set(float64(rows))
+	bufferBytes.Set(float64(bytes))
+	bufferSpilledEvents.Set(float64(spilled))
+	bufferSpilledBytes.Set(float64(spilledBytes))
+}
+
+func AddBufferBlocked(d time.Duration) {
+	bufferBlocked.Add(d.Seconds())
+}
+
+func SetThrottled(v bool) {
+	if v {
+		throttled.Set(1)
+	} else {
+		throttled.Set(0)
+	}
+}
+
+func AddThrottleWait(reason string, d time.Duration) {
+	throttleWait.WithLabelValues(reason).Add(d.Seconds())
+}
+
+func SetUpstreamThreadsRunning(n int) {
+	upstreamThreadsRunning.Set(float64(n))
+}
+
+func SetUpstreamReplicaLag(replica string, d time.Duration) {
+	upstreamReplicaLag.WithLabelValues(replica).Set(d.Seconds())
+}
+
+func SetHeartbeatLag(d time.Duration) {
+	heartbeatLag.Set(d.Seconds())
+}
+
+func SetReplicationDelay(d time.Duration) {
+	replicationDelay.Set(d.Seconds())
+}
+
+func SetAppliedEventAge(d time.Duration) {
+	appliedEventAge.Set(d.Seconds())
+}
+
+func SetLeader(v bool) {
+	if v {
+		haLeader.Set(1)
+	} else {
+		haLeader.Set(0)
+	}
+}
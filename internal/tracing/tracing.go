@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
+	"go.opentelemetry.io/otel/exporters/stdout"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+const (
+	serviceName    = "mymy"
+	instrumentName = "github.com/city-mobil/go-mymy"
+)
+
+// Span attribute keys.
+const (
+	TableKey   = attribute.Key("mymy.table")
+	ActionKey  = attribute.Key("mymy.action")
+	GTIDKey    = attribute.Key("mymy.gtid")
+	RowsKey    = attribute.Key("mymy.rows")
+	AttemptKey = attribute.Key("mymy.attempt")
+)
+
+// ShutdownFunc flushes the finished spans and stops the exporter.
+type ShutdownFunc func(ctx context.Context) error
+
+// Init sets up the global tracer provider.
+// The spans are dropped if the tracing is disabled.
+func Init(cfg config.Tracing, version string) (ShutdownFunc, error) {
+	if cfg.Exporter == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   func() error
+	)
+	switch cfg.Exporter {
+	case config.TracingExporterOTLP:
+		opts := []otlphttp.Option{
+			otlphttp.WithEndpoint(cfg.Endpoint),
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		}
+
+		exp, err := otlp.NewExporter(context.Background(), otlphttp.NewDriver(opts...))
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+	case config.TracingExporterFile:
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		exp, err := stdout.NewExporter(stdout.WithWriter(file), stdout.WithoutMetricExport())
+		if err != nil {
+			_ = file.Close()
+
+			return nil, err
+		}
+		exporter = exp
+		closer = file.Close
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if errClose := closer(); err == nil {
+				err = errClose
+			}
+		}
+
+		return err
+	}, nil
+}
+
+// Start creates a span and a context containing it.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error if any and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/city-mobil/go-mymy/internal/config"
+)
+
+func TestInit_Disabled(t *testing.T) {
+	shutdown, err := Init(config.Tracing{}, "dev")
+	require.NoError(t, err)
+
+	_, span := Start(context.Background(), "mymy.test")
+	assert.False(t, span.SpanContext().IsSampled())
+	End(span, nil)
+
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+func TestInit_File(t *testing.T) {
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	dir, err := ioutil.TempDir("", "mymy-tracing")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	path := filepath.Join(dir, "spans.json")
+	shutdown, err := Init(config.Tracing{
+		Exporter:    config.TracingExporterFile,
+		File:        path,
+		SampleRatio: 1,
+	}, "dev")
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "mymy.transaction", GTIDKey.String("de278ad0-2106-11e4-9f8e-6edd0ca20947:1"))
+	_, child := Start(ctx, "mymy.apply", TableKey.String("city.users"), ActionKey.String("insert"))
+	assert.True(t, child.SpanContext().IsSampled())
+	assert.Equal(t, parent.SpanContext().TraceID(), child.SpanContext().TraceID())
+
+	End(child, errors.New("upstream is down"))
+	End(parent, nil)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "mymy.transaction")
+	assert.Contains(t, string(data), "mymy.apply")
+	assert.Contains(t, string(data), "city.users")
+	assert.Contains(t, string(data), "upstream is down")
+}
+
+func TestInit_NotSampled(t *testing.T) {
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	dir, err := ioutil.TempDir("", "mymy-tracing")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	shutdown, err := Init(config.Tracing{
+		Exporter:    config.TracingExporterFile,
+		File:        filepath.Join(dir, "spans.json"),
+		SampleRatio: 0,
+	}, "dev")
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "mymy.transaction")
+	_, child := Start(ctx, "mymy.apply")
+	assert.False(t, parent.SpanContext().IsSampled())
+	assert.False(t, child.SpanContext().IsSampled())
+	End(child, nil)
+	End(parent, nil)
+
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -18,6 +18,8 @@ const (
 	ActionInsert Action = "insert"
 	ActionUpdate Action = "update"
 	ActionDelete Action = "delete"
+	// ActionUpsert inserts a row or updates the existing one on a duplicate key.
+	ActionUpsert Action = "upsert"
 )
 
 type QueryArg struct {
@@ -40,6 +42,8 @@ func (q *Query) SQL() (sql string, args []interface{}, err error) {
 		return q.toUpdateSQL()
 	case ActionDelete:
 		return q.toDeleteSQL()
+	case ActionUpsert:
+		return q.toUpsertSQL()
 	default:
 		err = fmt.Errorf("unknown action type: %s", q.Action)
 	}
@@ -82,6 +86,28 @@ func (q *Query) toInsertSQL() (sql string, args []interface{}, err error) {
 	return sql, args, err
 }
 
+func (q *Query) toUpsertSQL() (sql string, args []interface{}, err error) {
+	sql, args, err = q.toInsertSQL()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sql)
+	sb.WriteString(" ON DUPLICATE KEY UPDATE ")
+	for i, arg := range q.Values {
+		sb.WriteString(arg.Field)
+		sb.WriteString("=VALUES(")
+		sb.WriteString(arg.Field)
+		sb.WriteRune(')')
+		if i < len(q.Values)-1 {
+			sb.WriteString(", ")
+		}
+	}
+
+	return sb.String(), args, nil
+}
+
 func (q *Query) toUpdateSQL() (sql string, args []interface{}, err error) {
 	if q.Table == "" {
 		return "", nil, ErrEmptyTable
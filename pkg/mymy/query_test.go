@@ -63,6 +63,28 @@ func TestQuery_SQL(t *testing.T) {
 			wantArgs: []interface{}{1, "bob", "bob@mail.com"},
 			wantErr:  false,
 		},
+		{
+			name: "Upsert_EmptyValues",
+			fields: fields{
+				Action: ActionUpsert,
+				Table:  "users",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Upsert_MultipleValues",
+			fields: fields{
+				Action: ActionUpsert,
+				Table:  "users",
+				Values: []QueryArg{
+					{Field: "id", Value: 1},
+					{Field: "name", Value: "bob"},
+				},
+			},
+			wantSQL:  "INSERT INTO users (id,name) VALUES (?,?) ON DUPLICATE KEY UPDATE id=VALUES(id), name=VALUES(name)",
+			wantArgs: []interface{}{1, "bob"},
+			wantErr:  false,
+		},
 		{
 			name: "Update_EmptyTable",
 			fields: fields{
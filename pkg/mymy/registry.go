@@ -0,0 +1,59 @@
+package mymy
+
+import (
+	"sort"
+	"sync"
+)
+
+// NewEventHandlerFunc creates the handler using a path to its configuration file.
+type NewEventHandlerFunc func(cfgPath string) (EventHandler, error)
+
+var registry = struct {
+	mu       sync.RWMutex
+	handlers map[string]NewEventHandlerFunc
+}{
+	handlers: make(map[string]NewEventHandlerFunc),
+}
+
+// Register makes the handler available by the name used in the rule plugin options.
+// Call it from the init function of the package with your handler and import
+// the package in the replicator binary.
+//
+// Register panics if the constructor is nil or the name is already registered.
+func Register(name string, fn NewEventHandlerFunc) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if fn == nil {
+		panic("mymy: register nil handler constructor " + name)
+	}
+	if _, dup := registry.handlers[name]; dup {
+		panic("mymy: register called twice for handler " + name)
+	}
+
+	registry.handlers[name] = fn
+}
+
+// Lookup returns the constructor of the registered handler.
+func Lookup(name string) (NewEventHandlerFunc, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	fn, ok := registry.handlers[name]
+
+	return fn, ok
+}
+
+// Registered returns the sorted names of the registered handlers.
+func Registered() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names := make([]string, 0, len(registry.handlers))
+	for name := range registry.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,36 @@
+package mymy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	newHandler := func(_ string) (EventHandler, error) {
+		return NewBaseEventHandler("users"), nil
+	}
+
+	Register("test_handler_b", newHandler)
+	Register("test_handler_a", newHandler)
+
+	fn, ok := Lookup("test_handler_a")
+	require.True(t, ok)
+
+	h, err := fn("")
+	require.NoError(t, err)
+	assert.NotNil(t, h)
+
+	_, ok = Lookup("test_handler_c")
+	assert.False(t, ok)
+
+	assert.Subset(t, Registered(), []string{"test_handler_a", "test_handler_b"})
+
+	assert.Panics(t, func() {
+		Register("test_handler_a", newHandler)
+	})
+	assert.Panics(t, func() {
+		Register("test_handler_c", nil)
+	})
+}
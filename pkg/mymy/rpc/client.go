@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+// Client calls the handler served with ServeConn.
+// The client is not safe for concurrent use.
+type Client struct {
+	r      *bufio.Reader
+	w      *bufio.Writer
+	nextID uint64
+}
+
+func NewClient(r io.Reader, w io.Writer) *Client {
+	return &Client{
+		r: bufio.NewReader(r),
+		w: bufio.NewWriter(w),
+	}
+}
+
+// Init creates the handler with the configuration file.
+func (c *Client) Init(cfgPath string) error {
+	_, err := c.call(&Request{
+		Method: MethodInit,
+		Config: cfgPath,
+	})
+
+	return err
+}
+
+func (c *Client) OnTableChanged(info mymy.SourceInfo) error {
+	source := EncodeSourceInfo(info)
+	_, err := c.call(&Request{
+		Method: MethodTableChanged,
+		Source: &source,
+	})
+
+	return err
+}
+
+func (c *Client) OnRows(e *mymy.RowsEvent) ([]*mymy.Query, error) {
+	event, err := EncodeRowsEvent(e)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.call(&Request{
+		Method: MethodRows,
+		Event:  event,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeQueries(resp.Queries)
+}
+
+// call sends the request and waits for the response.
+// The handler errors are returned as *RemoteError.
+func (c *Client) call(req *Request) (*Response, error) {
+	c.nextID++
+	req.ID = c.nextID
+
+	if err := WriteMessage(c.w, req); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	var resp Response
+	if err := ReadMessage(c.r, &resp); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return nil, err
+	}
+	if resp.ID != req.ID {
+		return nil, fmt.Errorf("rpc: unexpected response id %d, expected %d", resp.ID, req.ID)
+	}
+	if resp.Error != "" {
+		return nil, &RemoteError{Message: resp.Error}
+	}
+
+	return &resp, nil
+}
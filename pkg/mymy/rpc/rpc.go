@@ -0,0 +1,284 @@
+// Package rpc implements the protocol to run the event handlers as separate processes.
+//
+// The replicator starts the handler executable and talks to it over stdin and stdout.
+// Every message is a JSON document prefixed with its length as a 4-byte big-endian
+// unsigned integer. The replicator sends a Request and waits for the Response with
+// the same id before sending the next one. The first request is always "init" with
+// a path to the handler configuration file.
+//
+// The handler must not write anything else to stdout; use stderr for logs.
+// The handler should exit when stdin is closed.
+package rpc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+// MaxMessageSize is the maximum size of the message body.
+const MaxMessageSize = 64 << 20
+
+type Method string
+
+const (
+	MethodInit         Method = "init"
+	MethodTableChanged Method = "table_changed"
+	MethodRows         Method = "rows"
+)
+
+type Request struct {
+	ID     uint64      `json:"id"`
+	Method Method      `json:"method"`
+	Config string      `json:"config,omitempty"`
+	Source *SourceInfo `json:"source,omitempty"`
+	Event  *RowsEvent  `json:"event,omitempty"`
+}
+
+type Response struct {
+	ID      uint64  `json:"id"`
+	Error   string  `json:"error,omitempty"`
+	Queries []Query `json:"queries,omitempty"`
+}
+
+type Column struct {
+	Index      uint64          `json:"index"`
+	Name       string          `json:"name"`
+	Type       mymy.ColumnType `json:"type"`
+	Collation  string          `json:"collation,omitempty"`
+	IsAuto     bool            `json:"is_auto,omitempty"`
+	IsUnsigned bool            `json:"is_unsigned,omitempty"`
+	IsVirtual  bool            `json:"is_virtual,omitempty"`
+}
+
+type SourceInfo struct {
+	Schema string   `json:"schema"`
+	Table  string   `json:"table"`
+	PKs    []Column `json:"pks"`
+	Cols   []Column `json:"cols"`
+}
+
+type RowsEvent struct {
+	Action mymy.Action `json:"action"`
+	Source SourceInfo  `json:"source"`
+	Rows   [][]Value   `json:"rows"`
+}
+
+type QueryArg struct {
+	Field string `json:"field"`
+	Value Value  `json:"value"`
+}
+
+type Query struct {
+	Action mymy.Action `json:"action"`
+	Table  string      `json:"table"`
+	Values []QueryArg  `json:"values,omitempty"`
+	Where  []QueryArg  `json:"where,omitempty"`
+}
+
+// RemoteError is an error returned by the handler.
+type RemoteError struct {
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Message
+}
+
+// WriteMessage writes the length-prefixed JSON message.
+func WriteMessage(w io.Writer, msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(body) > MaxMessageSize {
+		return fmt.Errorf("rpc: message size %d exceeds limit %d", len(body), MaxMessageSize)
+	}
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(body)))
+	if _, err = w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+
+	return err
+}
+
+// ReadMessage reads the length-prefixed JSON message.
+// It returns io.EOF if the stream is closed before the message starts.
+func ReadMessage(r io.Reader, msg interface{}) error {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxMessageSize {
+		return fmt.Errorf("rpc: message size %d exceeds limit %d", size, MaxMessageSize)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	return json.Unmarshal(body, msg)
+}
+
+func encodeColumns(cols []mymy.Column) []Column {
+	res := make([]Column, len(cols))
+	for i, col := range cols {
+		res[i] = Column(col)
+	}
+
+	return res
+}
+
+func decodeColumns(cols []Column) []mymy.Column {
+	res := make([]mymy.Column, len(cols))
+	for i, col := range cols {
+		res[i] = mymy.Column(col)
+	}
+
+	return res
+}
+
+func EncodeSourceInfo(info mymy.SourceInfo) SourceInfo {
+	return SourceInfo{
+		Schema: info.Schema,
+		Table:  info.Table,
+		PKs:    encodeColumns(info.PKs),
+		Cols:   encodeColumns(info.Cols),
+	}
+}
+
+func (info SourceInfo) Decode() mymy.SourceInfo {
+	return mymy.SourceInfo{
+		Schema: info.Schema,
+		Table:  info.Table,
+		PKs:    decodeColumns(info.PKs),
+		Cols:   decodeColumns(info.Cols),
+	}
+}
+
+func EncodeRowsEvent(e *mymy.RowsEvent) (*RowsEvent, error) {
+	rows := make([][]Value, len(e.Rows))
+	for i, row := range e.Rows {
+		values, err := encodeRow(row)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = values
+	}
+
+	return &RowsEvent{
+		Action: e.Action,
+		Source: EncodeSourceInfo(e.Source),
+		Rows:   rows,
+	}, nil
+}
+
+func (e *RowsEvent) Decode() (*mymy.RowsEvent, error) {
+	rows := make([][]interface{}, len(e.Rows))
+	for i, values := range e.Rows {
+		row, err := decodeRow(values)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+
+	return &mymy.RowsEvent{
+		Action: e.Action,
+		Source: e.Source.Decode(),
+		Rows:   rows,
+	}, nil
+}
+
+func encodeArgs(args []mymy.QueryArg) ([]QueryArg, error) {
+	if args == nil {
+		return nil, nil
+	}
+
+	res := make([]QueryArg, len(args))
+	for i, arg := range args {
+		val, err := EncodeValue(arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", arg.Field, err)
+		}
+		res[i] = QueryArg{Field: arg.Field, Value: val}
+	}
+
+	return res, nil
+}
+
+func decodeArgs(args []QueryArg) ([]mymy.QueryArg, error) {
+	if args == nil {
+		return nil, nil
+	}
+
+	res := make([]mymy.QueryArg, len(args))
+	for i, arg := range args {
+		val, err := arg.Value.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", arg.Field, err)
+		}
+		res[i] = mymy.QueryArg{Field: arg.Field, Value: val}
+	}
+
+	return res, nil
+}
+
+func EncodeQueries(queries []*mymy.Query) ([]Query, error) {
+	res := make([]Query, len(queries))
+	for i, q := range queries {
+		values, err := encodeArgs(q.Values)
+		if err != nil {
+			return nil, err
+		}
+		where, err := encodeArgs(q.Where)
+		if err != nil {
+			return nil, err
+		}
+
+		res[i] = Query{
+			Action: q.Action,
+			Table:  q.Table,
+			Values: values,
+			Where:  where,
+		}
+	}
+
+	return res, nil
+}
+
+func DecodeQueries(queries []Query) ([]*mymy.Query, error) {
+	res := make([]*mymy.Query, len(queries))
+	for i, q := range queries {
+		values, err := decodeArgs(q.Values)
+		if err != nil {
+			return nil, err
+		}
+		where, err := decodeArgs(q.Where)
+		if err != nil {
+			return nil, err
+		}
+
+		res[i] = &mymy.Query{
+			Action: q.Action,
+			Table:  q.Table,
+			Values: values,
+			Where:  where,
+		}
+	}
+
+	return res, nil
+}
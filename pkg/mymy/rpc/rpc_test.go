@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+var errBrokenRow = errors.New("broken row")
+
+type echoHandler struct {
+	table string
+	info  mymy.SourceInfo
+}
+
+func (h *echoHandler) OnTableChanged(info mymy.SourceInfo) error {
+	h.info = info
+
+	return nil
+}
+
+func (h *echoHandler) OnRows(e *mymy.RowsEvent) ([]*mymy.Query, error) {
+	if len(e.Rows) == 0 {
+		return nil, errBrokenRow
+	}
+
+	queries := make([]*mymy.Query, 0, len(e.Rows))
+	for _, row := range e.Rows {
+		queries = append(queries, &mymy.Query{
+			Action: e.Action,
+			Table:  h.table,
+			Values: []mymy.QueryArg{
+				{Field: "id", Value: row[0]},
+				{Field: "name", Value: row[1]},
+				{Field: "schema", Value: h.info.Schema},
+			},
+		})
+	}
+
+	return queries, nil
+}
+
+func TestClient_Server(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(reqR, respW, func(cfgPath string) (mymy.EventHandler, error) {
+			return &echoHandler{table: cfgPath}, nil
+		})
+	}()
+
+	client := NewClient(respR, reqW)
+
+	_, err := client.OnRows(&mymy.RowsEvent{})
+	assert.Equal(t, &RemoteError{Message: errNotInitialized.Error()}, err)
+
+	require.NoError(t, client.Init("clients"))
+
+	info := mymy.SourceInfo{
+		Schema: "city",
+		Table:  "users",
+		PKs:    []mymy.Column{{Index: 0, Name: "id", Type: mymy.TypeNumber, IsUnsigned: true}},
+		Cols:   []mymy.Column{{Index: 1, Name: "name", Type: mymy.TypeBinary}},
+	}
+	require.NoError(t, client.OnTableChanged(info))
+
+	queries, err := client.OnRows(&mymy.RowsEvent{
+		Action: mymy.ActionInsert,
+		Source: info,
+		Rows:   [][]interface{}{{uint64(1), []byte("bob")}, {uint64(2), nil}},
+	})
+	require.NoError(t, err)
+
+	want := []*mymy.Query{
+		{
+			Action: mymy.ActionInsert,
+			Table:  "clients",
+			Values: []mymy.QueryArg{{Field: "id", Value: uint64(1)}, {Field: "name", Value: []byte("bob")}, {Field: "schema", Value: "city"}},
+		},
+		{
+			Action: mymy.ActionInsert,
+			Table:  "clients",
+			Values: []mymy.QueryArg{{Field: "id", Value: uint64(2)}, {Field: "name", Value: nil}, {Field: "schema", Value: "city"}},
+		},
+	}
+	assert.Equal(t, want, queries)
+
+	_, err = client.OnRows(&mymy.RowsEvent{Action: mymy.ActionInsert})
+	var remoteErr *RemoteError
+	require.True(t, errors.As(err, &remoteErr))
+	assert.Equal(t, errBrokenRow.Error(), remoteErr.Message)
+
+	require.NoError(t, reqW.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestReadMessage_TooLarge(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{0xff, 0xff, 0xff, 0xff})
+
+	var resp Response
+	assert.Error(t, ReadMessage(buf, &resp))
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+var errNotInitialized = errors.New("rpc: handler is not initialized")
+
+// Serve runs the handler over stdin and stdout until stdin is closed.
+//
+// Call it from the main function of the handler executable:
+//
+//	func main() {
+//		if err := rpc.Serve(NewEventHandler); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+func Serve(newHandler mymy.NewEventHandlerFunc) error {
+	return ServeConn(os.Stdin, os.Stdout, newHandler)
+}
+
+// ServeConn runs the handler reading the requests from r and writing the responses to w.
+func ServeConn(r io.Reader, w io.Writer, newHandler mymy.NewEventHandlerFunc) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	var h mymy.EventHandler
+	for {
+		var req Request
+		err := ReadMessage(br, &req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := Response{ID: req.ID}
+		queries, err := handle(&h, newHandler, &req)
+		if err == nil {
+			resp.Queries, err = EncodeQueries(queries)
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+
+		if err = WriteMessage(bw, &resp); err != nil {
+			return err
+		}
+		if err = bw.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func handle(h *mymy.EventHandler, newHandler mymy.NewEventHandlerFunc, req *Request) ([]*mymy.Query, error) {
+	if req.Method == MethodInit {
+		handler, err := newHandler(req.Config)
+		if err != nil {
+			return nil, err
+		}
+		*h = handler
+
+		return nil, nil
+	}
+
+	if *h == nil {
+		return nil, errNotInitialized
+	}
+
+	switch req.Method {
+	case MethodTableChanged:
+		if req.Source == nil {
+			return nil, errors.New("rpc: empty source info")
+		}
+
+		return nil, (*h).OnTableChanged(req.Source.Decode())
+	case MethodRows:
+		if req.Event == nil {
+			return nil, errors.New("rpc: empty rows event")
+		}
+
+		e, err := req.Event.Decode()
+		if err != nil {
+			return nil, err
+		}
+
+		return (*h).OnRows(e)
+	default:
+		return nil, fmt.Errorf("rpc: unknown method %q", req.Method)
+	}
+}
@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Value types keep the exact Go type of the row values and the query arguments.
+const (
+	TypeNull    = "null"
+	TypeBool    = "bool"
+	TypeInt     = "int"
+	TypeInt8    = "int8"
+	TypeInt16   = "int16"
+	TypeInt32   = "int32"
+	TypeInt64   = "int64"
+	TypeUint    = "uint"
+	TypeUint8   = "uint8"
+	TypeUint16  = "uint16"
+	TypeUint32  = "uint32"
+	TypeUint64  = "uint64"
+	TypeFloat32 = "float32"
+	TypeFloat64 = "float64"
+	TypeString  = "string"
+	TypeBytes   = "bytes"
+	TypeTime    = "time"
+)
+
+// Value is a typed value.
+//
+// Numbers are encoded as decimal strings to avoid the precision loss of JSON numbers,
+// bytes are encoded in base64 and time in RFC 3339 format with nanoseconds.
+type Value struct {
+	Type string `json:"t"`
+	Data string `json:"v,omitempty"`
+}
+
+// EncodeValue converts the Go value to the typed value.
+func EncodeValue(v interface{}) (Value, error) {
+	switch val := v.(type) {
+	case nil:
+		return Value{Type: TypeNull}, nil
+	case bool:
+		return Value{Type: TypeBool, Data: strconv.FormatBool(val)}, nil
+	case int:
+		return Value{Type: TypeInt, Data: strconv.FormatInt(int64(val), 10)}, nil
+	case int8:
+		return Value{Type: TypeInt8, Data: strconv.FormatInt(int64(val), 10)}, nil
+	case int16:
+		return Value{Type: TypeInt16, Data: strconv.FormatInt(int64(val), 10)}, nil
+	case int32:
+		return Value{Type: TypeInt32, Data: strconv.FormatInt(int64(val), 10)}, nil
+	case int64:
+		return Value{Type: TypeInt64, Data: strconv.FormatInt(val, 10)}, nil
+	case uint:
+		return Value{Type: TypeUint, Data: strconv.FormatUint(uint64(val), 10)}, nil
+	case uint8:
+		return Value{Type: TypeUint8, Data: strconv.FormatUint(uint64(val), 10)}, nil
+	case uint16:
+		return Value{Type: TypeUint16, Data: strconv.FormatUint(uint64(val), 10)}, nil
+	case uint32:
+		return Value{Type: TypeUint32, Data: strconv.FormatUint(uint64(val), 10)}, nil
+	case uint64:
+		return Value{Type: TypeUint64, Data: strconv.FormatUint(val, 10)}, nil
+	case float32:
+		return Value{Type: TypeFloat32, Data: strconv.FormatFloat(float64(val), 'g', -1, 32)}, nil
+	case float64:
+		return Value{Type: TypeFloat64, Data: strconv.FormatFloat(val, 'g', -1, 64)}, nil
+	case string:
+		return Value{Type: TypeString, Data: val}, nil
+	case []byte:
+		return Value{Type: TypeBytes, Data: base64.StdEncoding.EncodeToString(val)}, nil
+	case time.Time:
+		return Value{Type: TypeTime, Data: val.Format(time.RFC3339Nano)}, nil
+	default:
+		return Value{}, fmt.Errorf("rpc: unsupported value type %T", v)
+	}
+}
+
+// Decode converts the typed value back to the Go value.
+func (v Value) Decode() (interface{}, error) {
+	switch v.Type {
+	case TypeNull:
+		return nil, nil
+	case TypeBool:
+		return strconv.ParseBool(v.Data)
+	case TypeInt:
+		i, err := strconv.ParseInt(v.Data, 10, strconv.IntSize)
+		return int(i), err
+	case TypeInt8:
+		i, err := strconv.ParseInt(v.Data, 10, 8)
+		return int8(i), err
+	case TypeInt16:
+		i, err := strconv.ParseInt(v.Data, 10, 16)
+		return int16(i), err
+	case TypeInt32:
+		i, err := strconv.ParseInt(v.Data, 10, 32)
+		return int32(i), err
+	case TypeInt64:
+		return strconv.ParseInt(v.Data, 10, 64)
+	case TypeUint:
+		u, err := strconv.ParseUint(v.Data, 10, strconv.IntSize)
+		return uint(u), err
+	case TypeUint8:
+		u, err := strconv.ParseUint(v.Data, 10, 8)
+		return uint8(u), err
+	case TypeUint16:
+		u, err := strconv.ParseUint(v.Data, 10, 16)
+		return uint16(u), err
+	case TypeUint32:
+		u, err := strconv.ParseUint(v.Data, 10, 32)
+		return uint32(u), err
+	case TypeUint64:
+		return strconv.ParseUint(v.Data, 10, 64)
+	case TypeFloat32:
+		f, err := strconv.ParseFloat(v.Data, 32)
+		return float32(f), err
+	case TypeFloat64:
+		return strconv.ParseFloat(v.Data, 64)
+	case TypeString:
+		return v.Data, nil
+	case TypeBytes:
+		return base64.StdEncoding.DecodeString(v.Data)
+	case TypeTime:
+		return time.Parse(time.RFC3339Nano, v.Data)
+	default:
+		return nil, fmt.Errorf("rpc: unsupported value type %q", v.Type)
+	}
+}
+
+func encodeRow(row []interface{}) ([]Value, error) {
+	values := make([]Value, len(row))
+	for i, v := range row {
+		val, err := EncodeValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+
+	return values, nil
+}
+
+func decodeRow(values []Value) ([]interface{}, error) {
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		val, err := v.Decode()
+		if err != nil {
+			return nil, err
+		}
+		row[i] = val
+	}
+
+	return row, nil
+}
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "Null", value: nil},
+		{name: "Bool", value: true},
+		{name: "Int", value: -42},
+		{name: "Int8", value: int8(math.MinInt8)},
+		{name: "Int16", value: int16(math.MaxInt16)},
+		{name: "Int32", value: int32(math.MinInt32)},
+		{name: "Int64", value: int64(math.MaxInt64)},
+		{name: "Uint", value: uint(42)},
+		{name: "Uint8", value: uint8(math.MaxUint8)},
+		{name: "Uint16", value: uint16(math.MaxUint16)},
+		{name: "Uint32", value: uint32(math.MaxUint32)},
+		{name: "Uint64", value: uint64(math.MaxUint64)},
+		{name: "Float32", value: float32(3.14)},
+		{name: "Float64", value: math.SmallestNonzeroFloat64},
+		{name: "Decimal", value: "12345678901234567890.123456789"},
+		{name: "String", value: "привет"},
+		{name: "Bytes", value: []byte{0x00, 0x01, 0xff}},
+		{name: "Time", value: time.Date(2020, 10, 19, 12, 30, 15, 123456789, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := EncodeValue(tt.value)
+			require.NoError(t, err)
+
+			buf, err := json.Marshal(v)
+			require.NoError(t, err)
+
+			var decoded Value
+			require.NoError(t, json.Unmarshal(buf, &decoded))
+
+			got, err := decoded.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+}
+
+func TestValue_Unsupported(t *testing.T) {
+	_, err := EncodeValue(struct{}{})
+	assert.Error(t, err)
+
+	_, err = Value{Type: "complex"}.Decode()
+	assert.Error(t, err)
+
+	_, err = Value{Type: TypeInt8, Data: "300"}.Decode()
+	assert.Error(t, err)
+}
@@ -0,0 +1,254 @@
+// Package script provides the event handler which transforms the rows with a Starlark script.
+//
+// The script defines the function on_rows(event) and optionally on_table_changed(source).
+// The event has the fields:
+//
+//	action   - "insert", "update" or "delete",
+//	source   - the source table info with the fields schema, table, pks and cols (column names),
+//	rows     - list of dicts keyed by column name, the rows after update for the update events,
+//	old_rows - list of the rows before update for the update events, empty otherwise.
+//
+// on_rows returns a list of the query dicts with the keys action, table, values and where,
+// where values and where are dicts keyed by the upstream column name.
+//
+// Scripts are sandboxed: they can not load modules or access the file system and network.
+// Every call is canceled after the configured timeout. The script file is reloaded
+// on change; if the new version fails to compile the previous one keeps working.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"gopkg.in/yaml.v3"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+const (
+	defaultTimeout = 100 * time.Millisecond
+	reloadInterval = 1 * time.Second
+
+	fnOnRows         = "on_rows"
+	fnOnTableChanged = "on_table_changed"
+)
+
+type config struct {
+	// Script is a path to the Starlark script, relative to the config file.
+	Script string `yaml:"script"`
+	// Timeout is a maximum duration of the script call.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func readConfig(path string) (*config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config{
+		Timeout: defaultTimeout,
+	}
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Script == "" {
+		return nil, errors.New("script path is empty")
+	}
+	if !filepath.IsAbs(cfg.Script) {
+		cfg.Script = filepath.Join(filepath.Dir(path), cfg.Script)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return &cfg, nil
+}
+
+// program is the compiled script.
+type program struct {
+	globals starlark.StringDict
+	modTime time.Time
+	size    int64
+}
+
+// EventHandler calls the script functions.
+type EventHandler struct {
+	path    string
+	timeout time.Duration
+
+	mu        *sync.Mutex
+	prog      *program
+	checkedAt time.Time
+	info      *mymy.SourceInfo
+}
+
+// NewEventHandler creates the script handler using the YAML configuration file.
+func NewEventHandler(cfgPath string) (mymy.EventHandler, error) {
+	cfg, err := readConfig(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &EventHandler{
+		path:    cfg.Script,
+		timeout: cfg.Timeout,
+		mu:      &sync.Mutex{},
+	}
+
+	h.prog, err = h.load()
+	if err != nil {
+		return nil, err
+	}
+	h.checkedAt = time.Now()
+
+	return h, nil
+}
+
+func (h *EventHandler) OnTableChanged(info mymy.SourceInfo) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.reload()
+	h.info = &info
+
+	fn, ok := h.prog.globals[fnOnTableChanged]
+	if !ok {
+		return nil
+	}
+
+	_, err := h.call(fn, newSource(info))
+
+	return err
+}
+
+func (h *EventHandler) OnRows(e *mymy.RowsEvent) ([]*mymy.Query, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.reload()
+
+	fn, ok := h.prog.globals[fnOnRows]
+	if !ok {
+		return nil, fmt.Errorf("script %s: function %s is not defined", h.path, fnOnRows)
+	}
+
+	event, err := newEvent(e)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := h.call(fn, event)
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := toQueries(res)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", h.path, err)
+	}
+
+	return queries, nil
+}
+
+func (h *EventHandler) newThread() *starlark.Thread {
+	return &starlark.Thread{
+		Name: h.path,
+		Print: func(_ *starlark.Thread, msg string) {
+			log.Info().Str("script", h.path).Msg(msg)
+		},
+	}
+}
+
+func (h *EventHandler) call(fn starlark.Value, args ...starlark.Value) (starlark.Value, error) {
+	thread := h.newThread()
+	timer := time.AfterFunc(h.timeout, func() {
+		thread.Cancel("timeout")
+	})
+	defer timer.Stop()
+
+	res, err := starlark.Call(thread, fn, args, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", h.path, err)
+	}
+
+	return res, nil
+}
+
+// load compiles the script and runs its top-level statements.
+func (h *EventHandler) load() (*program, error) {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := ioutil.ReadFile(h.path)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := h.newThread()
+	timer := time.AfterFunc(h.timeout, func() {
+		thread.Cancel("timeout")
+	})
+	defer timer.Stop()
+
+	predeclared := starlark.StringDict{
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+	}
+	globals, err := starlark.ExecFile(thread, h.path, src, predeclared)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load script %s: %w", h.path, err)
+	}
+
+	return &program{
+		globals: globals,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}, nil
+}
+
+// reload loads the script again if the file has changed.
+func (h *EventHandler) reload() {
+	now := time.Now()
+	if now.Sub(h.checkedAt) < reloadInterval {
+		return
+	}
+	h.checkedAt = now
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		log.Error().Err(err).Str("script", h.path).Msg("failed to check script, keep the previous version")
+
+		return
+	}
+	if info.ModTime().Equal(h.prog.modTime) && info.Size() == h.prog.size {
+		return
+	}
+
+	prog, err := h.load()
+	if err != nil {
+		log.Error().Err(err).Str("script", h.path).Msg("failed to reload script, keep the previous version")
+
+		return
+	}
+	h.prog = prog
+
+	if fn, ok := prog.globals[fnOnTableChanged]; ok && h.info != nil {
+		if _, err = h.call(fn, newSource(*h.info)); err != nil {
+			log.Error().Err(err).Str("script", h.path).Msg("failed to pass table info to reloaded script")
+		}
+	}
+
+	log.Info().Str("script", h.path).Msg("script reloaded")
+}
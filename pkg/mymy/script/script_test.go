@@ -0,0 +1,208 @@
+package script
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+var tSource = mymy.SourceInfo{
+	Schema: "city",
+	Table:  "users",
+	PKs: []mymy.Column{
+		{Index: 0, Name: "id", Type: mymy.TypeNumber},
+	},
+	Cols: []mymy.Column{
+		{Index: 1, Name: "name", Type: mymy.TypeString},
+		{Index: 2, Name: "password", Type: mymy.TypeString},
+	},
+}
+
+const tScript = `
+def on_rows(event):
+    queries = []
+    for i, row in enumerate(event.rows):
+        if event.action == "delete":
+            queries.append({"action": "delete", "table": "clients", "where": {"id": row["id"]}})
+        elif event.action == "update":
+            old = event.old_rows[i]
+            queries.append({
+                "action": "update",
+                "table": "clients",
+                "values": {"name": row["name"].upper()},
+                "where": {"id": old["id"]},
+            })
+        else:
+            queries.append({"action": event.action, "table": "clients", "values": {"id": row["id"], "name": row["name"].upper()}})
+    return queries
+`
+
+func newTestHandler(t *testing.T, script string) (*EventHandler, string) {
+	dir, err := ioutil.TempDir("", "mymy-script")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	scriptPath := filepath.Join(dir, "users.star")
+	require.NoError(t, ioutil.WriteFile(scriptPath, []byte(script), 0644))
+
+	cfgPath := filepath.Join(dir, "users.yml")
+	require.NoError(t, ioutil.WriteFile(cfgPath, []byte("script: 'users.star'\ntimeout: '50ms'\n"), 0644))
+
+	h, err := NewEventHandler(cfgPath)
+	require.NoError(t, err)
+
+	return h.(*EventHandler), scriptPath
+}
+
+func TestEventHandler_OnRows(t *testing.T) {
+	h, _ := newTestHandler(t, tScript)
+	require.NoError(t, h.OnTableChanged(tSource))
+
+	tests := []struct {
+		name string
+		e    *mymy.RowsEvent
+		want []*mymy.Query
+	}{
+		{
+			name: "Insert",
+			e: &mymy.RowsEvent{
+				Action: mymy.ActionInsert,
+				Source: tSource,
+				Rows:   [][]interface{}{{int32(1), "bob", "12345"}},
+			},
+			want: []*mymy.Query{
+				{
+					Action: mymy.ActionInsert,
+					Table:  "clients",
+					Values: []mymy.QueryArg{{Field: "id", Value: int64(1)}, {Field: "name", Value: "BOB"}},
+				},
+			},
+		},
+		{
+			name: "Update",
+			e: &mymy.RowsEvent{
+				Action: mymy.ActionUpdate,
+				Source: tSource,
+				Rows:   [][]interface{}{{uint64(1), "bob", "12345"}, {uint64(1), "alice", "12345"}},
+			},
+			want: []*mymy.Query{
+				{
+					Action: mymy.ActionUpdate,
+					Table:  "clients",
+					Values: []mymy.QueryArg{{Field: "name", Value: "ALICE"}},
+					Where:  []mymy.QueryArg{{Field: "id", Value: int64(1)}},
+				},
+			},
+		},
+		{
+			name: "Delete",
+			e: &mymy.RowsEvent{
+				Action: mymy.ActionDelete,
+				Source: tSource,
+				Rows:   [][]interface{}{{uint64(2), nil, nil}},
+			},
+			want: []*mymy.Query{
+				{
+					Action: mymy.ActionDelete,
+					Table:  "clients",
+					Where:  []mymy.QueryArg{{Field: "id", Value: int64(2)}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.OnRows(tt.e)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEventHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{
+			name:   "Timeout",
+			script: "def on_rows(event):\n    for i in range(1000000000):\n        pass\n",
+		},
+		{
+			name:   "InvalidResult",
+			script: "def on_rows(event):\n    return [{\"table\": \"clients\"}]\n",
+		},
+		{
+			name:   "NoFunction",
+			script: "x = 1\n",
+		},
+	}
+
+	e := &mymy.RowsEvent{
+		Action: mymy.ActionInsert,
+		Source: tSource,
+		Rows:   [][]interface{}{{int64(1), "bob", "12345"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t, tt.script)
+
+			_, err := h.OnRows(e)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewEventHandler_Sandbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mymy-script")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "users.star"), []byte("load('os.star', 'exec')\n"), 0644))
+	cfgPath := filepath.Join(dir, "users.yml")
+	require.NoError(t, ioutil.WriteFile(cfgPath, []byte("script: 'users.star'\n"), 0644))
+
+	_, err = NewEventHandler(cfgPath)
+	assert.Error(t, err)
+}
+
+func TestEventHandler_Reload(t *testing.T) {
+	h, scriptPath := newTestHandler(t, tScript)
+
+	e := &mymy.RowsEvent{
+		Action: mymy.ActionInsert,
+		Source: tSource,
+		Rows:   [][]interface{}{{int64(1), "bob", "12345"}},
+	}
+
+	// The broken script is ignored.
+	require.NoError(t, ioutil.WriteFile(scriptPath, []byte("def on_rows(event)\n"), 0644))
+	h.checkedAt = time.Time{}
+
+	got, err := h.OnRows(e)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "clients", got[0].Table)
+
+	require.NoError(t, ioutil.WriteFile(scriptPath, []byte("def on_rows(event):\n    return []\n"), 0644))
+	h.checkedAt = time.Time{}
+
+	got, err = h.OnRows(e)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,253 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"github.com/city-mobil/go-mymy/pkg/mymy"
+)
+
+func columnNames(cols []mymy.Column) *starlark.List {
+	names := make([]starlark.Value, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, starlark.String(col.Name))
+	}
+
+	return starlark.NewList(names)
+}
+
+func newSource(info mymy.SourceInfo) starlark.Value {
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"schema": starlark.String(info.Schema),
+		"table":  starlark.String(info.Table),
+		"pks":    columnNames(info.PKs),
+		"cols":   columnNames(info.Cols),
+	})
+}
+
+// newRow converts the row to the dict keyed by column name.
+func newRow(info mymy.SourceInfo, row []interface{}) (*starlark.Dict, error) {
+	dict := starlark.NewDict(len(info.PKs) + len(info.Cols))
+	for _, cols := range [][]mymy.Column{info.PKs, info.Cols} {
+		for i := range cols {
+			v, err := cols[i].GetValue(row)
+			if err != nil {
+				return nil, err
+			}
+
+			sv, err := toStarlark(v)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", cols[i].Name, err)
+			}
+
+			if err = dict.SetKey(starlark.String(cols[i].Name), sv); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return dict, nil
+}
+
+func newEvent(e *mymy.RowsEvent) (starlark.Value, error) {
+	var rows, oldRows []starlark.Value
+	for i, row := range e.Rows {
+		dict, err := newRow(e.Source, row)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Action == mymy.ActionUpdate && i%2 == 0 {
+			oldRows = append(oldRows, dict)
+		} else {
+			rows = append(rows, dict)
+		}
+	}
+
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"action":   starlark.String(e.Action),
+		"source":   newSource(e.Source),
+		"rows":     starlark.NewList(rows),
+		"old_rows": starlark.NewList(oldRows),
+	}), nil
+}
+
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch val := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(val), nil
+	case int:
+		return starlark.MakeInt(val), nil
+	case int8:
+		return starlark.MakeInt64(int64(val)), nil
+	case int16:
+		return starlark.MakeInt64(int64(val)), nil
+	case int32:
+		return starlark.MakeInt64(int64(val)), nil
+	case int64:
+		return starlark.MakeInt64(val), nil
+	case uint:
+		return starlark.MakeUint(val), nil
+	case uint8:
+		return starlark.MakeUint64(uint64(val)), nil
+	case uint16:
+		return starlark.MakeUint64(uint64(val)), nil
+	case uint32:
+		return starlark.MakeUint64(uint64(val)), nil
+	case uint64:
+		return starlark.MakeUint64(val), nil
+	case float32:
+		return starlark.Float(val), nil
+	case float64:
+		return starlark.Float(val), nil
+	case string:
+		return starlark.String(val), nil
+	case []byte:
+		return starlark.String(val), nil
+	case time.Time:
+		return starlark.String(val.Format(time.RFC3339Nano)), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch val := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(val), nil
+	case starlark.Int:
+		if i, ok := val.Int64(); ok {
+			return i, nil
+		}
+		if u, ok := val.Uint64(); ok {
+			return u, nil
+		}
+
+		return nil, fmt.Errorf("integer %s overflows 64 bits", val)
+	case starlark.Float:
+		return float64(val), nil
+	case starlark.String:
+		return string(val), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %s", v.Type())
+	}
+}
+
+func toArgs(v starlark.Value) ([]mymy.QueryArg, error) {
+	if v == nil || v == starlark.None {
+		return nil, nil
+	}
+
+	dict, ok := v.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("expected dict, got %s", v.Type())
+	}
+
+	args := make([]mymy.QueryArg, 0, dict.Len())
+	for _, item := range dict.Items() {
+		field, ok := starlark.AsString(item[0])
+		if !ok {
+			return nil, fmt.Errorf("expected string field name, got %s", item[0].Type())
+		}
+
+		val, err := fromStarlark(item[1])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+
+		args = append(args, mymy.QueryArg{Field: field, Value: val})
+	}
+
+	return args, nil
+}
+
+func getString(dict *starlark.Dict, key string) (string, error) {
+	v, found, err := dict.Get(starlark.String(key))
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("query %s is required", key)
+	}
+
+	s, ok := starlark.AsString(v)
+	if !ok {
+		return "", fmt.Errorf("query %s must be string, got %s", key, v.Type())
+	}
+
+	return s, nil
+}
+
+func toQuery(v starlark.Value) (*mymy.Query, error) {
+	dict, ok := v.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("query must be dict, got %s", v.Type())
+	}
+
+	action, err := getString(dict, "action")
+	if err != nil {
+		return nil, err
+	}
+	table, err := getString(dict, "table")
+	if err != nil {
+		return nil, err
+	}
+
+	q := &mymy.Query{
+		Action: mymy.Action(action),
+		Table:  table,
+	}
+
+	values, _, err := dict.Get(starlark.String("values"))
+	if err != nil {
+		return nil, err
+	}
+	if q.Values, err = toArgs(values); err != nil {
+		return nil, fmt.Errorf("query values: %w", err)
+	}
+
+	where, _, err := dict.Get(starlark.String("where"))
+	if err != nil {
+		return nil, err
+	}
+	if q.Where, err = toArgs(where); err != nil {
+		return nil, fmt.Errorf("query where: %w", err)
+	}
+
+	return q, nil
+}
+
+// toQueries converts the result of on_rows to the queries.
+func toQueries(v starlark.Value) ([]*mymy.Query, error) {
+	if v == starlark.None {
+		return nil, nil
+	}
+
+	iterable, ok := v.(starlark.Iterable)
+	if !ok {
+		return nil, errors.New("on_rows must return a list of queries")
+	}
+
+	var queries []*mymy.Query
+	iter := iterable.Iterate()
+	defer iter.Done()
+
+	var item starlark.Value
+	for iter.Next(&item) {
+		q, err := toQuery(item)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+
+	return queries, nil
+}